@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// APIKeyPrefix đứng đầu mọi API key để dễ nhận ra khi bị lộ trong log/code.
+	APIKeyPrefix = "glk_"
+
+	maxAPIKeysPerUser = 20
+	// apiKeyTouchInterval: last_used_at chỉ được ghi tối đa mỗi phút một lần.
+	apiKeyTouchInterval = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey     = errors.New("invalid api key")
+	ErrAPIKeyNotFound    = errors.New("api key not found")
+	ErrTooManyAPIKeys    = errors.New("too many active api keys")
+	ErrScopeNotPermitted = errors.New("scope not permitted for this role")
+)
+
+func apiKeys() *mongo.Collection {
+	return config.DB.Collection("api_keys")
+}
+
+// APIKeyIdentity là kết quả xác thực một API key.
+type APIKeyIdentity struct {
+	Key  models.APIKey
+	User models.User
+}
+
+// Scopes trả về scope của key dưới dạng Permission.
+func (id APIKeyIdentity) Scopes() []Permission {
+	scopes := make([]Permission, 0, len(id.Key.Scopes))
+	for _, s := range id.Key.Scopes {
+		scopes = append(scopes, Permission(s))
+	}
+	return scopes
+}
+
+// CreateAPIKey tạo key mới cho user. Scope phải nằm trong quyền của role
+// hiện tại; expiresAt nil nghĩa là không hết hạn. Key gốc chỉ được trả về
+// một lần ở đây.
+func CreateAPIKey(ctx context.Context, user models.User, name string, scopes []Permission, expiresAt *time.Time) (string, models.APIKey, error) {
+	seen := map[Permission]bool{}
+	stored := []string{}
+	for _, s := range scopes {
+		if !HasPermission(user.Role, s) {
+			return "", models.APIKey{}, ErrScopeNotPermitted
+		}
+		if !seen[s] {
+			seen[s] = true
+			stored = append(stored, string(s))
+		}
+	}
+
+	active, err := apiKeys().CountDocuments(ctx, activeAPIKeyFilter(user.ID))
+	if err != nil {
+		return "", models.APIKey{}, err
+	}
+	if active >= maxAPIKeysPerUser {
+		return "", models.APIKey{}, ErrTooManyAPIKeys
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", models.APIKey{}, err
+	}
+	raw := APIKeyPrefix + secret
+	key := models.APIKey{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.ID,
+		Name:      name,
+		Prefix:    raw[:len(APIKeyPrefix)+8],
+		KeyHash:   HashToken(raw),
+		Scopes:    stored,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+	if _, err := apiKeys().InsertOne(ctx, key); err != nil {
+		return "", models.APIKey{}, err
+	}
+	return raw, key, nil
+}
+
+func activeAPIKeyFilter(userID primitive.ObjectID) bson.M {
+	return bson.M{
+		"user_id":    userID,
+		"revoked_at": nil,
+		"$or": bson.A{
+			bson.M{"expires_at": nil},
+			bson.M{"expires_at": bson.M{"$gt": time.Now()}},
+		},
+	}
+}
+
+// ListAPIKeys trả về mọi key của user (kể cả key đã thu hồi/hết hạn), mới nhất trước.
+func ListAPIKeys(ctx context.Context, userID primitive.ObjectID) ([]models.APIKey, error) {
+	cursor, err := apiKeys().Find(ctx, bson.M{"user_id": userID}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+	keys := []models.APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey thu hồi key của user. Thu hồi lại key đã thu hồi không lỗi.
+func RevokeAPIKey(ctx context.Context, userID, keyID primitive.ObjectID) error {
+	res, err := apiKeys().UpdateOne(ctx,
+		bson.M{"_id": keyID, "user_id": userID},
+		[]bson.M{{"$set": bson.M{"revoked_at": bson.M{"$ifNull": bson.A{"$revoked_at", "$$NOW"}}}}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// revokeAPIKeysForUser thu hồi mọi key còn hiệu lực của user, dùng khi user
+// đổi/đặt lại mật khẩu hoặc đăng xuất mọi nơi.
+func revokeAPIKeysForUser(ctx context.Context, userID primitive.ObjectID, reason string) error {
+	_, err := apiKeys().UpdateMany(ctx, bson.M{"user_id": userID, "revoked_at": nil}, bson.M{"$set": bson.M{
+		"revoked_at":    time.Now(),
+		"revoke_reason": reason,
+	}})
+	return err
+}
+
+// AuthenticateAPIKey xác thực key gửi qua header X-API-Key và tải user sở
+// hữu nó. Role lấy theo user hiện tại nên hạ quyền user có hiệu lực ngay.
+func AuthenticateAPIKey(ctx context.Context, raw, ip string) (APIKeyIdentity, error) {
+	var id APIKeyIdentity
+	if !strings.HasPrefix(raw, APIKeyPrefix) {
+		return id, ErrInvalidAPIKey
+	}
+
+	err := apiKeys().FindOne(ctx, bson.M{"key_hash": HashToken(raw)}).Decode(&id.Key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return id, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return id, err
+	}
+	now := time.Now()
+	if id.Key.RevokedAt != nil {
+		return id, ErrTokenRevoked
+	}
+	if id.Key.ExpiresAt != nil && !id.Key.ExpiresAt.After(now) {
+		return id, ErrInvalidAPIKey
+	}
+
+	err = config.DB.Collection("users").FindOne(ctx, bson.M{"_id": id.Key.UserID}).Decode(&id.User)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return id, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return id, err
+	}
+
+	// Ghi last_used_at thưa để không ghi DB ở mọi request.
+	if id.Key.LastUsedAt == nil || now.Sub(*id.Key.LastUsedAt) >= apiKeyTouchInterval {
+		apiKeys().UpdateOne(ctx,
+			bson.M{"_id": id.Key.ID, "$or": bson.A{
+				bson.M{"last_used_at": nil},
+				bson.M{"last_used_at": bson.M{"$lte": now.Add(-apiKeyTouchInterval)}},
+			}},
+			bson.M{"$set": bson.M{"last_used_at": now, "last_used_ip": ip}},
+		)
+	}
+	return id, nil
+}
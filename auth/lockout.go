@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// lockoutPolicy: từ lần sai thứ Threshold trở đi, khoá tạm BaseDelay và
+// nhân đôi sau mỗi lần sai tiếp theo, tối đa MaxDelay.
+type lockoutPolicy struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+var (
+	accountLockout = lockoutPolicy{Threshold: 5, BaseDelay: 30 * time.Second, MaxDelay: 30 * time.Minute}
+	ipLockout      = lockoutPolicy{Threshold: 20, BaseDelay: 10 * time.Second, MaxDelay: 30 * time.Minute}
+)
+
+// failureWindow: bộ đếm được reset nếu không sai thêm lần nào trong 24 giờ.
+const failureWindow = 24 * time.Hour
+
+func (p lockoutPolicy) delay(failures int) time.Duration {
+	if failures < p.Threshold {
+		return 0
+	}
+	shift := failures - p.Threshold
+	if shift > 20 {
+		return p.MaxDelay
+	}
+	d := p.BaseDelay << shift
+	if d > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return d
+}
+
+// dummyHash dùng để so sánh khi email không tồn tại, giữ thời gian phản hồi
+// giống như khi sai mật khẩu. Được tạo ở lần dùng đầu tiên.
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+// CheckPasswordTiming giống CheckPassword nhưng vẫn tốn thời gian bcrypt khi
+// không có hash (user không tồn tại).
+func CheckPasswordTiming(hash, password string) bool {
+	if hash == "" {
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcryptCost)
+		})
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return CheckPassword(hash, password)
+}
+
+func loginAttempts() *mongo.Collection {
+	return config.DB.Collection("login_attempts")
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// LoginLockedFor trả về thời gian còn bị khoá của email hoặc IP (0 nếu không
+// bị khoá).
+func LoginLockedFor(ctx context.Context, email, ip string) (time.Duration, error) {
+	now := time.Now()
+	cursor, err := loginAttempts().Find(ctx, bson.M{
+		"_id":          bson.M{"$in": bson.A{accountKey(email), ipKey(ip)}},
+		"locked_until": bson.M{"$gt": now},
+	})
+	if err != nil {
+		return 0, err
+	}
+	var locked []models.LoginAttempt
+	if err := cursor.All(ctx, &locked); err != nil {
+		return 0, err
+	}
+	var wait time.Duration
+	for _, a := range locked {
+		if d := a.LockedUntil.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// RecordLoginFailure tăng bộ đếm của email và IP, khoá tạm nếu vượt ngưỡng.
+func RecordLoginFailure(ctx context.Context, email, ip string) error {
+	if err := recordFailure(ctx, accountKey(email), accountLockout); err != nil {
+		return err
+	}
+	return recordFailure(ctx, ipKey(ip), ipLockout)
+}
+
+func recordFailure(ctx context.Context, key string, policy lockoutPolicy) error {
+	now := time.Now()
+	var attempt models.LoginAttempt
+	err := loginAttempts().FindOneAndUpdate(ctx,
+		bson.M{"_id": key},
+		bson.M{
+			"$inc": bson.M{"failures": 1},
+			"$set": bson.M{"last_failure_at": now, "expires_at": now.Add(failureWindow)},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&attempt)
+	if err != nil {
+		return err
+	}
+	if d := policy.delay(attempt.Failures); d > 0 {
+		_, err = loginAttempts().UpdateOne(ctx, bson.M{"_id": key}, bson.M{"$set": bson.M{"locked_until": now.Add(d)}})
+	}
+	return err
+}
+
+// ClearLoginFailures reset bộ đếm của email sau khi đăng nhập thành công.
+// Bộ đếm theo IP được giữ nguyên để một tài khoản hợp lệ không xoá được
+// dấu vết dò mật khẩu các tài khoản khác từ cùng IP.
+func ClearLoginFailures(ctx context.Context, email string) error {
+	_, err := loginAttempts().DeleteOne(ctx, bson.M{"_id": accountKey(email)})
+	return err
+}
+
+// UnlockAccount xoá bộ đếm và khoá của email (và IP nếu khác rỗng).
+func UnlockAccount(ctx context.Context, email, ip string) error {
+	keys := bson.A{accountKey(email)}
+	if ip != "" {
+		keys = append(keys, ipKey(ip))
+	}
+	_, err := loginAttempts().DeleteMany(ctx, bson.M{"_id": bson.M{"$in": keys}})
+	return err
+}
+
+// RecordLogin ghi một lần thử đăng nhập vào login_history.
+func RecordLogin(ctx context.Context, entry models.LoginHistory) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	_, err := config.DB.Collection("login_history").InsertOne(ctx, entry)
+	return err
+}
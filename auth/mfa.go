@@ -0,0 +1,365 @@
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/models"
+
+	"github.com/skip2/go-qrcode"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// MFAIssuer là tên hiển thị trong ứng dụng authenticator.
+	MFAIssuer = "Game Library"
+
+	mfaChallengeTTL         = 5 * time.Minute
+	mfaChallengeMaxAttempts = 5
+	recoveryCodeCount       = 10
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled    = errors.New("two-factor authentication is not enrolled")
+	ErrInvalidMFACode    = errors.New("invalid two-factor code")
+	ErrInvalidChallenge  = errors.New("invalid or expired mfa challenge")
+)
+
+// Enrollment là thông tin trả về khi bắt đầu bật 2FA.
+type Enrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	QRCodePNG  []byte `json:"qr_png"` // JSON hoá thành base64
+}
+
+func userMFA() *mongo.Collection {
+	return config.DB.Collection("user_mfa")
+}
+
+func mfaChallenges() *mongo.Collection {
+	return config.DB.Collection("mfa_challenges")
+}
+
+// MFAEnrollmentRequired: admin bắt buộc bật 2FA; khi chưa bật, token của họ
+// chỉ dùng được cho các route bật 2FA.
+func MFAEnrollmentRequired(user models.User) bool {
+	return user.Role == RoleAdmin && !user.MFAEnabled
+}
+
+// mfaKey là khoá AES dùng để mã hoá TOTP secret trong DB, suy ra từ JWT secret.
+func mfaKey() []byte {
+	mac := hmac.New(sha256.New, jwtSecret)
+	mac.Write([]byte("totp-secret-encryption"))
+	return mac.Sum(nil)
+}
+
+func encryptSecret(plain string) (string, error) {
+	block, err := aes.NewCipher(mfaKey())
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decryptSecret(encoded string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(mfaKey())
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("invalid encrypted secret")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	return string(plain), err
+}
+
+// BeginEnrollment tạo secret mới ở trạng thái chờ xác nhận. Gọi lại sẽ thay
+// secret đang chờ bằng secret mới.
+func BeginEnrollment(ctx context.Context, user models.User) (Enrollment, error) {
+	if user.MFAEnabled {
+		return Enrollment{}, ErrMFAAlreadyEnabled
+	}
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return Enrollment{}, err
+	}
+	encrypted, err := encryptSecret(secret)
+	if err != nil {
+		return Enrollment{}, err
+	}
+
+	_, err = userMFA().ReplaceOne(ctx,
+		bson.M{"_id": user.ID, "confirmed_at": nil},
+		models.UserMFA{UserID: user.ID, Secret: encrypted, RecoveryCodes: []string{}, CreatedAt: time.Now()},
+		options.Replace().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		// Đã có bản ghi đã xác nhận.
+		return Enrollment{}, ErrMFAAlreadyEnabled
+	}
+	if err != nil {
+		return Enrollment{}, err
+	}
+
+	uri := TOTPURI(MFAIssuer, user.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	return Enrollment{Secret: secret, OTPAuthURI: uri, QRCodePNG: png}, nil
+}
+
+// ConfirmEnrollment bật 2FA khi code khớp với secret đang chờ và trả về
+// danh sách recovery code (chỉ hiển thị một lần).
+func ConfirmEnrollment(ctx context.Context, userID primitive.ObjectID, code string) ([]string, error) {
+	var record models.UserMFA
+	err := userMFA().FindOne(ctx, bson.M{"_id": userID}).Decode(&record)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrMFANotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if record.ConfirmedAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := decryptSecret(record.Secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := validateTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	err = config.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		res, err := userMFA().UpdateOne(sessCtx, bson.M{"_id": userID, "confirmed_at": nil}, bson.M{"$set": bson.M{
+			"confirmed_at":   now,
+			"recovery_codes": hashes,
+			"last_used_step": step,
+		}})
+		if err != nil {
+			return err
+		}
+		if res.ModifiedCount == 0 {
+			return ErrMFAAlreadyEnabled
+		}
+		_, err = config.DB.Collection("users").UpdateByID(sessCtx, userID, bson.M{"$set": bson.M{"mfa_enabled": true}})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifyMFA kiểm tra code của user đã bật 2FA. code có thể là mã TOTP hoặc
+// một recovery code; recovery code bị xoá sau khi dùng và mỗi mã TOTP chỉ
+// được chấp nhận một lần.
+func VerifyMFA(ctx context.Context, userID primitive.ObjectID, code string) error {
+	var record models.UserMFA
+	err := userMFA().FindOne(ctx, bson.M{"_id": userID, "confirmed_at": bson.M{"$ne": nil}}).Decode(&record)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrMFANotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+
+	secret, err := decryptSecret(record.Secret)
+	if err != nil {
+		return err
+	}
+	if step, ok := validateTOTP(secret, code, time.Now()); ok {
+		res, err := userMFA().UpdateOne(ctx,
+			bson.M{"_id": userID, "last_used_step": bson.M{"$lt": step}},
+			bson.M{"$set": bson.M{"last_used_step": step}},
+		)
+		if err != nil {
+			return err
+		}
+		if res.ModifiedCount == 0 {
+			return ErrInvalidMFACode // mã đã được dùng
+		}
+		return nil
+	}
+
+	hash := HashToken(normalizeRecoveryCode(code))
+	res, err := userMFA().UpdateOne(ctx,
+		bson.M{"_id": userID, "recovery_codes": hash},
+		bson.M{"$pull": bson.M{"recovery_codes": hash}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes thay toàn bộ recovery code cũ bằng bộ mới.
+func RegenerateRecoveryCodes(ctx context.Context, userID primitive.ObjectID) ([]string, error) {
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	res, err := userMFA().UpdateOne(ctx,
+		bson.M{"_id": userID, "confirmed_at": bson.M{"$ne": nil}},
+		bson.M{"$set": bson.M{"recovery_codes": hashes}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if res.MatchedCount == 0 {
+		return nil, ErrMFANotEnrolled
+	}
+	return codes, nil
+}
+
+// DisableMFA tắt 2FA và xoá secret.
+func DisableMFA(ctx context.Context, userID primitive.ObjectID) error {
+	return config.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		if _, err := userMFA().DeleteOne(sessCtx, bson.M{"_id": userID}); err != nil {
+			return err
+		}
+		_, err := config.DB.Collection("users").UpdateByID(sessCtx, userID, bson.M{"$set": bson.M{"mfa_enabled": false}})
+		return err
+	})
+}
+
+// newRecoveryCodes sinh recovery code dạng xxxxx-xxxxx cùng hash để lưu.
+func newRecoveryCodes() ([]string, []string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	buf := make([]byte, 10)
+	for i := 0; i < recoveryCodeCount; i++ {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		var b strings.Builder
+		for j, v := range buf {
+			if j == 5 {
+				b.WriteByte('-')
+			}
+			b.WriteByte(alphabet[int(v)%len(alphabet)])
+		}
+		code := b.String()
+		codes = append(codes, code)
+		hashes = append(hashes, HashToken(normalizeRecoveryCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// CreateMFAChallenge tạo challenge token cho bước đăng nhập thứ hai.
+func CreateMFAChallenge(ctx context.Context, userID primitive.ObjectID) (string, time.Time, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := time.Now()
+	expiresAt := now.Add(mfaChallengeTTL)
+	_, err = mfaChallenges().InsertOne(ctx, models.MFAChallenge{
+		ID:        primitive.NewObjectID(),
+		TokenHash: HashToken(token),
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// MFAChallengeUser trả về user của challenge còn hiệu lực mà không tính là
+// một lần thử, để kiểm tra khoá đăng nhập trước khi xác minh code.
+func MFAChallengeUser(ctx context.Context, token string) (primitive.ObjectID, error) {
+	var challenge models.MFAChallenge
+	err := mfaChallenges().FindOne(ctx, bson.M{
+		"token_hash": HashToken(token),
+		"expires_at": bson.M{"$gt": time.Now()},
+		"attempts":   bson.M{"$lt": mfaChallengeMaxAttempts},
+	}).Decode(&challenge)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return primitive.NilObjectID, ErrInvalidChallenge
+	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return challenge.UserID, nil
+}
+
+// CompleteMFAChallenge kiểm tra code cho challenge. Mỗi challenge được thử
+// tối đa mfaChallengeMaxAttempts lần và bị xoá khi thành công.
+func CompleteMFAChallenge(ctx context.Context, token, code string) (primitive.ObjectID, error) {
+	var challenge models.MFAChallenge
+	err := mfaChallenges().FindOneAndUpdate(ctx,
+		bson.M{
+			"token_hash": HashToken(token),
+			"expires_at": bson.M{"$gt": time.Now()},
+			"attempts":   bson.M{"$lt": mfaChallengeMaxAttempts},
+		},
+		bson.M{"$inc": bson.M{"attempts": 1}},
+	).Decode(&challenge)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return primitive.NilObjectID, ErrInvalidChallenge
+	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	if err := VerifyMFA(ctx, challenge.UserID, code); err != nil {
+		return primitive.NilObjectID, err
+	}
+	res, err := mfaChallenges().DeleteOne(ctx, bson.M{"_id": challenge.ID})
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	if res.DeletedCount == 0 {
+		// Challenge đã được dùng bởi một request đồng thời.
+		return primitive.NilObjectID, ErrInvalidChallenge
+	}
+	return challenge.UserID, nil
+}
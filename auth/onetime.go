@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Mục đích của one-time token.
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+	PurposeChangeEmail   = "change_email"
+)
+
+var (
+	ErrInvalidOneTimeToken = errors.New("invalid or expired token")
+	ErrTooManyRequests     = errors.New("too many requests")
+)
+
+// RateLimit giới hạn số token được tạo cho một user với cùng mục đích.
+type RateLimit struct {
+	MinInterval time.Duration // khoảng cách tối thiểu giữa hai lần tạo
+	MaxPerDay   int           // số token tối đa trong 24 giờ
+}
+
+// RateLimitError cho biết bao lâu nữa mới được tạo token mới.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string { return ErrTooManyRequests.Error() }
+func (e *RateLimitError) Unwrap() error { return ErrTooManyRequests }
+
+func oneTimeTokens() *mongo.Collection {
+	return config.DB.Collection("one_time_tokens")
+}
+
+// IssueOneTimeToken tạo một token dùng một lần cho user. Chỉ hash của token
+// được lưu; các token cũ chưa dùng cùng mục đích bị vô hiệu (đánh dấu đã dùng
+// nhưng vẫn giữ lại để tính rate limit).
+func IssueOneTimeToken(ctx context.Context, userID primitive.ObjectID, purpose string, ttl time.Duration, limit RateLimit) (string, error) {
+	now := time.Now()
+	if limit.MinInterval > 0 || limit.MaxPerDay > 0 {
+		if err := checkRateLimit(ctx, userID, purpose, now, limit); err != nil {
+			return "", err
+		}
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = oneTimeTokens().UpdateMany(ctx,
+		bson.M{"user_id": userID, "purpose": purpose, "used_at": nil},
+		bson.M{"$set": bson.M{"used_at": now}},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = oneTimeTokens().InsertOne(ctx, models.OneTimeToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: HashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func checkRateLimit(ctx context.Context, userID primitive.ObjectID, purpose string, now time.Time, limit RateLimit) error {
+	filter := bson.M{"user_id": userID, "purpose": purpose, "created_at": bson.M{"$gt": now.Add(-24 * time.Hour)}}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := oneTimeTokens().Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	var recent []models.OneTimeToken
+	if err := cursor.All(ctx, &recent); err != nil {
+		return err
+	}
+	if len(recent) == 0 {
+		return nil
+	}
+
+	if limit.MaxPerDay > 0 && len(recent) >= limit.MaxPerDay {
+		return &RateLimitError{RetryAfter: recent[0].CreatedAt.Add(24 * time.Hour).Sub(now)}
+	}
+	last := recent[len(recent)-1].CreatedAt
+	if wait := last.Add(limit.MinInterval).Sub(now); limit.MinInterval > 0 && wait > 0 {
+		return &RateLimitError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// ConsumeOneTimeToken đánh dấu token đã dùng và trả về user sở hữu nó.
+// Mỗi token chỉ dùng được một lần, kể cả khi có request đồng thời.
+func ConsumeOneTimeToken(ctx context.Context, purpose, token string) (primitive.ObjectID, error) {
+	now := time.Now()
+	var record models.OneTimeToken
+	err := oneTimeTokens().FindOneAndUpdate(ctx,
+		bson.M{
+			"token_hash": HashToken(token),
+			"purpose":    purpose,
+			"used_at":    nil,
+			"expires_at": bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{"used_at": now}},
+	).Decode(&record)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return primitive.NilObjectID, ErrInvalidOneTimeToken
+	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return record.UserID, nil
+}
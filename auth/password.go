@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptCost giữ nguyên cost 12 như khi đăng ký.
+const bcryptCost = 12
+
+// Giới hạn độ dài mật khẩu. bcrypt chỉ dùng 72 byte đầu nên không cho dài hơn.
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+var (
+	ErrPasswordTooShort = errors.New("password must be at least 8 characters")
+	ErrPasswordTooLong  = errors.New("password must be at most 72 bytes")
+	ErrPasswordTooWeak  = errors.New("password must contain both letters and digits")
+	ErrPasswordIsEmail  = errors.New("password must not contain the email address")
+)
+
+// ValidatePassword kiểm tra mật khẩu theo chính sách chung: 8–72 byte, có cả
+// chữ và số, không chứa phần tên của email.
+func ValidatePassword(password, email string) error {
+	if len([]rune(password)) < MinPasswordLength {
+		return ErrPasswordTooShort
+	}
+	if len(password) > MaxPasswordLength {
+		return ErrPasswordTooLong
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return ErrPasswordTooWeak
+	}
+
+	if local, _, ok := strings.Cut(strings.ToLower(email), "@"); ok && len(local) >= 3 &&
+		strings.Contains(strings.ToLower(password), local) {
+		return ErrPasswordIsEmail
+	}
+	return nil
+}
+
+// HashPassword băm mật khẩu bằng bcrypt.
+func HashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	return string(hashed), err
+}
+
+// CheckPassword so sánh mật khẩu với hash đã lưu.
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,84 @@
+package auth
+
+// Permission là một quyền thao tác trên API, dạng "<tài nguyên>:<hành động>".
+type Permission string
+
+const (
+	PermGamesWrite       Permission = "games:write"        // tạo, xoá, import game
+	PermUsersAdmin       Permission = "users:admin"        // xem, tạo, sửa, xoá, đổi role user khác
+	PermWalletAdjust     Permission = "wallet:adjust"      // sửa coin_balance, đối soát ví
+	PermRentalPlansWrite Permission = "rental_plans:write" // cấu hình gói thuê
+	PermPricingWrite     Permission = "pricing:write"      // xem trước và áp dụng bảng giá
+	PermWalletSpend      Permission = "wallet:spend"       // mua, thuê, gia hạn, hoàn tiền, nạp coin
+)
+
+// Các role của user.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// rolePermissions ánh xạ role sang các quyền được cấp. Role không có trong
+// map (kể cả role rỗng của token cũ) không có quyền nào.
+var rolePermissions = map[string][]Permission{
+	RoleUser: {PermWalletSpend},
+	RoleAdmin: {
+		PermWalletSpend,
+		PermGamesWrite,
+		PermUsersAdmin,
+		PermWalletAdjust,
+		PermRentalPlansWrite,
+		PermPricingWrite,
+	},
+}
+
+// ValidRole kiểm tra role có được định nghĩa hay không.
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission kiểm tra role có quyền p hay không.
+func HasPermission(role string, p Permission) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions trả về danh sách quyền của role.
+func Permissions(role string) []Permission {
+	return append([]Permission{}, rolePermissions[role]...)
+}
+
+// adminPermissions là các quyền chỉ dành cho khu quản trị.
+var adminPermissions = map[Permission]bool{
+	PermGamesWrite:       true,
+	PermUsersAdmin:       true,
+	PermWalletAdjust:     true,
+	PermRentalPlansWrite: true,
+	PermPricingWrite:     true,
+}
+
+// HasAdminScope kiểm tra API key có được cấp ít nhất một quyền quản trị.
+func HasAdminScope(scopes []Permission) bool {
+	for _, s := range scopes {
+		if adminPermissions[s] {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeIncludes kiểm tra danh sách scope của một API key có chứa quyền p.
+// Quyền thực tế của key là giao của scope với quyền của role hiện tại.
+func ScopeIncludes(scopes []Permission, p Permission) bool {
+	for _, s := range scopes {
+		if s == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package auth
+
+import "testing"
+
+func TestRolePermissionMatrix(t *testing.T) {
+	all := []Permission{PermWalletSpend, PermGamesWrite, PermUsersAdmin, PermWalletAdjust, PermRentalPlansWrite, PermPricingWrite}
+	want := map[string][]Permission{
+		RoleUser:  {PermWalletSpend},
+		RoleAdmin: all,
+		"":        {},
+		"root":    {},
+	}
+	for role, granted := range want {
+		has := map[Permission]bool{}
+		for _, p := range granted {
+			has[p] = true
+		}
+		for _, p := range all {
+			t.Run(role+"/"+string(p), func(t *testing.T) {
+				if got := HasPermission(role, p); got != has[p] {
+					t.Errorf("HasPermission(%q, %s) = %v, want %v", role, p, got, has[p])
+				}
+			})
+		}
+		if got := len(Permissions(role)); got != len(granted) {
+			t.Errorf("Permissions(%q) has %d entries, want %d", role, got, len(granted))
+		}
+	}
+}
+
+func TestValidRole(t *testing.T) {
+	for role, want := range map[string]bool{RoleUser: true, RoleAdmin: true, "": false, "Admin": false, "superuser": false} {
+		if got := ValidRole(role); got != want {
+			t.Errorf("ValidRole(%q) = %v, want %v", role, got, want)
+		}
+	}
+}
+
+func TestPermissionsReturnsCopy(t *testing.T) {
+	perms := Permissions(RoleAdmin)
+	perms[0] = "hacked"
+	if Permissions(RoleAdmin)[0] == "hacked" {
+		t.Fatal("Permissions exposes the role table")
+	}
+}
+
+func TestHasAdminScope(t *testing.T) {
+	tests := []struct {
+		scopes []Permission
+		want   bool
+	}{
+		{nil, false},
+		{[]Permission{PermWalletSpend}, false},
+		{[]Permission{PermWalletSpend, PermGamesWrite}, true},
+		{[]Permission{PermPricingWrite}, true},
+		{[]Permission{"unknown:scope"}, false},
+	}
+	for _, tt := range tests {
+		if got := HasAdminScope(tt.scopes); got != tt.want {
+			t.Errorf("HasAdminScope(%v) = %v, want %v", tt.scopes, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// sessionTouchInterval: last_seen_at chỉ được ghi tối đa mỗi phút một lần.
+const sessionTouchInterval = time.Minute
+
+var ErrSessionNotFound = errors.New("session not found")
+
+func sessions() *mongo.Collection {
+	return config.DB.Collection("sessions")
+}
+
+// SessionInfo mô tả thiết bị đăng nhập. DeviceID là định danh thiết bị do
+// client gửi (header X-Device-ID); thiếu thì nhận diện thiết bị theo user agent.
+type SessionInfo struct {
+	DeviceName string
+	DeviceID   string
+	IP         string
+	UserAgent  string
+}
+
+func (info SessionInfo) deviceHash() string {
+	if info.DeviceID != "" {
+		return HashToken("id:" + info.DeviceID)
+	}
+	return HashToken("ua:" + info.UserAgent)
+}
+
+// createSession ghi phiên mới và cho biết đây có phải thiết bị lạ không:
+// user đã từng đăng nhập nhưng chưa từng từ thiết bị này.
+func createSession(ctx context.Context, user models.User, familyID primitive.ObjectID, info SessionInfo) (bool, error) {
+	hash := info.deviceHash()
+	previous, err := sessions().CountDocuments(ctx, bson.M{"user_id": user.ID}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	known, err := sessions().CountDocuments(ctx, bson.M{"user_id": user.ID, "device_hash": hash}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	name := strings.TrimSpace(info.DeviceName)
+	if name == "" {
+		name = DeviceNameFromUserAgent(info.UserAgent)
+	}
+	now := time.Now()
+	_, err = sessions().InsertOne(ctx, models.Session{
+		ID:         familyID,
+		UserID:     user.ID,
+		DeviceName: name,
+		DeviceHash: hash,
+		IP:         info.IP,
+		UserAgent:  info.UserAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		LastSeenIP: info.IP,
+		ExpiresAt:  now.Add(refreshTokenTTL),
+	})
+	if err != nil {
+		return false, err
+	}
+	return previous > 0 && known == 0, nil
+}
+
+// checkSession trả về ErrTokenRevoked nếu phiên đã bị thu hồi và cập nhật
+// last_seen. Token cấp trước khi có bảng sessions không có bản ghi và vẫn
+// được chấp nhận tới khi hết hạn.
+func checkSession(ctx context.Context, sessionID, ip string) error {
+	id, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return nil
+	}
+	var s models.Session
+	err = sessions().FindOne(ctx, bson.M{"_id": id},
+		options.FindOne().SetProjection(bson.M{"revoked_at": 1, "last_seen_at": 1}),
+	).Decode(&s)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if s.RevokedAt != nil {
+		return ErrTokenRevoked
+	}
+
+	now := time.Now()
+	if now.Sub(s.LastSeenAt) >= sessionTouchInterval {
+		set := bson.M{"last_seen_at": now}
+		if ip != "" {
+			set["last_seen_ip"] = ip
+		}
+		sessions().UpdateOne(ctx, bson.M{"_id": id, "last_seen_at": bson.M{"$lte": now.Add(-sessionTouchInterval)}}, bson.M{"$set": set})
+	}
+	return nil
+}
+
+// ListSessions trả về các phiên còn hiệu lực của user, dùng gần nhất trước.
+func ListSessions(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error) {
+	cursor, err := sessions().Find(ctx,
+		bson.M{"user_id": userID, "revoked_at": nil, "expires_at": bson.M{"$gt": time.Now()}},
+		options.Find().SetSort(bson.M{"last_seen_at": -1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	list := []models.Session{}
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// RevokeSession thu hồi một phiên của user cùng mọi token của nó.
+func RevokeSession(ctx context.Context, userID, sessionID primitive.ObjectID, reason string) error {
+	count, err := sessions().CountDocuments(ctx, bson.M{"_id": sessionID, "user_id": userID, "revoked_at": nil})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrSessionNotFound
+	}
+	return RevokeFamily(ctx, sessionID, reason)
+}
+
+// DeviceNameFromUserAgent đặt tên dễ đọc cho thiết bị, ví dụ "Chrome on Windows".
+func DeviceNameFromUserAgent(ua string) string {
+	if ua == "" {
+		return "Unknown device"
+	}
+	lower := strings.ToLower(ua)
+	pick := func(candidates [][2]string) string {
+		for _, c := range candidates {
+			if strings.Contains(lower, c[0]) {
+				return c[1]
+			}
+		}
+		return ""
+	}
+
+	// Thứ tự quan trọng: UA của Edge/Opera chứa "chrome", UA của Chrome chứa "safari".
+	client := pick([][2]string{
+		{"edg/", "Edge"}, {"opr/", "Opera"}, {"firefox/", "Firefox"}, {"chrome/", "Chrome"},
+		{"safari/", "Safari"}, {"curl/", "curl"}, {"postmanruntime/", "Postman"},
+		{"go-http-client/", "Go client"}, {"python-requests/", "Python client"}, {"okhttp/", "Android app"},
+	})
+	platform := pick([][2]string{
+		{"windows", "Windows"}, {"iphone", "iOS"}, {"ipad", "iPadOS"}, {"android", "Android"},
+		{"mac os x", "macOS"}, {"cros", "ChromeOS"}, {"linux", "Linux"},
+	})
+
+	switch {
+	case client != "" && platform != "":
+		return client + " on " + platform
+	case client != "":
+		return client
+	case platform != "":
+		return platform + " device"
+	}
+	if len(ua) > 60 {
+		ua = ua[:60]
+	}
+	return ua
+}
@@ -0,0 +1,349 @@
+// Package auth phát hành và thu hồi token đăng nhập.
+//
+// Access token là JWT HS256 ngắn hạn có jti; refresh token là chuỗi ngẫu nhiên
+// chỉ được lưu dưới dạng hash trong collection refresh_tokens. Mỗi lần refresh
+// token cũ bị xoay vòng (rotate); nếu một token đã xoay vòng bị dùng lại thì
+// toàn bộ family của nó bị thu hồi.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const TokenTypeAccess = "access"
+
+// Được gán từ config lúc khởi động qua Configure.
+var (
+	jwtSecret       []byte
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// Configure nạp secret ký JWT và thời hạn token. Phải được gọi trước khi
+// phát hành hoặc xác thực token.
+func Configure(cfg config.AuthConfig) {
+	jwtSecret = cfg.JWTSecret
+	accessTokenTTL = cfg.AccessTokenTTL
+	refreshTokenTTL = cfg.RefreshTokenTTL
+}
+
+var (
+	ErrInvalidToken   = errors.New("invalid token")
+	ErrTokenRevoked   = errors.New("token revoked")
+	ErrInvalidRefresh = errors.New("invalid refresh token")
+	ErrRefreshReuse   = errors.New("refresh token reuse detected")
+)
+
+// Claims là nội dung của access token. Các key user_id/email/role giữ nguyên
+// như token cũ để client không phải đổi.
+type Claims struct {
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	Type      string `json:"typ"`
+	SessionID string `json:"sid,omitempty"` // family của refresh token
+	// MFAEnroll: token chỉ dùng được cho các route bật 2FA (admin chưa bật 2FA).
+	MFAEnroll bool `json:"mfa_enroll,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// TokenPair là kết quả của đăng nhập hoặc refresh.
+type TokenPair struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresIn    int       `json:"expires_in"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	SessionID    string    `json:"session_id"`
+	// NewDevice: phiên mới được tạo từ thiết bị user chưa từng đăng nhập.
+	NewDevice bool `json:"-"`
+}
+
+func refreshTokens() *mongo.Collection {
+	return config.DB.Collection("refresh_tokens")
+}
+
+func revokedTokens() *mongo.Collection {
+	return config.DB.Collection("revoked_tokens")
+}
+
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashToken trả về sha256 hex của token, dùng để lưu token bí mật.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// signAccessToken ký access token cho user trong session familyID.
+func signAccessToken(user models.User, familyID primitive.ObjectID) (string, string, time.Time, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	now := time.Now()
+	exp := now.Add(accessTokenTTL)
+	claims := Claims{
+		UserID:    user.ID.Hex(),
+		Email:     user.Email,
+		Role:      user.Role,
+		Type:      TokenTypeAccess,
+		SessionID: familyID.Hex(),
+		MFAEnroll: MFAEnrollmentRequired(user),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   user.ID.Hex(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+	return signed, jti, exp, err
+}
+
+// ParseAccessToken xác thực chữ ký, hạn dùng và loại của access token.
+// Không kiểm tra thu hồi; dùng IsRevoked cho việc đó.
+func ParseAccessToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	if claims.Type != TokenTypeAccess || claims.ID == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// IsRevoked kiểm tra access token có bị thu hồi theo jti hay không.
+func IsRevoked(ctx context.Context, jti string) (bool, error) {
+	count, err := revokedTokens().CountDocuments(ctx, bson.M{"_id": jti})
+	return count > 0, err
+}
+
+// Authenticate xác thực access token, kiểm tra token và phiên của nó chưa bị
+// thu hồi, đồng thời ghi nhận phiên vừa được dùng từ ip.
+func Authenticate(ctx context.Context, tokenString, ip string) (*Claims, error) {
+	claims, err := ParseAccessToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	if err := checkSession(ctx, claims.SessionID, ip); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// IssueTokenPair tạo phiên đăng nhập mới trên thiết bị info cùng access
+// token và refresh token của phiên đó.
+func IssueTokenPair(ctx context.Context, user models.User, info SessionInfo) (TokenPair, error) {
+	familyID := primitive.NewObjectID()
+	newDevice, err := createSession(ctx, user, familyID, info)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	tokens, err := issue(ctx, user, familyID)
+	tokens.NewDevice = newDevice
+	return tokens, err
+}
+
+func issue(ctx context.Context, user models.User, familyID primitive.ObjectID) (TokenPair, error) {
+	access, jti, accessExp, err := signAccessToken(user, familyID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refresh, err := randomToken(32)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	now := time.Now()
+	record := models.RefreshToken{
+		ID:              primitive.NewObjectID(),
+		UserID:          user.ID,
+		FamilyID:        familyID,
+		TokenHash:       HashToken(refresh),
+		AccessJTI:       jti,
+		AccessExpiresAt: accessExp,
+		ExpiresAt:       now.Add(refreshTokenTTL),
+		CreatedAt:       now,
+	}
+	if _, err := refreshTokens().InsertOne(ctx, record); err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTokenTTL.Seconds()),
+		ExpiresAt:    accessExp,
+		SessionID:    familyID.Hex(),
+	}, nil
+}
+
+// Rotate đổi refresh token lấy cặp token mới trong cùng family. Nếu token
+// đã bị xoay vòng hoặc thu hồi trước đó, cả family bị thu hồi và trả về
+// ErrRefreshReuse.
+func Rotate(ctx context.Context, refreshToken string) (TokenPair, error) {
+	var record models.RefreshToken
+	err := refreshTokens().FindOne(ctx, bson.M{"token_hash": HashToken(refreshToken)}).Decode(&record)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return TokenPair{}, ErrInvalidRefresh
+	}
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	if record.RotatedAt != nil || record.RevokedAt != nil {
+		if err := RevokeFamily(ctx, record.FamilyID, "reuse_detected"); err != nil {
+			return TokenPair{}, err
+		}
+		return TokenPair{}, ErrRefreshReuse
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return TokenPair{}, ErrInvalidRefresh
+	}
+
+	var user models.User
+	if err := config.DB.Collection("users").FindOne(ctx, bson.M{"_id": record.UserID}).Decode(&user); err != nil {
+		return TokenPair{}, ErrInvalidRefresh
+	}
+
+	nextID := primitive.NewObjectID()
+	res, err := refreshTokens().UpdateOne(ctx,
+		bson.M{"_id": record.ID, "rotated_at": nil, "revoked_at": nil},
+		bson.M{"$set": bson.M{"rotated_at": time.Now(), "replaced_by": nextID}},
+	)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if res.ModifiedCount == 0 {
+		// Hai request refresh cùng lúc với một token: coi như bị dùng lại.
+		if err := RevokeFamily(ctx, record.FamilyID, "reuse_detected"); err != nil {
+			return TokenPair{}, err
+		}
+		return TokenPair{}, ErrRefreshReuse
+	}
+
+	tokens, err := issue(ctx, user, record.FamilyID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	// Phiên còn sống chừng nào refresh token mới nhất còn hạn.
+	_, err = sessions().UpdateOne(ctx, bson.M{"_id": record.FamilyID}, bson.M{"$set": bson.M{
+		"expires_at":   time.Now().Add(refreshTokenTTL),
+		"last_seen_at": time.Now(),
+	}})
+	return tokens, err
+}
+
+// RevokeFamily thu hồi mọi refresh token trong family và các access token
+// còn hạn đã phát hành cùng chúng.
+func RevokeFamily(ctx context.Context, familyID primitive.ObjectID, reason string) error {
+	if err := revokeSessions(ctx, bson.M{"_id": familyID}, reason); err != nil {
+		return err
+	}
+	return revokeWhere(ctx, bson.M{"family_id": familyID}, reason)
+}
+
+// RevokeAllForUser thu hồi mọi phiên đăng nhập và mọi API key của user.
+func RevokeAllForUser(ctx context.Context, userID primitive.ObjectID, reason string) error {
+	if err := revokeSessions(ctx, bson.M{"user_id": userID}, reason); err != nil {
+		return err
+	}
+	if err := revokeAPIKeysForUser(ctx, userID, reason); err != nil {
+		return err
+	}
+	return revokeWhere(ctx, bson.M{"user_id": userID}, reason)
+}
+
+// revokeSessions đánh dấu phiên bị thu hồi trước khi thu hồi token để
+// middleware từ chối ngay cả token chưa kịp vào revoked_tokens.
+func revokeSessions(ctx context.Context, filter bson.M, reason string) error {
+	filter["revoked_at"] = nil
+	_, err := sessions().UpdateMany(ctx, filter, bson.M{"$set": bson.M{
+		"revoked_at":    time.Now(),
+		"revoke_reason": reason,
+	}})
+	return err
+}
+
+func revokeWhere(ctx context.Context, filter bson.M, reason string) error {
+	now := time.Now()
+
+	liveFilter := bson.M{"access_expires_at": bson.M{"$gt": now}}
+	for k, v := range filter {
+		liveFilter[k] = v
+	}
+	cursor, err := refreshTokens().Find(ctx, liveFilter)
+	if err != nil {
+		return err
+	}
+	var live []models.RefreshToken
+	if err := cursor.All(ctx, &live); err != nil {
+		return err
+	}
+	for _, t := range live {
+		if err := RevokeAccessToken(ctx, t.AccessJTI, t.UserID, t.AccessExpiresAt, reason); err != nil {
+			return err
+		}
+	}
+
+	activeFilter := bson.M{"revoked_at": nil}
+	for k, v := range filter {
+		activeFilter[k] = v
+	}
+	_, err = refreshTokens().UpdateMany(ctx, activeFilter, bson.M{"$set": bson.M{
+		"revoked_at":    now,
+		"revoke_reason": reason,
+	}})
+	return err
+}
+
+// RevokeAccessToken đưa jti vào danh sách thu hồi tới khi token hết hạn.
+func RevokeAccessToken(ctx context.Context, jti string, userID primitive.ObjectID, expiresAt time.Time, reason string) error {
+	if jti == "" {
+		return nil
+	}
+	_, err := revokedTokens().InsertOne(ctx, models.RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+		RevokedAt: time.Now(),
+		Reason:    reason,
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
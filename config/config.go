@@ -0,0 +1,428 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config là toàn bộ cấu hình của server, được nạp một lần lúc khởi động
+// rồi truyền xuống các package cần dùng.
+type Config struct {
+	Server      ServerConfig
+	Mongo       MongoConfig
+	Auth        AuthConfig
+	RAWG        RAWGConfig
+	Payment     PaymentConfig
+	Rental      RentalConfig
+	Mail        MailConfig
+	OIDC        OIDCConfig
+	Idempotency IdempotencyConfig
+}
+
+// Môi trường chạy (APP_ENV). Các tính năng chỉ dành cho phát triển như fake
+// payment gateway bị cấm ở production.
+const (
+	EnvProduction  = "production"
+	EnvDevelopment = "development"
+	EnvTest        = "test"
+)
+
+// Cổng thanh toán (PAYMENT_PROVIDER).
+const (
+	PaymentHosted = "hosted"
+	PaymentFake   = "fake"
+)
+
+type ServerConfig struct {
+	Env             string
+	Port            string
+	PublicBaseURL   string
+	CORSOrigins     []string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	ShutdownTimeout time.Duration
+}
+
+type MongoConfig struct {
+	URI            string
+	Database       string
+	ConnectTimeout time.Duration
+}
+
+type AuthConfig struct {
+	JWTSecret       []byte
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+type RAWGConfig struct {
+	APIKey string
+	// EnrichConcurrency là số worker enrich chạy song song; EnrichBatch là số
+	// game tối đa mỗi lượt enrich.
+	EnrichConcurrency int
+	EnrichBatch       int
+}
+
+// PaymentConfig cấu hình cổng thanh toán. Provider "fake" tự ký webhook
+// thành công nên chỉ được bật khi APP_ENV là development hoặc test.
+type PaymentConfig struct {
+	Provider      string
+	WebhookSecret string
+	// CheckoutURL là trang thanh toán của cổng hosted.
+	CheckoutURL       string
+	MaxRechargeAmount int
+}
+
+// FakeGatewayAllowed cho biết môi trường có được dùng fake gateway không.
+func (cfg *Config) FakeGatewayAllowed() bool {
+	return cfg.Server.Env == EnvDevelopment || cfg.Server.Env == EnvTest
+}
+
+// MailConfig cấu hình SMTP. SMTPHost rỗng nghĩa là chỉ ghi email ra log.
+type MailConfig struct {
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	From         string
+}
+
+// OIDCConfig cấu hình đăng nhập qua một OpenID Connect provider.
+// IssuerURL rỗng nghĩa là tắt tính năng này.
+type OIDCConfig struct {
+	Provider     string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// IdempotencyConfig cấu hình Idempotency-Key. TTL là thời gian giữ response
+// đã lưu; Lease là thời gian một request đang xử lý giữ key, được gia hạn
+// liên tục khi handler còn chạy. Process chết giữa chừng thì hết lease request
+// khác được nhận key.
+type IdempotencyConfig struct {
+	TTL   time.Duration
+	Lease time.Duration
+}
+
+type RentalConfig struct {
+	SweepInterval    time.Duration
+	SweepBatch       int
+	RefundWindowDays int
+	RentToOwnPercent int
+	RentToOwnDays    int
+}
+
+// minSecretLength là độ dài tối thiểu của JWT secret (HS256 cần ít nhất 256 bit).
+const minSecretLength = 32
+
+// Default trả về cấu hình mặc định, chưa có secret.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Env:             EnvProduction,
+			Port:            "8080",
+			CORSOrigins:     []string{"*"},
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			ShutdownTimeout: 15 * time.Second,
+		},
+		Mongo: MongoConfig{
+			URI:            "mongodb://localhost:27017",
+			Database:       "game_library",
+			ConnectTimeout: 10 * time.Second,
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+		},
+		Payment: PaymentConfig{
+			Provider:          PaymentHosted,
+			MaxRechargeAmount: 100000,
+		},
+		RAWG: RAWGConfig{
+			EnrichConcurrency: 4,
+			EnrichBatch:       100,
+		},
+		Rental: RentalConfig{
+			SweepInterval:    time.Minute,
+			SweepBatch:       100,
+			RefundWindowDays: 14,
+			RentToOwnPercent: 50,
+			RentToOwnDays:    30,
+		},
+		Mail: MailConfig{
+			SMTPPort: 1025,
+			From:     "Game Library <no-reply@game-lib.local>",
+		},
+		OIDC: OIDCConfig{
+			Provider: "oidc",
+			Scopes:   []string{"openid", "email", "profile"},
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:   24 * time.Hour,
+			Lease: 2 * time.Minute,
+		},
+	}
+}
+
+// Load nạp cấu hình theo thứ tự ưu tiên: flag > biến môi trường > file .env
+// > giá trị mặc định, rồi kiểm tra tính hợp lệ. args là các tham số dòng lệnh
+// (không gồm tên chương trình).
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	envFile := fs.String("env-file", ".env", "file .env chứa biến môi trường")
+	port := fs.String("port", "", "cổng HTTP (ghi đè PORT)")
+	mongoURI := fs.String("mongo-uri", "", "MongoDB URI (ghi đè MONGODB_URI)")
+	mongoDB := fs.String("mongo-db", "", "tên database (ghi đè MONGODB_DATABASE)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	envFileSet := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "env-file" {
+			envFileSet = true
+		}
+	})
+	if err := loadEnvFile(*envFile); err != nil && (envFileSet || !errors.Is(err, os.ErrNotExist)) {
+		return nil, fmt.Errorf("config: đọc %s: %w", *envFile, err)
+	}
+
+	cfg := Default()
+	var errs []error
+	str := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok && v != "" {
+			*dst = v
+		}
+	}
+	num := func(name string, dst *int) {
+		if v, ok := os.LookupEnv(name); ok && v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				errs = append(errs, fmt.Errorf("%s: số không hợp lệ %q", name, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	dur := func(name string, dst *time.Duration) {
+		if v, ok := os.LookupEnv(name); ok && v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				errs = append(errs, fmt.Errorf("%s: thời lượng không hợp lệ %q", name, v))
+				return
+			}
+			*dst = d
+		}
+	}
+
+	str("APP_ENV", &cfg.Server.Env)
+	str("PORT", &cfg.Server.Port)
+	str("PUBLIC_BASE_URL", &cfg.Server.PublicBaseURL)
+	if v := os.Getenv("CORS_ALLOWED_ORIGINS"); v != "" {
+		cfg.Server.CORSOrigins = splitList(v)
+	}
+	dur("HTTP_READ_TIMEOUT", &cfg.Server.ReadTimeout)
+	dur("HTTP_WRITE_TIMEOUT", &cfg.Server.WriteTimeout)
+	dur("SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout)
+
+	str("MONGODB_URI", &cfg.Mongo.URI)
+	str("MONGODB_DATABASE", &cfg.Mongo.Database)
+	dur("MONGODB_CONNECT_TIMEOUT", &cfg.Mongo.ConnectTimeout)
+
+	var jwtSecret string
+	str("JWT_SECRET", &jwtSecret)
+	cfg.Auth.JWTSecret = []byte(jwtSecret)
+	dur("ACCESS_TOKEN_TTL", &cfg.Auth.AccessTokenTTL)
+	dur("REFRESH_TOKEN_TTL", &cfg.Auth.RefreshTokenTTL)
+
+	str("RAWG_API_KEY", &cfg.RAWG.APIKey)
+	num("RAWG_ENRICH_CONCURRENCY", &cfg.RAWG.EnrichConcurrency)
+	num("RAWG_ENRICH_BATCH", &cfg.RAWG.EnrichBatch)
+	str("PAYMENT_PROVIDER", &cfg.Payment.Provider)
+	str("PAYMENT_WEBHOOK_SECRET", &cfg.Payment.WebhookSecret)
+	str("PAYMENT_CHECKOUT_URL", &cfg.Payment.CheckoutURL)
+	num("PAYMENT_MAX_RECHARGE", &cfg.Payment.MaxRechargeAmount)
+
+	dur("RENTAL_SWEEP_INTERVAL", &cfg.Rental.SweepInterval)
+	num("RENTAL_SWEEP_BATCH", &cfg.Rental.SweepBatch)
+	num("REFUND_WINDOW_DAYS", &cfg.Rental.RefundWindowDays)
+	num("RENT_TO_OWN_PERCENT", &cfg.Rental.RentToOwnPercent)
+	num("RENT_TO_OWN_DAYS", &cfg.Rental.RentToOwnDays)
+
+	str("SMTP_HOST", &cfg.Mail.SMTPHost)
+	num("SMTP_PORT", &cfg.Mail.SMTPPort)
+	str("SMTP_USERNAME", &cfg.Mail.SMTPUsername)
+	str("SMTP_PASSWORD", &cfg.Mail.SMTPPassword)
+	str("MAIL_FROM", &cfg.Mail.From)
+
+	str("OIDC_PROVIDER", &cfg.OIDC.Provider)
+	str("OIDC_ISSUER_URL", &cfg.OIDC.IssuerURL)
+	str("OIDC_CLIENT_ID", &cfg.OIDC.ClientID)
+	str("OIDC_CLIENT_SECRET", &cfg.OIDC.ClientSecret)
+	str("OIDC_REDIRECT_URL", &cfg.OIDC.RedirectURL)
+	if v := os.Getenv("OIDC_SCOPES"); v != "" {
+		cfg.OIDC.Scopes = splitList(v)
+	}
+
+	dur("IDEMPOTENCY_TTL", &cfg.Idempotency.TTL)
+	dur("IDEMPOTENCY_LEASE", &cfg.Idempotency.Lease)
+
+	if *port != "" {
+		cfg.Server.Port = *port
+	}
+	if cfg.Server.PublicBaseURL == "" {
+		cfg.Server.PublicBaseURL = "http://localhost:" + cfg.Server.Port
+	}
+	cfg.Server.PublicBaseURL = strings.TrimRight(cfg.Server.PublicBaseURL, "/")
+	if cfg.OIDC.IssuerURL != "" && cfg.OIDC.RedirectURL == "" {
+		cfg.OIDC.RedirectURL = cfg.Server.PublicBaseURL + "/auth/oidc/" + cfg.OIDC.Provider + "/callback"
+	}
+	if *mongoURI != "" {
+		cfg.Mongo.URI = *mongoURI
+	}
+	if *mongoDB != "" {
+		cfg.Mongo.Database = *mongoDB
+	}
+
+	errs = append(errs, cfg.validate()...)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("config không hợp lệ: %w", errors.Join(errs...))
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) validate() []error {
+	var errs []error
+	if len(cfg.Auth.JWTSecret) == 0 {
+		errs = append(errs, errors.New("JWT_SECRET chưa được cấu hình"))
+	} else if len(cfg.Auth.JWTSecret) < minSecretLength {
+		errs = append(errs, fmt.Errorf("JWT_SECRET phải dài ít nhất %d ký tự", minSecretLength))
+	}
+	if cfg.Payment.WebhookSecret == "" {
+		errs = append(errs, errors.New("PAYMENT_WEBHOOK_SECRET chưa được cấu hình"))
+	}
+	switch cfg.Server.Env {
+	case EnvProduction, EnvDevelopment, EnvTest:
+	default:
+		errs = append(errs, fmt.Errorf("APP_ENV không hợp lệ %q", cfg.Server.Env))
+	}
+	switch cfg.Payment.Provider {
+	case PaymentHosted:
+		if cfg.Payment.CheckoutURL == "" {
+			errs = append(errs, errors.New("PAYMENT_CHECKOUT_URL chưa được cấu hình"))
+		}
+	case PaymentFake:
+		if !cfg.FakeGatewayAllowed() {
+			errs = append(errs, errors.New("PAYMENT_PROVIDER=fake chỉ được dùng khi APP_ENV là development hoặc test"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("PAYMENT_PROVIDER không hợp lệ %q", cfg.Payment.Provider))
+	}
+	if cfg.Payment.MaxRechargeAmount < 100 {
+		errs = append(errs, errors.New("PAYMENT_MAX_RECHARGE phải ít nhất 100"))
+	}
+	if p, err := strconv.Atoi(cfg.Server.Port); err != nil || p <= 0 || p > 65535 {
+		errs = append(errs, fmt.Errorf("PORT không hợp lệ %q", cfg.Server.Port))
+	}
+	if cfg.Mongo.Database == "" {
+		errs = append(errs, errors.New("MONGODB_DATABASE không được để trống"))
+	}
+	if len(cfg.Server.CORSOrigins) == 0 {
+		errs = append(errs, errors.New("CORS_ALLOWED_ORIGINS không được để trống"))
+	}
+	if cfg.Auth.RefreshTokenTTL <= cfg.Auth.AccessTokenTTL {
+		errs = append(errs, errors.New("REFRESH_TOKEN_TTL phải lớn hơn ACCESS_TOKEN_TTL"))
+	}
+	if cfg.RAWG.EnrichConcurrency == 0 || cfg.RAWG.EnrichBatch == 0 {
+		errs = append(errs, errors.New("RAWG_ENRICH_CONCURRENCY và RAWG_ENRICH_BATCH phải lớn hơn 0"))
+	}
+	if cfg.Rental.SweepBatch == 0 {
+		errs = append(errs, errors.New("RENTAL_SWEEP_BATCH phải lớn hơn 0"))
+	}
+	if cfg.Mail.SMTPHost != "" && (cfg.Mail.SMTPPort <= 0 || cfg.Mail.SMTPPort > 65535) {
+		errs = append(errs, fmt.Errorf("SMTP_PORT không hợp lệ %d", cfg.Mail.SMTPPort))
+	}
+	if cfg.OIDC.IssuerURL != "" && cfg.OIDC.ClientID == "" {
+		errs = append(errs, errors.New("OIDC_CLIENT_ID chưa được cấu hình"))
+	}
+	if cfg.Idempotency.Lease <= 0 {
+		errs = append(errs, errors.New("IDEMPOTENCY_LEASE phải lớn hơn 0"))
+	} else if cfg.Idempotency.Lease < cfg.Server.WriteTimeout {
+		errs = append(errs, errors.New("IDEMPOTENCY_LEASE không được nhỏ hơn HTTP_WRITE_TIMEOUT"))
+	}
+	if cfg.Idempotency.TTL <= cfg.Idempotency.Lease {
+		errs = append(errs, errors.New("IDEMPOTENCY_TTL phải lớn hơn IDEMPOTENCY_LEASE"))
+	}
+	if cfg.Rental.RentToOwnPercent > 100 {
+		errs = append(errs, errors.New("RENT_TO_OWN_PERCENT không được vượt quá 100"))
+	}
+	return errs
+}
+
+// loadEnvFile đọc file dạng KEY=VALUE và gán vào biến môi trường. Biến đã
+// có sẵn trong môi trường không bị ghi đè. Hỗ trợ dòng trống, comment "#",
+// tiền tố "export " và giá trị trong dấu nháy đơn hoặc kép.
+func loadEnvFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return fmt.Errorf("dòng %d: thiếu dấu '='", lineNo)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return fmt.Errorf("dòng %d: %w", lineNo, err)
+			}
+			value = unquoted
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+
+		if _, exists := os.LookupEnv(key); !exists {
+			os.Setenv(key, value)
+		}
+	}
+	return scanner.Err()
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
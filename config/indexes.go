@@ -0,0 +1,160 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-mvc-demo/models"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EmailCollation so sánh email không phân biệt hoa thường. Mọi truy vấn theo
+// email phải dùng cùng collation với unique index để dùng được index.
+var EmailCollation = &options.Collation{Locale: "en", Strength: 2}
+
+// indexes liệt kê các index cần có theo từng collection.
+var indexes = map[string][]mongo.IndexModel{
+	"ledger_entries": {
+		{Keys: bson.D{{Key: "account", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "transaction_id", Value: 1}}},
+	},
+	"recharges": {
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{
+			Keys: bson.D{{Key: "provider", Value: 1}, {Key: "reference", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"reference": bson.M{"$exists": true}}),
+		},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expires_at", Value: 1}}},
+	},
+	"purchases": {
+		// Mỗi user chỉ sở hữu một purchase completed cho mỗi game.
+		// Dữ liệu cũ bị trùng cần chạy: server migrate dedupe-entitlements
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "game_id", Value: 1}},
+			Options: options.Index().SetName("uniq_completed_purchase").SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": models.PurchaseCompleted}),
+		},
+	},
+	"rentals": {
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expire_at", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "game_id", Value: 1}, {Key: "rent_at", Value: -1}}},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "game_id", Value: 1}},
+			Options: options.Index().SetName("uniq_active_rental").SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": models.RentalActive}),
+		},
+	},
+	"rental_plans": {
+		{Keys: bson.D{{Key: "scope", Value: 1}, {Key: "code", Value: 1}, {Key: "genre", Value: 1}, {Key: "game_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	"events": {
+		{Keys: bson.D{{Key: "type", Value: 1}, {Key: "created_at", Value: -1}}},
+	},
+	"idempotency_keys": {
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+	"refresh_tokens": {
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "family_id", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "access_expires_at", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+	"users": {
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true).SetCollation(EmailCollation).SetName("uniq_email")},
+	},
+	"one_time_tokens": {
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "purpose", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "created_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(48 * 3600)},
+	},
+	"mfa_challenges": {
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+	"login_attempts": {
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+	"login_history": {
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "created_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(180 * 24 * 3600)},
+	},
+	"user_identities": {
+		{Keys: bson.D{{Key: "provider", Value: 1}, {Key: "subject", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	},
+	"oidc_states": {
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+	"games": {
+		// Game tạo tay có rawg_id = 0 nên chỉ unique với game từ RAWG.
+		// Dữ liệu cũ bị trùng cần chạy: server migrate dedupe-games
+		{
+			Keys: bson.D{{Key: "rawg_id", Value: 1}},
+			Options: options.Index().SetName("uniq_rawg_id").SetUnique(true).
+				SetPartialFilterExpression(bson.M{"rawg_id": bson.M{"$gt": 0}}),
+		},
+	},
+	"game_changes": {
+		{Keys: bson.D{{Key: "job_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "created_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(90 * 24 * 3600)},
+	},
+	"price_history": {
+		{Keys: bson.D{{Key: "game_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "batch_id", Value: 1}}},
+	},
+	"import_jobs": {
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}}},
+		// Mỗi lúc chỉ có một job queued/running.
+		{
+			Keys: bson.D{{Key: "active", Value: 1}},
+			Options: options.Index().SetName("uniq_active_import").SetUnique(true).
+				SetPartialFilterExpression(bson.M{"active": true}),
+		},
+	},
+	"sessions": {
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "device_hash", Value: 1}}},
+		// Giữ phiên đã hết hạn thêm 90 ngày để còn nhận ra thiết bị quen.
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(90 * 24 * 3600)},
+	},
+	"api_keys": {
+		{Keys: bson.D{{Key: "key_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	},
+	"revoked_tokens": {
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+}
+
+// requiredIndexes là các collection mà server không được chạy khi thiếu
+// index, kèm migration dọn dữ liệu cũ vi phạm index. Import catalog upsert
+// theo rawg_id nên thiếu uniq_rawg_id sẽ tạo ra game trùng.
+var requiredIndexes = map[string]string{
+	"games": "dedupe-games",
+}
+
+// EnsureIndexes tạo các index còn thiếu. Lỗi ở collection thường chỉ được
+// log lại để server vẫn khởi động được (ví dụ khi dữ liệu cũ vi phạm một
+// unique index); lỗi ở collection trong requiredIndexes được trả về.
+func EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var errs []error
+	for name, models := range indexes {
+		if _, err := DB.Collection(name).Indexes().CreateMany(ctx, models); err != nil {
+			if migration, ok := requiredIndexes[name]; ok {
+				errs = append(errs, fmt.Errorf("config: index cho %s: %w (dọn dữ liệu bằng: server migrate %s -apply)", name, err, migration))
+				continue
+			}
+			log.Printf("⚠️ Không tạo được index cho %s: %v", name, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -3,7 +3,6 @@ package config
 import (
 	"context"
 	"log"
-	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -11,14 +10,43 @@ import (
 
 var DB *mongo.Database
 
-func ConnectDB() {
-	clientOptions := options.Client().ApplyURI("mongodb://localhost:27017")
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func ConnectDB(cfg MongoConfig) {
+	clientOptions := options.Client().ApplyURI(cfg.URI)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
 	defer cancel()
 	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
 		log.Fatal(err)
 	}
-	DB = client.Database("game_library")
+	if err := client.Ping(ctx, nil); err != nil {
+		log.Fatal(err)
+	}
+	DB = client.Database(cfg.Database)
 	log.Println("✅ Đã kết nối MongoDB!")
 }
+
+// DisconnectDB đóng kết nối MongoDB khi server tắt.
+func DisconnectDB(ctx context.Context) {
+	if DB == nil {
+		return
+	}
+	if err := DB.Client().Disconnect(ctx); err != nil {
+		log.Printf("⚠️ Lỗi khi đóng kết nối MongoDB: %v", err)
+	}
+}
+
+// WithTransaction chạy fn trong một multi-document transaction.
+// Nếu fn trả về lỗi thì toàn bộ thay đổi sẽ bị rollback.
+// Lưu ý: MongoDB chỉ hỗ trợ transaction trên replica set hoặc sharded cluster.
+func WithTransaction(ctx context.Context, fn func(sessCtx mongo.SessionContext) error) error {
+	session, err := DB.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	return err
+}
@@ -0,0 +1,62 @@
+// Package mongotest nối test vào MongoDB thật cho các test cần transaction
+// hoặc truy vấn của driver. URI lấy từ MONGODB_TEST_URI và phải trỏ tới
+// replica set (transaction không chạy trên server standalone), ví dụ:
+//
+//	docker run -d -p 27017:27017 mongo:7 --replSet rs0
+//	docker exec <id> mongosh --eval 'rs.initiate()'
+//	MONGODB_TEST_URI='mongodb://localhost:27017/?replicaSet=rs0&directConnection=true' go test ./...
+//
+// Không có biến môi trường thì test được bỏ qua.
+package mongotest
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"go-mvc-demo/config"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnvURI là biến môi trường chứa URI của MongoDB dùng cho test.
+const EnvURI = "MONGODB_TEST_URI"
+
+// Setup gán config.DB vào một database tạm có đủ index, xoá database và
+// trả lại config.DB cũ khi test kết thúc.
+func Setup(tb testing.TB) *mongo.Database {
+	tb.Helper()
+	uri := os.Getenv(EnvURI)
+	if uri == "" {
+		tb.Skipf("%s is not set; skipping MongoDB integration test", EnvURI)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		tb.Fatalf("mongotest: connect: %v", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		tb.Fatalf("mongotest: ping: %v", err)
+	}
+
+	db := client.Database("gamelib_test_" + primitive.NewObjectID().Hex())
+	prev := config.DB
+	config.DB = db
+	tb.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		db.Drop(ctx)
+		client.Disconnect(ctx)
+		config.DB = prev
+	})
+
+	if err := config.EnsureIndexes(); err != nil {
+		tb.Fatalf("mongotest: %v", err)
+	}
+	return db
+}
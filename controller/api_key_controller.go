@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-mvc-demo/auth"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxAPIKeyLifetimeDays giới hạn expires_in_days khi tạo key.
+const maxAPIKeyLifetimeDays = 365
+
+// ListAPIKeys godoc
+// @Summary Danh sách API key của người dùng
+// @Description Gồm cả key đã thu hồi hoặc hết hạn. Không bao giờ trả lại key gốc.
+// @Tags Auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.APIKey
+// @Failure 400,500 {object} ErrorResponse
+// @Router /api/api-keys [get]
+func ListAPIKeys(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.MustGet("user_id").(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	keys, err := auth.ListAPIKeys(context.TODO(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot list API keys"})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// CreateAPIKey godoc
+// @Summary Tạo API key
+// @Description Key gốc chỉ hiển thị một lần trong response; gửi qua header X-API-Key. scopes là các quyền (vd. wallet:spend, games:write) trong số quyền của role hiện tại; key không có scope chỉ đọc được dữ liệu, cần wallet:spend để mua, thuê, hoàn tiền hoặc nạp coin. Key không gọi được các API quản lý tài khoản (/auth, /users, đổi mật khẩu, 2FA, API key, phiên đăng nhập). expires_in_days = 0 nghĩa là không hết hạn.
+// @Tags Auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body map[string]interface{} true "name, scopes, expires_in_days"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400,403,409 {object} ErrorResponse
+// @Router /api/api-keys [post]
+func CreateAPIKey(c *gin.Context) {
+	var input struct {
+		Name          string   `json:"name" binding:"required"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" || len(input.Name) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must be 1-100 characters"})
+		return
+	}
+	if input.ExpiresInDays < 0 || input.ExpiresInDays > maxAPIKeyLifetimeDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_days must be between 0 and 365"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	scopes := make([]auth.Permission, 0, len(input.Scopes))
+	for _, s := range input.Scopes {
+		scopes = append(scopes, auth.Permission(s))
+	}
+	var expiresAt *time.Time
+	if input.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, input.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	raw, key, err := auth.CreateAPIKey(context.TODO(), user, input.Name, scopes, expiresAt)
+	switch {
+	case errors.Is(err, auth.ErrScopeNotPermitted):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "allowed_scopes": auth.Permissions(user.Role)})
+		return
+	case errors.Is(err, auth.ErrTooManyAPIKeys):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"api_key": raw,
+		"key":     key,
+		"message": "Store this key now, it will not be shown again",
+	})
+}
+
+// RevokeAPIKey godoc
+// @Summary Thu hồi API key
+// @Tags Auth
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "API key ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400,404 {object} ErrorResponse
+// @Router /api/api-keys/{id} [delete]
+func RevokeAPIKey(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.MustGet("user_id").(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	keyID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	err = auth.RevokeAPIKey(context.TODO(), userID, keyID)
+	if errors.Is(err, auth.ErrAPIKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot revoke API key"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
@@ -2,19 +2,51 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"go-mvc-demo/auth"
 	"go-mvc-demo/config"
+	"go-mvc-demo/ledger"
 	"go-mvc-demo/models"
-
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"golang.org/x/crypto/bcrypt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var jwtSecret = []byte("SECRET_KEY")
+// signupBonus là số coin tặng cho tài khoản mới.
+const signupBonus = 1000
+
+// createUserWithBonus tạo user đã xác minh email với số dư 0 rồi cộng coin
+// khởi tạo qua ledger để khoản tặng này cũng có bút toán đi kèm. Dùng khi
+// admin tạo user; user tự đăng ký chỉ nhận coin sau khi xác minh email.
+func createUserWithBonus(ctx context.Context, user models.User) error {
+	now := time.Now()
+	user.CoinBalance = 0
+	user.EmailVerified = true
+	user.EmailVerifiedAt = &now
+	user.CreatedAt = now
+	return config.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		if _, err := config.DB.Collection("users").InsertOne(sessCtx, user); err != nil {
+			return err
+		}
+		_, err := ledger.Credit(sessCtx, ledger.Movement{
+			UserID:  user.ID,
+			Amount:  signupBonus,
+			Type:    ledger.TypeBonus,
+			RefType: "signup",
+			RefID:   user.ID,
+		})
+		return err
+	})
+}
 
 type MessageResponse struct {
 	Message string `json:"message"`
@@ -26,12 +58,14 @@ type ErrorResponse struct {
 
 // Register godoc
 // @Summary Register a new user
+// @Description Tạo tài khoản chưa xác minh và gửi email xác minh. Coin khởi tạo được cộng sau khi xác minh.
 // @Tags Auth
 // @Accept json
 // @Produce json
 // @Param user body models.User true "User registration info"
-// @Success 200 {object} MessageResponse
+// @Success 201 {object} MessageResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
 // @Router /auth/register [post]
 func Register(c *gin.Context) {
 	var input struct {
@@ -44,39 +78,67 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(input.Password), 12)
+	email, ok := normalizeEmail(input.Email)
+	if !ok {
+		c.JSON(400, gin.H{"error": "Invalid email"})
+		return
+	}
 
-	user := models.User{
-		ID:          primitive.NewObjectID(),
-		Name:        input.Name,
-		Email:       input.Email,
-		Password:    string(hashedPassword),
-		CoinBalance: 1000, // khởi tạo coin
-		Role:        "user",
+	if err := auth.ValidatePassword(input.Password, email); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
 	}
 
-	_, err := config.DB.Collection("users").InsertOne(context.TODO(), user)
+	hashedPassword, err := auth.HashPassword(input.Password)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "Cannot create user"})
 		return
 	}
 
-	c.JSON(201, gin.H{"message": "User created"})
+	user := models.User{
+		ID:            primitive.NewObjectID(),
+		Name:          input.Name,
+		Email:         email,
+		Password:      hashedPassword,
+		Role:          "user",
+		EmailVerified: false,
+		CreatedAt:     time.Now(),
+	}
+
+	if _, err := config.DB.Collection("users").InsertOne(context.TODO(), user); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(409, gin.H{"error": "Email already registered"})
+			return
+		}
+		c.JSON(500, gin.H{"error": "Cannot create user"})
+		return
+	}
+
+	if err := sendVerificationEmail(context.TODO(), settingsFrom(c).Server.PublicBaseURL, user, auth.RateLimit{}); err != nil {
+		// User vẫn được tạo; có thể gửi lại qua /auth/resend-verification.
+		log.Printf("⚠️ Không gửi được email xác minh cho %s: %v", user.Email, err)
+	}
+
+	c.JSON(201, gin.H{"message": "User created, please check your email to verify your account"})
 }
 
 // Login godoc
 // @Summary User login
+// @Description Nếu tài khoản bật 2FA, trả về mfa_required và mfa_token để gọi /auth/mfa/verify thay vì token. device_name (tuỳ chọn) đặt tên cho phiên đăng nhập; đăng nhập từ thiết bị lạ sẽ gửi email cảnh báo.
 // @Tags Auth
 // @Accept json
 // @Produce json
 // @Param credentials body map[string]string true "Email and Password"
 // @Success 200 {object} MessageResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
 // @Router /auth/login [post]
 func Login(c *gin.Context) {
 	var input struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email      string `json:"email"`
+		Password   string `json:"password"`
+		DeviceName string `json:"device_name"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -84,26 +146,199 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	ctx := context.TODO()
+	history := models.LoginHistory{
+		Email:     strings.ToLower(strings.TrimSpace(input.Email)),
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Method:    "password",
+	}
+	recordLogin := func(result string) {
+		history.Result = result
+		if err := auth.RecordLogin(ctx, history); err != nil {
+			log.Printf("⚠️ Không ghi được login history: %v", err)
+		}
+	}
+
+	wait, err := auth.LoginLockedFor(ctx, input.Email, history.IP)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Cannot log in"})
+		return
+	}
+	if wait > 0 {
+		recordLogin(models.LoginLocked)
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.JSON(429, gin.H{"error": "Too many failed login attempts, try again later"})
+		return
+	}
+
+	// Email không tồn tại và sai mật khẩu trả về cùng một lỗi, cùng thời
+	// gian xử lý, để không lộ email nào đã đăng ký.
 	var user models.User
-	err := config.DB.Collection("users").FindOne(context.TODO(), bson.M{"email": input.Email}).Decode(&user)
+	err = config.DB.Collection("users").FindOne(ctx,
+		bson.M{"email": strings.TrimSpace(input.Email)},
+		options.FindOne().SetCollation(config.EmailCollation),
+	).Decode(&user)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(500, gin.H{"error": "Cannot log in"})
+		return
+	}
+	if err == nil {
+		history.UserID = &user.ID
+	}
+
+	if !auth.CheckPasswordTiming(user.Password, input.Password) {
+		if err := auth.RecordLoginFailure(ctx, input.Email, history.IP); err != nil {
+			log.Printf("⚠️ Không ghi được lần đăng nhập sai: %v", err)
+		}
+		recordLogin(models.LoginInvalidCredentials)
+		c.JSON(401, gin.H{"error": "Invalid email or password"})
+		return
+	}
+
+	// User bật 2FA chỉ được reset bộ đếm sau khi qua bước mã TOTP, để mật
+	// khẩu đúng không mở khoá việc dò mã.
+	if !user.MFAEnabled {
+		if err := auth.ClearLoginFailures(ctx, input.Email); err != nil {
+			log.Printf("⚠️ Không reset được bộ đếm đăng nhập sai: %v", err)
+		}
+	}
+
+	finishLogin(c, user, input.DeviceName, recordLogin)
+}
+
+// finishLogin hoàn tất đăng nhập sau khi đã xác thực được user: trả về MFA
+// challenge nếu user bật 2FA, nếu không thì tạo phiên đăng nhập mới.
+func finishLogin(c *gin.Context, user models.User, deviceName string, recordLogin func(result string)) {
+	if user.MFAEnabled {
+		mfaToken, expiresAt, err := auth.CreateMFAChallenge(context.TODO(), user.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Cannot start two-factor challenge"})
+			return
+		}
+		recordLogin(models.LoginMFARequired)
+		c.JSON(200, gin.H{"mfa_required": true, "mfa_token": mfaToken, "expires_at": expiresAt})
+		return
+	}
+
+	tokens, err := issueSession(c, user, deviceName)
 	if err != nil {
-		c.JSON(401, gin.H{"error": "User not found"})
+		c.JSON(500, gin.H{"error": "Cannot issue token"})
 		return
 	}
+	recordLogin(models.LoginSucceeded)
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-		c.JSON(401, gin.H{"error": "Wrong password"})
+	response := loginResponse(tokens)
+	if auth.MFAEnrollmentRequired(user) {
+		response["mfa_enroll_required"] = true
+	}
+	c.JSON(200, response)
+}
+
+// loginResponse giữ key "token" cũ cho client chưa dùng refresh token.
+func loginResponse(tokens auth.TokenPair) gin.H {
+	return gin.H{
+		"token":         tokens.AccessToken,
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"token_type":    tokens.TokenType,
+		"expires_in":    tokens.ExpiresIn,
+		"expires_at":    tokens.ExpiresAt,
+		"session_id":    tokens.SessionID,
+	}
+}
+
+// RefreshToken godoc
+// @Summary Đổi refresh token lấy access token mới
+// @Description Refresh token cũ bị vô hiệu sau khi dùng. Dùng lại một refresh token đã đổi sẽ thu hồi toàn bộ phiên đăng nhập đó.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param body body map[string]string true "refresh_token"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,401 {object} ErrorResponse
+// @Router /auth/refresh [post]
+func RefreshToken(c *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
 		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID.Hex(),
-		"email":   user.Email,
-		"role":    user.Role,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-	})
+	tokens, err := auth.Rotate(context.TODO(), input.RefreshToken)
+	switch {
+	case errors.Is(err, auth.ErrRefreshReuse):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reused, session revoked"})
+		return
+	case errors.Is(err, auth.ErrInvalidRefresh):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot refresh token"})
+		return
+	}
 
-	tokenString, _ := token.SignedString(jwtSecret)
+	c.JSON(http.StatusOK, loginResponse(tokens))
+}
+
+// Logout godoc
+// @Summary Đăng xuất phiên hiện tại
+// @Description Thu hồi access token đang dùng và các refresh token của phiên đăng nhập này
+// @Tags Auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} MessageResponse
+// @Failure 401,500 {object} ErrorResponse
+// @Router /auth/logout [post]
+func Logout(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.MustGet("user_id").(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	ctx := context.TODO()
+	if err := auth.RevokeAccessToken(ctx, c.GetString("jti"), userID, c.GetTime("token_exp"), "logout"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot logout"})
+		return
+	}
+	if familyID, err := primitive.ObjectIDFromHex(c.GetString("session_id")); err == nil {
+		if err := auth.RevokeFamily(ctx, familyID, "logout"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot logout"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// LogoutAll godoc
+// @Summary Đăng xuất khỏi mọi thiết bị
+// @Description Thu hồi mọi phiên đăng nhập và mọi API key của tài khoản
+// @Tags Auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} MessageResponse
+// @Failure 401,500 {object} ErrorResponse
+// @Router /auth/logout-all [post]
+func LogoutAll(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.MustGet("user_id").(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	ctx := context.TODO()
+	if err := auth.RevokeAccessToken(ctx, c.GetString("jti"), userID, c.GetTime("token_exp"), "logout_all"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot logout"})
+		return
+	}
+	if err := auth.RevokeAllForUser(ctx, userID, "logout_all"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot logout"})
+		return
+	}
 
-	c.JSON(200, gin.H{"token": tokenString})
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all devices"})
 }
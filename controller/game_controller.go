@@ -2,15 +2,13 @@ package controllers
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"go-mvc-demo/config"
+	"go-mvc-demo/importer"
 	"go-mvc-demo/models"
-	"io"
 	"math"
-	"math/rand"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
@@ -18,7 +16,6 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-const RAWG_API_KEY = "adcb1c5b37944e2d9b1a9e730d721f44" // <-- Thay bằng API key thật của bạn
 // CreateGame godoc
 // @Summary Tạo một game mới
 // @Description Thêm game mới vào cơ sở dữ liệu
@@ -37,6 +34,8 @@ func CreateGame(c *gin.Context) {
 		return
 	}
 	game.ID = primitive.NewObjectID()
+	now := time.Now()
+	game.CreatedAt, game.UpdatedAt = &now, &now
 
 	_, err := config.DB.Collection("games").InsertOne(context.TODO(), game)
 	if err != nil {
@@ -84,7 +83,7 @@ func GetGameByID(c *gin.Context) {
 		c.JSON(404, gin.H{"error": "Game not found"})
 		return
 	}
-	c.JSON(200, game)
+	c.JSON(200, annotateGames(c, []models.Game{game})[0])
 }
 
 // DeleteGame godoc
@@ -114,249 +113,28 @@ func DeleteGame(c *gin.Context) {
 
 // FetchAndSaveGames godoc
 // @Summary Import toàn bộ game từ RAWG API (~10,000 game)
-// @Description Đồng bộ dữ liệu từ RAWG API
+// @Description Tạo import job nền cho 250 trang × 40 game; theo dõi qua GET /admin/imports/{id}
 // @Tags Games
 // @Produce json
-// @Success 200 {object} map[string]interface{}
-// @Failure 500 {object} map[string]string
+// @Success 202 {object} models.ImportJob
+// @Failure 409 {object} map[string]string
+// @Failure 503 {object} map[string]string
 // @Router  /games/fetch  [get]
 func FetchAndSaveGames(c *gin.Context) {
-	totalPages := 250 // 250 pages * 40 games = 10,000 games
-	pageSize := 40
-	importedCount := 0
-
-	for page := 1; page <= totalPages; page++ {
-		url := fmt.Sprintf("https://api.rawg.io/api/games?key=%s&page_size=%d&page=%d", RAWG_API_KEY, pageSize, page)
-		resp, err := http.Get(url)
-		if err != nil {
-			c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to fetch from RAWG at page %d", page)})
-			return
-		}
-		defer resp.Body.Close()
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to read response body"})
-			return
-		}
-
-		var result map[string]interface{}
-		err = json.Unmarshal(body, &result)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to parse JSON"})
-			return
-		}
-
-		results, ok := result["results"].([]interface{})
-		if !ok {
-			c.JSON(500, gin.H{"error": "Invalid data format"})
-			return
-		}
-
-		for _, item := range results {
-			gameMap := item.(map[string]interface{})
-
-			rawgID := int(gameMap["id"].(float64))
-			name := gameMap["name"].(string)
-
-			image := ""
-			if gameMap["background_image"] != nil {
-				image = gameMap["background_image"].(string)
-			}
-
-			rating := 0.0
-			if gameMap["rating"] != nil {
-				rating = gameMap["rating"].(float64)
-			}
-
-			genresRaw := gameMap["genres"].([]interface{})
-			var genres []string
-			for _, g := range genresRaw {
-				genres = append(genres, g.(map[string]interface{})["name"].(string))
-			}
-
-			platformsRaw := gameMap["platforms"].([]interface{})
-			var platforms []string
-			for _, p := range platformsRaw {
-				pMap := p.(map[string]interface{})
-				platform := pMap["platform"].(map[string]interface{})
-				platforms = append(platforms, platform["name"].(string))
-			}
-
-			description := "No description available"
-
-			price := 100 + rand.Intn(900)
-
-			filter := bson.M{"rawg_id": rawgID}
-			count, err := config.DB.Collection("games").CountDocuments(context.TODO(), filter)
-			if err != nil {
-				c.JSON(500, gin.H{"error": "Database error"})
-				return
-			}
-			if count == 0 {
-				game := models.Game{
-					ID:          primitive.NewObjectID(),
-					RawgID:      rawgID,
-					Name:        name,
-					Description: description,
-					ImageURL:    image,
-					Genres:      genres,
-					Platforms:   platforms,
-					Rating:      rating,
-					Price:       price,
-				}
-				_, err := config.DB.Collection("games").InsertOne(context.TODO(), game)
-				if err != nil {
-					c.JSON(500, gin.H{"error": "Failed to insert into DB"})
-					return
-				}
-				importedCount++
-			}
-		}
-	}
-
-	c.JSON(200, gin.H{"message": fmt.Sprintf("%d games imported", importedCount)})
+	startImport(c, importer.Params{StartPage: 1, Pages: 250, PageSize: importer.MaxPageSize})
 }
 
 // FetchAndSaveGames100 godoc
 // @Summary Import 100 game từ RAWG API
-// @Description Chỉ lấy 100 game từ RAWG để kiểm thử
+// @Description Tạo import job nền cho 4 trang × 25 game để kiểm thử
 // @Tags Games
 // @Produce json
-// @Success 200 {object} map[string]interface{}
-// @Failure 500 {object} map[string]string
+// @Success 202 {object} models.ImportJob
+// @Failure 409 {object} map[string]string
+// @Failure 503 {object} map[string]string
 // @Router /games/fetch-games100 [get]
 func FetchAndSaveGames100(c *gin.Context) {
-	const totalGames = 100
-	const pageSize = 40
-
-	importedCount := 0
-	fetchedCount := 0
-
-	totalPages := (totalGames + pageSize - 1) / pageSize // = 3 pages
-
-	for page := 1; page <= totalPages; page++ {
-		url := fmt.Sprintf("https://api.rawg.io/api/games?key=%s&page_size=%d&page=%d", RAWG_API_KEY, pageSize, page)
-		resp, err := http.Get(url)
-		if err != nil {
-			c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to fetch from RAWG at page %d: %v", page, err)})
-			return
-		}
-		defer resp.Body.Close()
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to read response body"})
-			return
-		}
-
-		var result map[string]interface{}
-		err = json.Unmarshal(body, &result)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to parse JSON"})
-			return
-		}
-
-		resultsRaw, ok := result["results"].([]interface{})
-		if !ok {
-			c.JSON(500, gin.H{"error": "Invalid data format in results"})
-			return
-		}
-
-		for _, item := range resultsRaw {
-			if importedCount >= totalGames {
-				break
-			}
-
-			gameMap, ok := item.(map[string]interface{})
-			if !ok {
-				continue
-			}
-
-			idVal, ok := gameMap["id"].(float64)
-			if !ok {
-				continue
-			}
-			rawgID := int(idVal)
-
-			nameVal, ok := gameMap["name"].(string)
-			if !ok {
-				continue
-			}
-			name := nameVal
-
-			image := ""
-			if imgVal, ok := gameMap["background_image"].(string); ok {
-				image = imgVal
-			}
-
-			rating := 0.0
-			if ratingVal, ok := gameMap["rating"].(float64); ok {
-				rating = ratingVal
-			}
-
-			genres := []string{}
-			if genresRaw, ok := gameMap["genres"].([]interface{}); ok {
-				for _, g := range genresRaw {
-					if gMap, ok := g.(map[string]interface{}); ok {
-						if gName, ok := gMap["name"].(string); ok {
-							genres = append(genres, gName)
-						}
-					}
-				}
-			}
-
-			platforms := []string{}
-			if platformsRaw, ok := gameMap["platforms"].([]interface{}); ok {
-				for _, p := range platformsRaw {
-					if pMap, ok := p.(map[string]interface{}); ok {
-						if platform, ok := pMap["platform"].(map[string]interface{}); ok {
-							if platformName, ok := platform["name"].(string); ok {
-								platforms = append(platforms, platformName)
-							}
-						}
-					}
-				}
-			}
-
-			description := "No description available" // API chưa lấy mô tả chi tiết, nếu cần có thể fetch thêm
-
-			price := 100 + rand.Intn(900)
-
-			filter := bson.M{"rawg_id": rawgID}
-			count, err := config.DB.Collection("games").CountDocuments(context.TODO(), filter)
-			if err != nil {
-				c.JSON(500, gin.H{"error": "Database error"})
-				return
-			}
-			if count == 0 {
-				game := models.Game{
-					ID:          primitive.NewObjectID(),
-					RawgID:      rawgID,
-					Name:        name,
-					Description: description,
-					ImageURL:    image,
-					Genres:      genres,
-					Platforms:   platforms,
-					Rating:      rating,
-					Price:       price,
-				}
-				_, err := config.DB.Collection("games").InsertOne(context.TODO(), game)
-				if err != nil {
-					c.JSON(500, gin.H{"error": "Failed to insert into DB"})
-					return
-				}
-				importedCount++
-			}
-
-			fetchedCount++
-		}
-		if importedCount >= totalGames {
-			break
-		}
-	}
-
-	c.JSON(200, gin.H{"message": fmt.Sprintf("%d games imported out of %d fetched", importedCount, fetchedCount)})
+	startImport(c, importer.Params{StartPage: 1, Pages: 4, PageSize: 25})
 }
 
 // FetchGamesByPage godoc
@@ -409,7 +187,7 @@ func FetchGamesByPage(c *gin.Context) {
 	totalPages := int(math.Ceil(float64(totalGames) / float64(pageSize)))
 
 	c.JSON(200, gin.H{
-		"games":      games,
+		"games":      annotateGames(c, games),
 		"page":       page,
 		"totalPages": totalPages,
 		"totalGames": totalGames,
@@ -440,7 +218,10 @@ func GetPurchasedGames(c *gin.Context) {
 		return
 	}
 
-	cursor, err := config.DB.Collection("purchases").Find(context.TODO(), bson.M{"user_id": userID})
+	cursor, err := config.DB.Collection("purchases").Find(context.TODO(), bson.M{
+		"user_id": userID,
+		"status":  bson.M{"$ne": models.PurchaseRefunded},
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch purchases"})
 		return
@@ -477,9 +258,11 @@ func GetPurchasedGames(c *gin.Context) {
 	for _, p := range purchases {
 		game := gameMap[p.GameID]
 		result = append(result, gin.H{
-			"id":          p.ID.Hex(),
-			"price":       p.Price,
-			"purchase_at": p.PurchaseAt,
+			"id":            p.ID.Hex(),
+			"price":         p.Price,
+			"list_price":    p.ListPrice,
+			"rental_credit": p.RentalCredit,
+			"purchase_at":   p.PurchaseAt,
 			"game": gin.H{
 				"id":        game.ID.Hex(),
 				"name":      game.Name,
@@ -552,11 +335,16 @@ func GetRentedGames(c *gin.Context) {
 	var results []gin.H
 	for _, rental := range rentals {
 		game := gameMap[rental.GameID]
+		status := rental.Status
+		if status == models.RentalActive && time.Now().After(rental.ExpireAt) {
+			// Sweeper chưa kịp chạy: vẫn báo đúng trạng thái cho client.
+			status = models.RentalExpired
+		}
 		results = append(results, gin.H{
 			"id":        rental.ID.Hex(),
 			"rent_at":   rental.RentAt,
 			"expire_at": rental.ExpireAt,
-			"status":    rental.Status,
+			"status":    status,
 			"game": gin.H{
 				"id":        game.ID.Hex(),
 				"name":      game.Name,
@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-mvc-demo/importer"
+	"go-mvc-demo/models"
+	"go-mvc-demo/rawg"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxImportPages giới hạn số trang của một job (RAWG có khoảng 20.000 trang × 40).
+const maxImportPages = 1000
+
+// importJobResponse thêm phần trăm tiến độ vào job.
+func importJobResponse(job models.ImportJob) gin.H {
+	total := job.EndPage - job.StartPage + 1
+	percent := 0.0
+	if total > 0 {
+		percent = float64(job.PagesDone) * 100 / float64(total)
+	}
+	if job.Status == models.ImportCompleted {
+		// Job dừng sớm khi RAWG hết trang.
+		percent = 100
+	}
+	return gin.H{"job": job, "total_pages": total, "progress_percent": percent}
+}
+
+func respondImportError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, importer.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, importer.ErrJobActive),
+		errors.Is(err, importer.ErrJobNotRunning),
+		errors.Is(err, importer.ErrJobNotStopped):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Import job operation failed"})
+	}
+}
+
+// startImport tạo job và trả về 202; job được scheduler chạy ở nền.
+func startImport(c *gin.Context, params importer.Params) {
+	if settingsFrom(c).RAWG.APIKey == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "RAWG_API_KEY is not configured"})
+		return
+	}
+	if userID, err := primitive.ObjectIDFromHex(c.GetString("user_id")); err == nil {
+		params.CreatedBy = userID
+	}
+
+	job, err := importer.Start(context.TODO(), params)
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, importJobResponse(job))
+}
+
+func importJobID(c *gin.Context) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import job ID"})
+		return id, false
+	}
+	return id, true
+}
+
+// StartImport godoc
+// @Summary Tạo job import game từ RAWG
+// @Description Job chạy nền, lưu tiến độ sau mỗi trang. Mặc định 250 trang × 40 game từ trang 1. Mỗi lúc chỉ có một job queued/running.
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body map[string]int false "start_page, pages, page_size"
+// @Success 202 {object} map[string]interface{}
+// @Failure 400,409,503 {object} ErrorResponse
+// @Router /admin/imports [post]
+func StartImport(c *gin.Context) {
+	input := struct {
+		StartPage int `json:"start_page"`
+		Pages     int `json:"pages"`
+		PageSize  int `json:"page_size"`
+	}{StartPage: 1, Pages: 250, PageSize: importer.MaxPageSize}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+	}
+	if input.StartPage < 1 || input.Pages < 1 || input.Pages > maxImportPages ||
+		input.PageSize < 1 || input.PageSize > importer.MaxPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_page >= 1, pages 1-1000 and page_size 1-40 are required"})
+		return
+	}
+
+	startImport(c, importer.Params{StartPage: input.StartPage, Pages: input.Pages, PageSize: input.PageSize})
+}
+
+// ListImports godoc
+// @Summary Danh sách job import gần nhất
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.ImportJob
+// @Router /admin/imports [get]
+func ListImports(c *gin.Context) {
+	list, err := importer.List(context.TODO(), 20)
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetImport godoc
+// @Summary Tiến độ của một job import
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Import job ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,404 {object} ErrorResponse
+// @Router /admin/imports/{id} [get]
+func GetImport(c *gin.Context) {
+	id, ok := importJobID(c)
+	if !ok {
+		return
+	}
+	job, err := importer.Get(context.TODO(), id)
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, importJobResponse(job))
+}
+
+// GetImportChanges godoc
+// @Summary Thay đổi catalog của một job import
+// @Description Game mới thêm và giá trị trước/sau của các field đã đổi, mới nhất trước (tối đa 500).
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Import job ID"
+// @Param limit query int false "Số bản ghi (mặc định 100)"
+// @Success 200 {array} models.GameChange
+// @Failure 400,404 {object} ErrorResponse
+// @Router /admin/imports/{id}/changes [get]
+func GetImportChanges(c *gin.Context) {
+	id, ok := importJobID(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+	if _, err := importer.Get(context.TODO(), id); err != nil {
+		respondImportError(c, err)
+		return
+	}
+	changes, err := importer.Changes(context.TODO(), id, int64(limit))
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, changes)
+}
+
+// CancelImport godoc
+// @Summary Huỷ job import
+// @Description Job đang chạy dừng sau trang hiện tại; có thể chạy tiếp bằng resume.
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Import job ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,404,409 {object} ErrorResponse
+// @Router /admin/imports/{id}/cancel [post]
+func CancelImport(c *gin.Context) {
+	id, ok := importJobID(c)
+	if !ok {
+		return
+	}
+	job, err := importer.Cancel(context.TODO(), id)
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, importJobResponse(job))
+}
+
+// ResumeImport godoc
+// @Summary Chạy tiếp job import đã huỷ hoặc lỗi
+// @Description Job chạy tiếp từ trang đầu tiên chưa import xong.
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Import job ID"
+// @Success 202 {object} map[string]interface{}
+// @Failure 400,404,409,503 {object} ErrorResponse
+// @Router /admin/imports/{id}/resume [post]
+func ResumeImport(c *gin.Context) {
+	if settingsFrom(c).RAWG.APIKey == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "RAWG_API_KEY is not configured"})
+		return
+	}
+	id, ok := importJobID(c)
+	if !ok {
+		return
+	}
+	job, err := importer.Resume(context.TODO(), id)
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, importJobResponse(job))
+}
+
+// EnrichGame godoc
+// @Summary Lấy lại chi tiết của một game từ RAWG
+// @Description Cập nhật mô tả (HTML đã làm sạch và plain text), ngày phát hành, nhà phát triển, nhà phát hành, ESRB, Metacritic, website, ảnh và trailer.
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Game ID"
+// @Success 200 {object} models.Game
+// @Failure 400,404,409,502,503 {object} ErrorResponse
+// @Router /admin/games/{id}/enrich [post]
+func EnrichGame(c *gin.Context) {
+	if settingsFrom(c).RAWG.APIKey == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "RAWG_API_KEY is not configured"})
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid game ID"})
+		return
+	}
+
+	game, err := importer.EnrichGame(c.Request.Context(), id)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, game)
+	case errors.Is(err, importer.ErrGameNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, importer.ErrNotRAWGGame):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, rawg.ErrNotFound):
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Game no longer exists on RAWG"})
+	case errors.Is(err, importer.ErrFetchFailed):
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch game details from RAWG"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Enrichment failed"})
+	}
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/entitlements"
+	"go-mvc-demo/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LibraryItem là một game trong thư viện của user.
+type LibraryItem struct {
+	Game        models.Game              `json:"game"`
+	Entitlement entitlements.Entitlement `json:"entitlement"`
+}
+
+// annotatedGame là game kèm quyền của user hiện tại (nếu đã đăng nhập).
+type annotatedGame struct {
+	models.Game
+	Entitlement *entitlements.Entitlement `json:"entitlement,omitempty"`
+}
+
+// GetLibrary godoc
+// @Summary Thư viện game của người dùng
+// @Description Các game đã mua, đang thuê hoặc đã hết hạn thuê, mới nhất trước
+// @Tags Games
+// @Security BearerAuth
+// @Produce json
+// @Param filter query string false "owned | rented | expired"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Page size" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,500 {object} map[string]string
+// @Router /api/library [get]
+func GetLibrary(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.MustGet("user_id").(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	filter := c.Query("filter")
+	switch filter {
+	case "", entitlements.KindOwned, entitlements.KindRented, entitlements.KindExpired:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter (owned, rented, expired)"})
+		return
+	}
+
+	page, limit, ok := parsePagination(c, 20)
+	if !ok {
+		return
+	}
+
+	items, total, err := entitlements.List(context.TODO(), userID, filter, (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load library"})
+		return
+	}
+
+	gameIDs := make([]primitive.ObjectID, 0, len(items))
+	for _, e := range items {
+		gameIDs = append(gameIDs, e.GameID)
+	}
+	games, err := gamesByID(context.TODO(), gameIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch games"})
+		return
+	}
+
+	library := make([]LibraryItem, 0, len(items))
+	for _, e := range items {
+		game, exists := games[e.GameID]
+		if !exists {
+			// Game đã bị xoá khỏi catalog.
+			continue
+		}
+		library = append(library, LibraryItem{Game: game, Entitlement: e})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": library,
+		"page":  page,
+		"limit": limit,
+		"total": total,
+	})
+}
+
+func gamesByID(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]models.Game, error) {
+	result := map[primitive.ObjectID]models.Game{}
+	if len(ids) == 0 {
+		return result, nil
+	}
+	cursor, err := config.DB.Collection("games").Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	var games []models.Game
+	if err := cursor.All(ctx, &games); err != nil {
+		return nil, err
+	}
+	for _, g := range games {
+		result[g.ID] = g
+	}
+	return result, nil
+}
+
+// annotateGames gắn quyền của user đang đăng nhập vào danh sách game.
+// Với khách (không có token) thì trả về game như cũ.
+func annotateGames(c *gin.Context, games []models.Game) []annotatedGame {
+	result := make([]annotatedGame, 0, len(games))
+	for _, g := range games {
+		result = append(result, annotatedGame{Game: g})
+	}
+
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		return result
+	}
+	userID, err := primitive.ObjectIDFromHex(userIDStr.(string))
+	if err != nil {
+		return result
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(games))
+	for _, g := range games {
+		ids = append(ids, g.ID)
+	}
+	owned, err := entitlements.ForGames(context.TODO(), userID, ids)
+	if err != nil {
+		return result
+	}
+	for i := range result {
+		e, ok := owned[result[i].ID]
+		if !ok {
+			e = entitlements.Entitlement{GameID: result[i].ID, Kind: entitlements.KindNone}
+		}
+		result[i].Entitlement = &e
+	}
+	return result
+}
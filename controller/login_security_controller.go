@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+
+	"go-mvc-demo/auth"
+	"go-mvc-demo/config"
+	"go-mvc-demo/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetLoginHistory godoc
+// @Summary Lịch sử đăng nhập của người dùng
+// @Description Các lần đăng nhập (thành công hoặc thất bại) vào tài khoản, mới nhất trước, kèm IP và user agent
+// @Tags Auth
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Page size" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,500 {object} map[string]string
+// @Router /api/login-history [get]
+func GetLoginHistory(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.MustGet("user_id").(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	respondLoginHistory(c, userID)
+}
+
+// GetUserLoginHistory godoc
+// @Summary Lịch sử đăng nhập của một user (admin)
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "User ID"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Page size" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,500 {object} map[string]string
+// @Router /admin/users/{id}/login-history [get]
+func GetUserLoginHistory(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	respondLoginHistory(c, userID)
+}
+
+func respondLoginHistory(c *gin.Context, userID primitive.ObjectID) {
+	page, limit, ok := parsePagination(c, 20)
+	if !ok {
+		return
+	}
+
+	coll := config.DB.Collection("login_history")
+	filter := bson.M{"user_id": userID}
+	total, err := coll.CountDocuments(context.TODO(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch login history"})
+		return
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	cursor, err := coll.Find(context.TODO(), filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch login history"})
+		return
+	}
+	entries := []models.LoginHistory{}
+	if err := cursor.All(context.TODO(), &entries); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch login history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"page":    page,
+		"limit":   limit,
+		"total":   total,
+	})
+}
+
+// UnlockUser godoc
+// @Summary Mở khoá đăng nhập cho user (admin)
+// @Description Xoá bộ đếm đăng nhập sai và khoá tạm của tài khoản; truyền thêm ip để mở khoá cả địa chỉ IP đó
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param body body map[string]string false "ip"
+// @Success 200 {object} MessageResponse
+// @Failure 400,404,500 {object} map[string]string
+// @Router /admin/users/{id}/unlock [post]
+func UnlockUser(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	var input struct {
+		IP string `json:"ip"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+	}
+
+	var user models.User
+	if err := config.DB.Collection("users").FindOne(context.TODO(), bson.M{"_id": userID}).Decode(&user); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := auth.UnlockAccount(context.TODO(), user.Email, input.IP); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked"})
+}
@@ -0,0 +1,283 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"go-mvc-demo/auth"
+	"go-mvc-demo/config"
+	"go-mvc-demo/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func currentUser(c *gin.Context) (models.User, bool) {
+	var user models.User
+	userID, err := primitive.ObjectIDFromHex(c.MustGet("user_id").(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return user, false
+	}
+	if err := config.DB.Collection("users").FindOne(context.TODO(), bson.M{"_id": userID}).Decode(&user); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return user, false
+	}
+	return user, true
+}
+
+func respondMFAError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, auth.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrMFANotEnrolled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrInvalidMFACode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrInvalidChallenge):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// EnrollMFA godoc
+// @Summary Bắt đầu bật 2FA
+// @Description Sinh TOTP secret mới, trả về otpauth URI và ảnh QR (PNG, base64). 2FA chỉ được bật sau khi xác nhận bằng một mã.
+// @Tags Auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} auth.Enrollment
+// @Failure 409 {object} ErrorResponse
+// @Router /api/mfa/enroll [post]
+func EnrollMFA(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	enrollment, err := auth.BeginEnrollment(context.TODO(), user)
+	if err != nil {
+		respondMFAError(c, err, "Cannot start two-factor enrollment")
+		return
+	}
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmMFA godoc
+// @Summary Xác nhận bật 2FA
+// @Description Bật 2FA bằng mã TOTP hiện tại. Trả về recovery code (chỉ hiển thị một lần) và token mới thay cho phiên hiện tại.
+// @Tags Auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body map[string]string true "code"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,401,409 {object} ErrorResponse
+// @Router /api/mfa/confirm [post]
+func ConfirmMFA(c *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	codes, err := auth.ConfirmEnrollment(context.TODO(), user.ID, input.Code)
+	if err != nil {
+		respondMFAError(c, err, "Cannot enable two-factor authentication")
+		return
+	}
+
+	// Phiên hiện tại được cấp trước khi bật 2FA (với admin là token bị giới
+	// hạn), nên thay bằng cặp token mới.
+	if familyID, err := primitive.ObjectIDFromHex(c.GetString("session_id")); err == nil {
+		auth.RevokeFamily(context.TODO(), familyID, "mfa_enabled")
+	}
+	user.MFAEnabled = true
+	tokens, err := issueSession(c, user, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot issue token"})
+		return
+	}
+
+	response := loginResponse(tokens)
+	response["message"] = "Two-factor authentication enabled"
+	response["recovery_codes"] = codes
+	c.JSON(http.StatusOK, response)
+}
+
+// DisableMFA godoc
+// @Summary Tắt 2FA
+// @Description Cần mật khẩu và một mã TOTP hoặc recovery code. Admin không được tắt 2FA.
+// @Tags Auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body map[string]string true "password, code"
+// @Success 200 {object} MessageResponse
+// @Failure 400,401,403 {object} ErrorResponse
+// @Router /api/mfa/disable [post]
+func DisableMFA(c *gin.Context) {
+	var input struct {
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password and code are required"})
+		return
+	}
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if user.Role == auth.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin accounts must keep two-factor authentication enabled"})
+		return
+	}
+	if !auth.CheckPassword(user.Password, input.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+	if err := auth.VerifyMFA(context.TODO(), user.ID, input.Code); err != nil {
+		respondMFAError(c, err, "Cannot disable two-factor authentication")
+		return
+	}
+
+	if err := auth.DisableMFA(context.TODO(), user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot disable two-factor authentication"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Tạo lại recovery code
+// @Description Vô hiệu toàn bộ recovery code cũ. Cần một mã TOTP hợp lệ.
+// @Tags Auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body map[string]string true "code"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,401 {object} ErrorResponse
+// @Router /api/mfa/recovery-codes [post]
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if err := auth.VerifyMFA(context.TODO(), user.ID, input.Code); err != nil {
+		respondMFAError(c, err, "Cannot regenerate recovery codes")
+		return
+	}
+
+	codes, err := auth.RegenerateRecoveryCodes(context.TODO(), user.ID)
+	if err != nil {
+		respondMFAError(c, err, "Cannot regenerate recovery codes")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// VerifyMFALogin godoc
+// @Summary Hoàn tất đăng nhập 2FA
+// @Description Đổi mfa_token nhận từ /auth/login cùng mã TOTP hoặc recovery code lấy access/refresh token. Mỗi mfa_token thử tối đa 5 lần trong 5 phút; mã sai được tính vào bộ đếm khoá tài khoản như sai mật khẩu.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param body body map[string]string true "mfa_token, code, device_name"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,401 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /auth/mfa/verify [post]
+func VerifyMFALogin(c *gin.Context) {
+	var input struct {
+		MFAToken   string `json:"mfa_token" binding:"required"`
+		Code       string `json:"code" binding:"required"`
+		DeviceName string `json:"device_name"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_token and code are required"})
+		return
+	}
+
+	ctx := context.TODO()
+	userID, err := auth.MFAChallengeUser(ctx, input.MFAToken)
+	if err != nil {
+		respondMFAError(c, err, "Cannot verify two-factor code")
+		return
+	}
+	var user models.User
+	if err := config.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	history := models.LoginHistory{
+		UserID:    &user.ID,
+		Email:     user.Email,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Method:    "mfa",
+	}
+	recordLogin := func(result string) {
+		history.Result = result
+		if err := auth.RecordLogin(ctx, history); err != nil {
+			log.Printf("⚠️ Không ghi được login history: %v", err)
+		}
+	}
+
+	// Dùng chung khoá với đăng nhập mật khẩu để không dò được mã TOTP bằng
+	// cách xin mfa_token mới sau mỗi 5 lần sai.
+	wait, err := auth.LoginLockedFor(ctx, user.Email, history.IP)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot verify two-factor code"})
+		return
+	}
+	if wait > 0 {
+		recordLogin(models.LoginLocked)
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts, try again later"})
+		return
+	}
+
+	if _, err := auth.CompleteMFAChallenge(ctx, input.MFAToken, input.Code); err != nil {
+		if errors.Is(err, auth.ErrInvalidMFACode) {
+			if err := auth.RecordLoginFailure(ctx, user.Email, history.IP); err != nil {
+				log.Printf("⚠️ Không ghi được lần đăng nhập sai: %v", err)
+			}
+			recordLogin(models.LoginInvalidMFACode)
+		}
+		respondMFAError(c, err, "Cannot verify two-factor code")
+		return
+	}
+
+	if err := auth.ClearLoginFailures(ctx, user.Email); err != nil {
+		log.Printf("⚠️ Không reset được bộ đếm đăng nhập sai: %v", err)
+	}
+	tokens, err := issueSession(c, user, input.DeviceName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot issue token"})
+		return
+	}
+	recordLogin(models.LoginSucceeded)
+	c.JSON(http.StatusOK, loginResponse(tokens))
+}
@@ -0,0 +1,293 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-mvc-demo/auth"
+	"go-mvc-demo/config"
+	"go-mvc-demo/models"
+	"go-mvc-demo/oidc"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// oidcStateTTL là thời gian user có để đăng nhập ở provider rồi quay lại.
+const oidcStateTTL = 10 * time.Minute
+
+var (
+	errOIDCEmailUnverified = errors.New("identity provider did not return a verified email")
+	errOIDCLinkUnverified  = errors.New("an account with this email exists but its email is not verified; verify it or log in with password first")
+)
+
+// oidcStateCookie giữ hash của state trên trình duyệt đã bắt đầu đăng nhập,
+// để callback chỉ được hoàn tất bởi chính trình duyệt đó (chống login CSRF).
+const oidcStateCookie = "oidc_state"
+
+// setOIDCStateCookie lưu value (hash của state) vào cookie HttpOnly trong
+// maxAge; maxAge âm xoá cookie. SameSite=Lax để cookie vẫn được gửi khi
+// provider chuyển hướng về.
+func setOIDCStateCookie(c *gin.Context, provider, value string, maxAge time.Duration) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/auth/oidc/" + provider + "/",
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(settingsFrom(c).Server.PublicBaseURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// oidcStateMatches so state trong query với cookie của trình duyệt.
+func oidcStateMatches(c *gin.Context, state string) bool {
+	cookie, err := c.Cookie(oidcStateCookie)
+	if err != nil || cookie == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie), []byte(auth.HashToken(state))) == 1
+}
+
+// oidcProviders là các identity provider đã cấu hình, theo tên dùng trong URL.
+var oidcProviders = map[string]*oidc.Provider{}
+
+// SetOIDCProvider đăng ký một identity provider cho /auth/oidc/:provider/*.
+func SetOIDCProvider(p *oidc.Provider) {
+	oidcProviders[p.Name()] = p
+}
+
+func oidcProvider(c *gin.Context) (*oidc.Provider, bool) {
+	p, ok := oidcProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown identity provider"})
+	}
+	return p, ok
+}
+
+// OIDCLogin godoc
+// @Summary Đăng nhập qua OpenID Connect
+// @Description Chuyển hướng tới trang đăng nhập của identity provider (authorization code + PKCE). Provider sẽ chuyển về /auth/oidc/{provider}/callback; hash của state được lưu trong cookie HttpOnly oidc_state để callback chỉ hoàn tất được trên cùng trình duyệt.
+// @Tags Auth
+// @Param provider path string true "Tên provider"
+// @Success 302
+// @Failure 404,502 {object} ErrorResponse
+// @Router /auth/oidc/{provider}/login [get]
+func OIDCLogin(c *gin.Context) {
+	provider, ok := oidcProvider(c)
+	if !ok {
+		return
+	}
+
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot start login"})
+		return
+	}
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot start login"})
+		return
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot start login"})
+		return
+	}
+
+	redirectURL, err := provider.AuthCodeURL(context.TODO(), state, nonce, challenge)
+	if err != nil {
+		log.Printf("⚠️ OIDC discovery lỗi (%s): %v", provider.Name(), err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+
+	_, err = config.DB.Collection("oidc_states").InsertOne(context.TODO(), models.OIDCState{
+		StateHash:    auth.HashToken(state),
+		Provider:     provider.Name(),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot start login"})
+		return
+	}
+
+	setOIDCStateCookie(c, provider.Name(), auth.HashToken(state), oidcStateTTL)
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+// OIDCCallback godoc
+// @Summary Callback đăng nhập OpenID Connect
+// @Description Đổi authorization code lấy ID token, liên kết với user theo email đã xác minh (hoặc tạo user mới) rồi trả về token như /auth/login, kể cả bước 2FA.
+// @Tags Auth
+// @Produce json
+// @Param provider path string true "Tên provider"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,401,403,404,409 {object} ErrorResponse
+// @Router /auth/oidc/{provider}/callback [get]
+func OIDCCallback(c *gin.Context) {
+	provider, ok := oidcProvider(c)
+	if !ok {
+		return
+	}
+	if e := c.Query("error"); e != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login cancelled or denied by identity provider", "provider_error": e})
+		return
+	}
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code and state are required"})
+		return
+	}
+
+	// State phải khớp cookie đặt lúc bắt đầu đăng nhập: link callback lấy
+	// từ trình duyệt khác (do kẻ tấn công gửi) không đăng nhập được.
+	matched := oidcStateMatches(c, state)
+	setOIDCStateCookie(c, provider.Name(), "", -time.Second)
+	if !matched {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login state does not match this browser"})
+		return
+	}
+
+	ctx := context.TODO()
+
+	// State chỉ dùng được một lần.
+	var saved models.OIDCState
+	err := config.DB.Collection("oidc_states").FindOneAndDelete(ctx, bson.M{
+		"_id":        auth.HashToken(state),
+		"provider":   provider.Name(),
+		"expires_at": bson.M{"$gt": time.Now()},
+	}).Decode(&saved)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot complete login"})
+		return
+	}
+
+	claims, err := provider.Exchange(ctx, code, saved.CodeVerifier, saved.Nonce)
+	if err != nil {
+		log.Printf("⚠️ OIDC callback lỗi (%s): %v", provider.Name(), err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Cannot verify identity provider response"})
+		return
+	}
+
+	user, err := resolveOIDCUser(ctx, provider.Name(), claims)
+	switch {
+	case errors.Is(err, errOIDCEmailUnverified):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errOIDCLinkUnverified):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("⚠️ Không liên kết được tài khoản OIDC (%s): %v", provider.Name(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot complete login"})
+		return
+	}
+
+	history := models.LoginHistory{
+		UserID:    &user.ID,
+		Email:     user.Email,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Method:    "oidc:" + provider.Name(),
+	}
+	finishLogin(c, user, "", func(result string) {
+		history.Result = result
+		if err := auth.RecordLogin(ctx, history); err != nil {
+			log.Printf("⚠️ Không ghi được login history: %v", err)
+		}
+	})
+}
+
+// resolveOIDCUser tìm user ứng với identity (provider, sub). Lần đầu đăng
+// nhập, identity được liên kết với user có cùng email nếu provider xác nhận
+// email đó; chưa có user thì tạo mới (đã xác minh, kèm coin khởi tạo).
+func resolveOIDCUser(ctx context.Context, provider string, claims *oidc.Claims) (models.User, error) {
+	identities := config.DB.Collection("user_identities")
+	users := config.DB.Collection("users")
+	now := time.Now()
+
+	var user models.User
+	var identity models.UserIdentity
+	err := identities.FindOneAndUpdate(ctx,
+		bson.M{"provider": provider, "subject": claims.Subject},
+		bson.M{"$set": bson.M{"last_login_at": now}},
+	).Decode(&identity)
+	if err == nil {
+		err = users.FindOne(ctx, bson.M{"_id": identity.UserID}).Decode(&user)
+		return user, err
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return user, err
+	}
+
+	// Chỉ liên kết theo email khi provider đã xác minh email, nếu không ai
+	// cũng có thể chiếm tài khoản bằng cách khai email của người khác.
+	if !claims.EmailVerified {
+		return user, errOIDCEmailUnverified
+	}
+	email, ok := normalizeEmail(claims.Email)
+	if !ok {
+		return user, errOIDCEmailUnverified
+	}
+
+	err = users.FindOne(ctx, bson.M{"email": email}, options.FindOne().SetCollation(config.EmailCollation)).Decode(&user)
+	switch {
+	case err == nil:
+		// Tài khoản đăng ký bằng mật khẩu nhưng chưa xác minh có thể do người
+		// khác tạo sẵn với email này; không liên kết để tránh bị chiếm.
+		n, err := users.CountDocuments(ctx, bson.M{"_id": user.ID, "email_verified": false})
+		if err != nil {
+			return user, err
+		}
+		if n > 0 {
+			return user, errOIDCLinkUnverified
+		}
+	case errors.Is(err, mongo.ErrNoDocuments):
+		name := strings.TrimSpace(claims.Name)
+		if name == "" {
+			name, _, _ = strings.Cut(email, "@")
+		}
+		user = models.User{
+			ID:    primitive.NewObjectID(),
+			Name:  name,
+			Email: email,
+			Role:  auth.RoleUser,
+		}
+		if err := createUserWithBonus(ctx, user); err != nil {
+			return user, err
+		}
+		if err := users.FindOne(ctx, bson.M{"_id": user.ID}).Decode(&user); err != nil {
+			return user, err
+		}
+	default:
+		return user, err
+	}
+
+	_, err = identities.InsertOne(ctx, models.UserIdentity{
+		ID:          primitive.NewObjectID(),
+		UserID:      user.ID,
+		Provider:    provider,
+		Subject:     claims.Subject,
+		Email:       email,
+		CreatedAt:   now,
+		LastLoginAt: now,
+	})
+	return user, err
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-mvc-demo/auth"
+	"go-mvc-demo/config"
+	"go-mvc-demo/oidc"
+	"go-mvc-demo/oidc/oidctest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOIDCCallbackRequiresStateCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := oidctest.New(t)
+	SetOIDCProvider(oidc.NewProvider(oidc.Config{
+		Name:     "mock",
+		Issuer:   srv.Issuer(),
+		ClientID: oidctest.ClientID,
+	}))
+	defer delete(oidcProviders, "mock")
+
+	r := gin.New()
+	r.GET("/auth/oidc/:provider/callback", OIDCCallback)
+
+	tests := []struct {
+		name   string
+		cookie string
+	}{
+		{"no cookie", ""},
+		{"cookie of another login", auth.HashToken("other-state")},
+		{"raw state instead of hash", "victim-state"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/auth/oidc/mock/callback?code=c&state=victim-state", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", w.Code, w.Body)
+			}
+			cleared := false
+			for _, c := range w.Result().Cookies() {
+				if c.Name == oidcStateCookie && c.MaxAge < 0 {
+					cleared = true
+				}
+			}
+			if !cleared {
+				t.Errorf("callback did not clear the %s cookie", oidcStateCookie)
+			}
+		})
+	}
+}
+
+func TestOIDCStateCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	setOIDCStateCookie(c, "mock", auth.HashToken("s-1"), oidcStateTTL)
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, want 1", len(cookies))
+	}
+	cookie := cookies[0]
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/auth/oidc/mock/" || cookie.MaxAge != int(oidcStateTTL.Seconds()) {
+		t.Errorf("cookie = %+v", cookie)
+	}
+	if cookie.Value == "s-1" {
+		t.Error("cookie stores the raw state")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	if !oidcStateMatches(c, "s-1") {
+		t.Error("state from the same browser did not match")
+	}
+	if oidcStateMatches(c, "s-2") {
+		t.Error("different state matched the cookie")
+	}
+}
+
+func TestOIDCStateCookieSecureFollowsSettings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for baseURL, want := range map[string]bool{
+		"https://game-lib.example.com": true,
+		"http://localhost:8080":        false,
+	} {
+		cfg := config.Default()
+		cfg.Server.PublicBaseURL = baseURL
+		r := gin.New()
+		r.Use(Settings(cfg))
+		r.GET("/", func(c *gin.Context) { setOIDCStateCookie(c, "mock", "v", oidcStateTTL) })
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if cookies := w.Result().Cookies(); len(cookies) != 1 || cookies[0].Secure != want {
+			t.Errorf("PUBLIC_BASE_URL %s: cookies = %+v, want Secure %v", baseURL, cookies, want)
+		}
+	}
+}
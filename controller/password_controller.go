@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-mvc-demo/auth"
+	"go-mvc-demo/config"
+	"go-mvc-demo/mailer"
+	"go-mvc-demo/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// passwordResetTTL là thời hạn của token đặt lại mật khẩu.
+const passwordResetTTL = time.Hour
+
+// passwordResetLimit: mỗi user tối đa 1 email/phút và 5 email/ngày.
+var passwordResetLimit = auth.RateLimit{MinInterval: time.Minute, MaxPerDay: 5}
+
+// setPassword lưu mật khẩu mới rồi thu hồi mọi phiên đăng nhập và API key
+// của user.
+func setPassword(ctx context.Context, userID primitive.ObjectID, password, reason string) error {
+	hashed, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	res, err := config.DB.Collection("users").UpdateByID(ctx, userID, bson.M{"$set": bson.M{
+		"password":            hashed,
+		"password_changed_at": time.Now(),
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return auth.RevokeAllForUser(ctx, userID, reason)
+}
+
+// ForgotPassword godoc
+// @Summary Quên mật khẩu
+// @Description Gửi token đặt lại mật khẩu (hết hạn sau 1 giờ, dùng một lần) qua email. Luôn trả về cùng một thông báo để không lộ email nào đã đăng ký.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param body body map[string]string true "email"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /auth/forgot-password [post]
+func ForgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is required"})
+		return
+	}
+
+	response := gin.H{"message": "If the account exists, a password reset email has been sent"}
+
+	var user models.User
+	err := config.DB.Collection("users").FindOne(context.TODO(),
+		bson.M{"email": strings.TrimSpace(input.Email)},
+		options.FindOne().SetCollation(config.EmailCollation),
+	).Decode(&user)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("⚠️ Không tìm được user để đặt lại mật khẩu: %v", err)
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := auth.IssueOneTimeToken(context.TODO(), user.ID, auth.PurposeResetPassword, passwordResetTTL, passwordResetLimit)
+	if errors.Is(err, auth.ErrTooManyRequests) {
+		// Không báo 429 để tránh lộ email đã đăng ký.
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot create reset token"})
+		return
+	}
+
+	link := settingsFrom(c).Server.PublicBaseURL + "/reset-password?token=" + url.QueryEscape(token)
+	err = mailSender.Send(context.TODO(), mailer.Message{
+		To:      user.Email,
+		Subject: "Đặt lại mật khẩu Game Library",
+		Body: fmt.Sprintf("Xin chào %s,\n\nMở link sau để đặt lại mật khẩu:\n%s\n\nMã đặt lại: %s\nLink hết hạn sau %d phút và chỉ dùng được một lần. Nếu bạn không yêu cầu, hãy bỏ qua email này.\n",
+			user.Name, link, token, int(passwordResetTTL.Minutes())),
+	})
+	if err != nil {
+		log.Printf("⚠️ Không gửi được email đặt lại mật khẩu cho %s: %v", user.Email, err)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ResetPassword godoc
+// @Summary Đặt lại mật khẩu
+// @Description Đặt mật khẩu mới bằng token nhận qua email. Mọi phiên đăng nhập và API key hiện có bị thu hồi.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param body body map[string]string true "token, new_password"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /auth/reset-password [post]
+func ResetPassword(c *gin.Context) {
+	var input struct {
+		Token       string `json:"token" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token and new_password are required"})
+		return
+	}
+
+	// Kiểm tra mật khẩu trước khi dùng token để user nhập lại được nếu mật
+	// khẩu không đạt. Phần liên quan tới email được kiểm tra lại sau.
+	if err := auth.ValidatePassword(input.NewPassword, ""); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := auth.ConsumeOneTimeToken(context.TODO(), auth.PurposeResetPassword, input.Token)
+	if errors.Is(err, auth.ErrInvalidOneTimeToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot reset password"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.Collection("users").FindOne(context.TODO(), bson.M{"_id": userID}).Decode(&user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+	if err := auth.ValidatePassword(input.NewPassword, user.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := setPassword(context.TODO(), userID, input.NewPassword, "password_reset"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset, please log in again"})
+}
+
+// ChangePassword godoc
+// @Summary Đổi mật khẩu
+// @Description Đổi mật khẩu khi biết mật khẩu hiện tại. Mọi phiên đăng nhập khác và mọi API key bị thu hồi; response chứa token mới cho thiết bị hiện tại.
+// @Tags Auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body map[string]string true "current_password, new_password"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,401 {object} ErrorResponse
+// @Router /api/change-password [post]
+func ChangePassword(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.MustGet("user_id").(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var input struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "current_password and new_password are required"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.Collection("users").FindOne(context.TODO(), bson.M{"_id": userID}).Decode(&user); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !auth.CheckPassword(user.Password, input.CurrentPassword) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+	if input.NewPassword == input.CurrentPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New password must be different from the current password"})
+		return
+	}
+	if err := auth.ValidatePassword(input.NewPassword, user.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := setPassword(context.TODO(), userID, input.NewPassword, "password_change"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot change password"})
+		return
+	}
+
+	tokens, err := issueSession(c, user, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot issue token"})
+		return
+	}
+
+	response := loginResponse(tokens)
+	response["message"] = "Password changed"
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-mvc-demo/auth"
+	"go-mvc-demo/config"
+	"go-mvc-demo/config/mongotest"
+	"go-mvc-demo/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestResetPasswordRevokesAPIKeys(t *testing.T) {
+	mongotest.Setup(t)
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+
+	user := models.User{ID: primitive.NewObjectID(), Email: "keys@example.com", Role: "user", EmailVerified: true}
+	if _, err := config.DB.Collection("users").InsertOne(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	raw, _, err := auth.CreateAPIKey(ctx, user, "script", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.AuthenticateAPIKey(ctx, raw, "127.0.0.1"); err != nil {
+		t.Fatalf("fresh key: %v", err)
+	}
+
+	token, err := auth.IssueOneTimeToken(ctx, user.ID, auth.PurposeResetPassword, time.Hour, auth.RateLimit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.POST("/reset-password", ResetPassword)
+	req := httptest.NewRequest(http.MethodPost, "/reset-password",
+		strings.NewReader(`{"token":"`+token+`","new_password":"correct horse 42"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("reset password: %d %s", w.Code, w.Body)
+	}
+
+	if _, err := auth.AuthenticateAPIKey(ctx, raw, "127.0.0.1"); !errors.Is(err, auth.ErrTokenRevoked) {
+		t.Fatalf("key after password reset: err = %v, want ErrTokenRevoked", err)
+	}
+	var key models.APIKey
+	if err := config.DB.Collection("api_keys").FindOne(ctx, bson.M{"user_id": user.ID}).Decode(&key); err != nil {
+		t.Fatal(err)
+	}
+	if key.RevokeReason != "password_reset" {
+		t.Fatalf("revoke_reason = %q, want password_reset", key.RevokeReason)
+	}
+
+	// Key tạo sau khi đặt lại mật khẩu vẫn dùng được.
+	fresh, _, err := auth.CreateAPIKey(ctx, user, "new script", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.AuthenticateAPIKey(ctx, fresh, "127.0.0.1"); err != nil {
+		t.Fatalf("key created after reset: %v", err)
+	}
+}
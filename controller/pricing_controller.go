@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"go-mvc-demo/pricing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// pricingRequest là body chung của preview và apply.
+type pricingRequest struct {
+	// GameIDs giới hạn các game được tính lại; để trống là toàn bộ catalog.
+	GameIDs []string `json:"game_ids"`
+	// IncludeManual tính lại cả game tạo tay (không import từ RAWG).
+	IncludeManual bool `json:"include_manual"`
+	// Limit là số thay đổi lớn nhất trả về trong changes (mặc định 100).
+	Limit int `json:"limit"`
+}
+
+func bindPricingRequest(c *gin.Context) (pricing.Selection, int, bool) {
+	input := pricingRequest{Limit: 100}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return pricing.Selection{}, 0, false
+		}
+	}
+	if input.Limit < 1 || input.Limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+		return pricing.Selection{}, 0, false
+	}
+	sel := pricing.Selection{IncludeManual: input.IncludeManual}
+	for _, raw := range input.GameIDs {
+		id, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid game ID " + raw})
+			return sel, 0, false
+		}
+		sel.GameIDs = append(sel.GameIDs, id)
+	}
+	return sel, input.Limit, true
+}
+
+// PreviewPricing godoc
+// @Summary Xem trước giá mới theo bảng giá (dry-run)
+// @Description Tính giá mới cho catalog (mặc định chỉ game import từ RAWG) và trả về giá trước/sau, không ghi gì vào DB.
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body pricingRequest false "game_ids, include_manual, limit"
+// @Success 200 {object} pricing.Report
+// @Failure 400,500 {object} ErrorResponse
+// @Router /admin/pricing/preview [post]
+func PreviewPricing(c *gin.Context) {
+	sel, limit, ok := bindPricingRequest(c)
+	if !ok {
+		return
+	}
+	report, err := pricing.Preview(context.TODO(), sel, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute prices"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ApplyPricing godoc
+// @Summary Áp dụng bảng giá cho catalog
+// @Description Đổi giá các game theo bảng giá và ghi price_history. Game bị đổi giá ở nơi khác trong lúc áp dụng được bỏ qua (conflicts).
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body pricingRequest false "game_ids, include_manual, limit"
+// @Success 200 {object} pricing.Report
+// @Failure 400,500 {object} ErrorResponse
+// @Router /admin/pricing/apply [post]
+func ApplyPricing(c *gin.Context) {
+	sel, limit, ok := bindPricingRequest(c)
+	if !ok {
+		return
+	}
+	changedBy, _ := primitive.ObjectIDFromHex(c.GetString("user_id"))
+	report, err := pricing.Apply(context.TODO(), sel, changedBy, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply prices", "report": report})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetPriceHistory godoc
+// @Summary Lịch sử giá của một game
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Game ID"
+// @Param limit query int false "Số bản ghi (mặc định 50)"
+// @Success 200 {array} models.PriceHistory
+// @Failure 400,500 {object} ErrorResponse
+// @Router /admin/games/{id}/price-history [get]
+func GetPriceHistory(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid game ID"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+	list, err := pricing.History(context.TODO(), id, int64(limit))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load price history"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
@@ -1,25 +1,45 @@
 package controllers
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"go-mvc-demo/config"
+	"go-mvc-demo/ledger"
 	"go-mvc-demo/models"
+	"go-mvc-demo/payment"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// rechargeCheckoutTTL là thời gian một recharge pending chờ thanh toán
+// trước khi bị chuyển sang expired.
+const rechargeCheckoutTTL = 30 * time.Minute
+
+var paymentProvider payment.Provider
+
+// SetPaymentProvider cấu hình cổng thanh toán dùng cho nạp coin.
+func SetPaymentProvider(p payment.Provider) {
+	paymentProvider = p
+}
+
 // RechargeCoin godoc
-// @Summary Recharge coin balance
+// @Summary Tạo yêu cầu nạp coin
+// @Description Tạo recharge ở trạng thái pending và trả về mã tham chiếu thanh toán. Coin chỉ được cộng khi webhook xác nhận thành công.
 // @Tags Wallet
 // @Security BearerAuth
 // @Accept json
 // @Produce json
 // @Param amount body map[string]int true "Recharge amount"
-// @Success 200 {object} gin.H
+// @Success 201 {object} gin.H
 // @Failure 400,500 {object} gin.H
 // @Router /recharge [post]
 
@@ -34,36 +54,228 @@ func RechargeCoin(c *gin.Context) {
 	var req struct {
 		Amount int `json:"amount"`
 	}
-	if err := c.ShouldBindJSON(&req); err != nil || req.Amount < 100 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount (min 100)"})
+	maxAmount := settingsFrom(c).Payment.MaxRechargeAmount
+	if err := c.ShouldBindJSON(&req); err != nil || req.Amount < 100 || req.Amount > maxAmount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid amount (min 100, max %d)", maxAmount)})
 		return
 	}
 
+	now := time.Now()
+	expiresAt := now.Add(rechargeCheckoutTTL)
 	recharge := models.Recharge{
+		ID:        primitive.NewObjectID(),
 		UserID:    userID,
 		Amount:    req.Amount,
-		Status:    "success",
-		CreatedAt: time.Now(),
+		Status:    models.RechargePending,
+		Provider:  paymentProvider.Name(),
+		ExpiresAt: &expiresAt,
+		CreatedAt: now,
 	}
 
-	rechargeCollection := config.DB.Collection("recharges")
-	userCollection := config.DB.Collection("users")
-
-	_, err = rechargeCollection.InsertOne(context.TODO(), recharge)
+	checkout, err := paymentProvider.CreateCheckout(context.TODO(), payment.CheckoutRequest{
+		RechargeID: recharge.ID.Hex(),
+		UserID:     userID.Hex(),
+		Amount:     req.Amount,
+		ExpiresAt:  expiresAt,
+	})
 	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to create checkout"})
+		return
+	}
+	recharge.Reference = checkout.Reference
+	recharge.CheckoutURL = checkout.URL
+
+	if _, err := config.DB.Collection("recharges").InsertOne(context.TODO(), recharge); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save recharge"})
 		return
 	}
 
-	_, err = userCollection.UpdateOne(context.TODO(), bson.M{"_id": userID}, bson.M{
-		"$inc": bson.M{"coin_balance": req.Amount},
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Recharge pending payment",
+		"recharge_id":  recharge.ID.Hex(),
+		"reference":    recharge.Reference,
+		"checkout_url": recharge.CheckoutURL,
+		"status":       recharge.Status,
+		"expires_at":   expiresAt,
 	})
+}
+
+var (
+	errRechargeNotFound = errors.New("recharge not found")
+	errAmountMismatch   = errors.New("amount mismatch")
+)
+
+// PaymentWebhook godoc
+// @Summary Nhận webhook từ cổng thanh toán
+// @Description Xác thực chữ ký HMAC, bỏ qua webhook trùng và cập nhật trạng thái recharge
+// @Tags Wallet
+// @Accept json
+// @Produce json
+// @Success 200 {object} gin.H
+// @Failure 400,401,404,500 {object} gin.H
+// @Router /webhooks/payments [post]
+func PaymentWebhook(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot read body"})
+		return
+	}
+
+	event, err := paymentProvider.ParseWebhook(c.Request.Header, body)
+	if errors.Is(err, payment.ErrInvalidSignature) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update coin balance"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Recharge successful"})
+	applied, err := applyPaymentEvent(context.TODO(), paymentProvider.Name(), event)
+	switch {
+	case mongo.IsDuplicateKeyError(err):
+		c.JSON(http.StatusOK, gin.H{"message": "Event already processed"})
+	case errors.Is(err, errRechargeNotFound):
+		// Trả 404 để cổng thanh toán gửi lại sau.
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recharge not found"})
+	case errors.Is(err, errAmountMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount does not match recharge"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process event"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Event processed", "applied": applied})
+	}
+}
+
+// applyPaymentEvent ghi nhận event và chuyển trạng thái recharge trong một
+// transaction. Event trùng sẽ trả về duplicate key error từ payment_events.
+// Thất bại chỉ áp dụng cho recharge pending; thành công áp dụng cho mọi
+// recharge chưa success (kể cả expired/failed vì cổng có thể thu tiền muộn
+// hoặc thử lại sau lần thất bại), nên tiền đã thu luôn được cộng đúng một lần.
+func applyPaymentEvent(ctx context.Context, provider string, event payment.Event) (bool, error) {
+	applied := false
+	err := config.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		applied = false
+
+		var recharge models.Recharge
+		err := config.DB.Collection("recharges").FindOne(sessCtx, bson.M{
+			"provider":  provider,
+			"reference": event.Reference,
+		}).Decode(&recharge)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return errRechargeNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if event.Amount != recharge.Amount {
+			return errAmountMismatch
+		}
+
+		now := time.Now()
+		record := models.PaymentEvent{
+			ID:         provider + ":" + event.ID,
+			Provider:   provider,
+			Reference:  event.Reference,
+			Status:     event.Status,
+			Amount:     event.Amount,
+			RechargeID: recharge.ID,
+			OccurredAt: event.OccurredAt,
+			ReceivedAt: now,
+		}
+
+		var filter bson.M
+		var newStatus string
+		if event.Status == payment.StatusSucceeded {
+			filter = bson.M{"_id": recharge.ID, "status": bson.M{"$in": bson.A{models.RechargePending, models.RechargeExpired, models.RechargeFailed}}}
+			newStatus = models.RechargeSuccess
+		} else {
+			filter = bson.M{"_id": recharge.ID, "status": models.RechargePending}
+			newStatus = models.RechargeFailed
+		}
+
+		res, err := config.DB.Collection("recharges").UpdateOne(sessCtx, filter, bson.M{"$set": bson.M{
+			"status":       newStatus,
+			"completed_at": now,
+		}})
+		if err != nil {
+			return err
+		}
+		applied = res.ModifiedCount > 0
+		record.Applied = applied
+
+		if _, err := config.DB.Collection("payment_events").InsertOne(sessCtx, record); err != nil {
+			return err
+		}
+
+		if applied && newStatus == models.RechargeSuccess {
+			_, err := ledger.Credit(sessCtx, ledger.Movement{
+				UserID:  recharge.UserID,
+				Amount:  recharge.Amount,
+				Type:    ledger.TypeRecharge,
+				RefType: "recharge",
+				RefID:   recharge.ID,
+			})
+			return err
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// SimulateFakePayment godoc
+// @Summary Giả lập kết quả thanh toán (chỉ với fake gateway)
+// @Description Tạo webhook đã ký và gửi qua cùng luồng xử lý với webhook thật
+// @Tags Wallet
+// @Security BearerAuth
+// @Produce json
+// @Param reference path string true "Checkout reference"
+// @Param outcome path string true "succeeded | failed"
+// @Success 200 {object} gin.H
+// @Router /payments/fake/{reference}/{outcome} [post]
+func SimulateFakePayment(c *gin.Context) {
+	gateway, ok := paymentProvider.(*payment.FakeGateway)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fake gateway is not enabled"})
+		return
+	}
+
+	userIDStr, _ := c.Get("user_id")
+	userID, _ := primitive.ObjectIDFromHex(userIDStr.(string))
+
+	var recharge models.Recharge
+	err := config.DB.Collection("recharges").FindOne(context.TODO(), bson.M{
+		"provider":  gateway.Name(),
+		"reference": c.Param("reference"),
+		"user_id":   userID,
+	}).Decode(&recharge)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recharge not found"})
+		return
+	}
+
+	body, _ := json.Marshal(payment.Event{
+		ID:         "evt_" + primitive.NewObjectID().Hex(),
+		Reference:  recharge.Reference,
+		Status:     c.Param("outcome"),
+		Amount:     recharge.Amount,
+		OccurredAt: time.Now(),
+	})
+	c.Request.Header.Set(payment.SignatureHeader, gateway.Sign(body))
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	PaymentWebhook(c)
+}
+
+// ExpirePendingRecharges chuyển các recharge pending đã quá hạn sang expired.
+func ExpirePendingRecharges(ctx context.Context) (int64, error) {
+	res, err := config.DB.Collection("recharges").UpdateMany(ctx, bson.M{
+		"status":     models.RechargePending,
+		"expires_at": bson.M{"$lte": time.Now()},
+	}, bson.M{"$set": bson.M{"status": models.RechargeExpired}})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
 }
 
 // GetRechargeHistory godoc
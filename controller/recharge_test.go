@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/config/mongotest"
+	"go-mvc-demo/ledger"
+	"go-mvc-demo/models"
+	"go-mvc-demo/payment"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPaymentWebhookSuccessAfterFailureCredits(t *testing.T) {
+	mongotest.Setup(t)
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+
+	gateway := payment.NewFakeGateway("test-secret", "http://localhost")
+	prev := paymentProvider
+	SetPaymentProvider(gateway)
+	t.Cleanup(func() { SetPaymentProvider(prev) })
+
+	userID := primitive.NewObjectID()
+	if _, err := config.DB.Collection("users").InsertOne(ctx, models.User{ID: userID, Email: "late@example.com", Role: "user", EmailVerified: true}); err != nil {
+		t.Fatal(err)
+	}
+	recharge := models.Recharge{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Amount:    200,
+		Status:    models.RechargePending,
+		Provider:  gateway.Name(),
+		Reference: "chk_" + primitive.NewObjectID().Hex(),
+		CreatedAt: time.Now(),
+	}
+	if _, err := config.DB.Collection("recharges").InsertOne(ctx, recharge); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.POST("/webhooks/payments", PaymentWebhook)
+	send := func(id, status string) bool {
+		t.Helper()
+		body, _ := json.Marshal(payment.Event{ID: id, Reference: recharge.Reference, Status: status, Amount: recharge.Amount, OccurredAt: time.Now()})
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/payments", strings.NewReader(string(body)))
+		req.Header.Set(payment.SignatureHeader, gateway.Sign(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s webhook: %d %s", status, w.Code, w.Body)
+		}
+		var resp struct {
+			Applied bool `json:"applied"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Applied
+	}
+
+	if !send("evt_fail", payment.StatusFailed) {
+		t.Fatal("failure webhook on a pending recharge was not applied")
+	}
+	// Cổng thanh toán thử lại và thu tiền thành công sau đó.
+	if !send("evt_success", payment.StatusSucceeded) {
+		t.Fatal("success webhook after a failure was not applied")
+	}
+	// Thất bại đến muộn không ghi đè thành công.
+	if send("evt_fail_late", payment.StatusFailed) {
+		t.Fatal("late failure webhook overwrote the success")
+	}
+
+	var stored models.Recharge
+	if err := config.DB.Collection("recharges").FindOne(ctx, bson.M{"_id": recharge.ID}).Decode(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.RechargeSuccess {
+		t.Fatalf("recharge status = %q, want success", stored.Status)
+	}
+	rec, err := ledger.Reconcile(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rec.Reconciled || rec.StoredBalance != recharge.Amount {
+		t.Fatalf("wallet after late success = %+v, want %d coins", rec, recharge.Amount)
+	}
+}
@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"go-mvc-demo/auth"
+	"go-mvc-demo/config"
+	"go-mvc-demo/ledger"
+	"go-mvc-demo/models"
+	"go-mvc-demo/rentalplan"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	errRefundNotFound     = errors.New("record not found")
+	errRefundForbidden    = errors.New("not the owner")
+	errAlreadyRefunded    = errors.New("already refunded")
+	errRefundWindowClosed = errors.New("refund window closed")
+	errRentalNotActive    = errors.New("rental is not active")
+	errRentalCredited     = errors.New("rental credited toward a purchase")
+)
+
+// refundWindow là khoảng thời gian kể từ PurchaseAt mà user tự hoàn tiền được
+// (REFUND_WINDOW_DAYS, mặc định 14 ngày).
+func refundWindow(cfg config.RentalConfig) time.Duration {
+	return time.Duration(cfg.RefundWindowDays) * 24 * time.Hour
+}
+
+// isAdmin: hoàn tiền thay user cần quyền wallet:adjust (admin, hoặc API key
+// của admin có scope này).
+func isAdmin(c *gin.Context) bool {
+	return hasPermission(c, auth.PermWalletAdjust)
+}
+
+// RefundPurchase godoc
+// @Summary Hoàn tiền một game đã mua
+// @Description User tự hoàn tiền trong thời hạn REFUND_WINDOW_DAYS kể từ lúc mua; admin có thể hoàn bất kỳ purchase nào.
+// @Tags Wallet
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Purchase ID"
+// @Param body body map[string]string false "Reason"
+// @Success 200 {object} gin.H
+// @Failure 400,403,404,409,500 {object} gin.H
+// @Router /purchases/{id}/refund [post]
+func RefundPurchase(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.MustGet("user_id").(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	purchaseID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purchase ID"})
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	c.ShouldBindJSON(&req)
+
+	admin := isAdmin(c)
+	var refunded models.Purchase
+	err = config.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		var purchase models.Purchase
+		err := config.DB.Collection("purchases").FindOne(sessCtx, bson.M{"_id": purchaseID}).Decode(&purchase)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return errRefundNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !admin {
+			if purchase.UserID != userID {
+				return errRefundForbidden
+			}
+			if time.Since(purchase.PurchaseAt) > refundWindow(settingsFrom(c).Rental) {
+				return errRefundWindowClosed
+			}
+		}
+
+		now := time.Now()
+		res, err := config.DB.Collection("purchases").UpdateOne(sessCtx,
+			bson.M{"_id": purchaseID, "status": bson.M{"$ne": models.PurchaseRefunded}},
+			bson.M{"$set": bson.M{
+				"status":        models.PurchaseRefunded,
+				"refunded_at":   now,
+				"refund_amount": purchase.Price,
+				"refund_reason": req.Reason,
+			}})
+		if err != nil {
+			return err
+		}
+		if res.ModifiedCount == 0 {
+			return errAlreadyRefunded
+		}
+
+		purchase.Status = models.PurchaseRefunded
+		purchase.RefundedAt = &now
+		purchase.RefundAmount = purchase.Price
+		purchase.RefundReason = req.Reason
+		refunded = purchase
+
+		// Tiền thuê đã dùng làm giảm giá được trả lại để dùng cho lần mua sau.
+		_, err = config.DB.Collection("rentals").UpdateMany(sessCtx,
+			bson.M{"credited_purchase_id": purchase.ID},
+			bson.M{"$unset": bson.M{"credited_purchase_id": ""}},
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = ledger.Credit(sessCtx, ledger.Movement{
+			UserID:  purchase.UserID,
+			Amount:  purchase.Price,
+			Type:    ledger.TypeRefund,
+			RefType: "purchase",
+			RefID:   purchase.ID,
+			Note:    req.Reason,
+		})
+		return err
+	})
+	if err != nil {
+		respondRefundError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Purchase refunded", "purchase": refunded})
+}
+
+// ReturnRental godoc
+// @Summary Trả game thuê sớm
+// @Description Kết thúc rental đang active và hoàn lại số coin tương ứng với thời gian còn lại.
+// @Tags Wallet
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Rental ID"
+// @Success 200 {object} gin.H
+// @Failure 400,403,404,409,500 {object} gin.H
+// @Router /rentals/{id}/return [post]
+func ReturnRental(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.MustGet("user_id").(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	rentalID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rental ID"})
+		return
+	}
+
+	admin := isAdmin(c)
+	var returned models.Rental
+	err = config.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		var rental models.Rental
+		err := config.DB.Collection("rentals").FindOne(sessCtx, bson.M{"_id": rentalID}).Decode(&rental)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return errRefundNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !admin && rental.UserID != userID {
+			return errRefundForbidden
+		}
+
+		now := time.Now()
+		if rental.Status != models.RentalActive || !now.Before(rental.ExpireAt) {
+			return errRentalNotActive
+		}
+		if rental.CreditedPurchaseID != nil {
+			// Tiền thuê đã được trừ vào giá mua game.
+			return errRentalCredited
+		}
+
+		paid := rentalAmountPaid(rental)
+		if paid == 0 {
+			// Rental cũ chưa lưu giá: dùng công thức giá thuê mặc định.
+			var game models.Game
+			if err := config.DB.Collection("games").FindOne(sessCtx, bson.M{"_id": rental.GameID}).Decode(&game); err == nil {
+				paid = game.Price / 10
+			}
+		}
+		amount := rentalplan.ProratedRefund(paid, rental.RentAt, rental.ExpireAt, now)
+
+		res, err := config.DB.Collection("rentals").UpdateOne(sessCtx,
+			bson.M{"_id": rentalID, "status": models.RentalActive, "credited_purchase_id": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{
+				"status":        models.RentalRefunded,
+				"refunded_at":   now,
+				"refund_amount": amount,
+			}})
+		if err != nil {
+			return err
+		}
+		if res.ModifiedCount == 0 {
+			return errRentalNotActive
+		}
+
+		rental.Status = models.RentalRefunded
+		rental.RefundedAt = &now
+		rental.RefundAmount = amount
+		returned = rental
+
+		_, err = ledger.Credit(sessCtx, ledger.Movement{
+			UserID:  rental.UserID,
+			Amount:  amount,
+			Type:    ledger.TypeRefund,
+			RefType: "rental",
+			RefID:   rental.ID,
+			Note:    "early return",
+		})
+		return err
+	})
+	if err != nil {
+		respondRefundError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Rental returned", "rental": returned})
+}
+
+func respondRefundError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errRefundNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	case errors.Is(err, errRefundForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not own this record"})
+	case errors.Is(err, errAlreadyRefunded):
+		c.JSON(http.StatusConflict, gin.H{"error": "Already refunded"})
+	case errors.Is(err, errRefundWindowClosed):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refund window has closed"})
+	case errors.Is(err, errRentalNotActive):
+		c.JSON(http.StatusConflict, gin.H{"error": "Rental is not active"})
+	case errors.Is(err, errRentalCredited):
+		c.JSON(http.StatusConflict, gin.H{"error": "Rental was credited toward a purchase"})
+	default:
+		respondTransactionError(c, err, "Failed to process refund")
+	}
+}
@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/ledger"
+	"go-mvc-demo/models"
+	"go-mvc-demo/rentalplan"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var errRentalChanged = errors.New("rental was modified concurrently")
+
+// rentalAmountPaid trả về tổng số coin đã trả cho rental (gồm các lần gia hạn).
+func rentalAmountPaid(r models.Rental) int {
+	if r.TotalPaid > 0 {
+		return r.TotalPaid
+	}
+	return r.Price
+}
+
+// rentToOwnCredit tính khoản giảm giá từ các rental của user cho game trong
+// RENT_TO_OWN_DAYS ngày gần nhất (chưa hoàn tiền, chưa dùng cho purchase khác).
+// Trả về số coin được giảm (không vượt quá listPrice) và ID các rental đã dùng.
+func rentToOwnCredit(ctx context.Context, cfg config.RentalConfig, userID, gameID primitive.ObjectID, listPrice int) (int, []primitive.ObjectID, error) {
+	percent := cfg.RentToOwnPercent
+	days := cfg.RentToOwnDays
+	if percent == 0 || days == 0 {
+		return 0, nil, nil
+	}
+
+	cursor, err := config.DB.Collection("rentals").Find(ctx, bson.M{
+		"user_id":              userID,
+		"game_id":              gameID,
+		"status":               bson.M{"$in": bson.A{models.RentalActive, models.RentalExpired}},
+		"rent_at":              bson.M{"$gte": time.Now().AddDate(0, 0, -days)},
+		"credited_purchase_id": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	var rentals []models.Rental
+	if err := cursor.All(ctx, &rentals); err != nil {
+		return 0, nil, err
+	}
+
+	paid := 0
+	ids := make([]primitive.ObjectID, 0, len(rentals))
+	for _, r := range rentals {
+		paid += rentalAmountPaid(r) - r.RefundAmount
+		ids = append(ids, r.ID)
+	}
+
+	credit := paid * percent / 100
+	if credit > listPrice {
+		credit = listPrice
+	}
+	if credit <= 0 {
+		return 0, nil, nil
+	}
+	return credit, ids, nil
+}
+
+// ExtendRental godoc
+// @Summary Gia hạn một rental đang active
+// @Description Cộng thêm thời lượng của gói vào ExpireAt hiện tại và trừ phí gói. Không tạo rental mới.
+// @Tags Games
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Rental ID"
+// @Param body body map[string]string false "Rental plan code"
+// @Success 200 {object} gin.H
+// @Failure 400,403,404,409,500 {object} gin.H
+// @Router /rentals/{id}/extend [post]
+func ExtendRental(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.MustGet("user_id").(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	rentalID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rental ID"})
+		return
+	}
+
+	var req struct {
+		Plan string `json:"plan"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	var rental models.Rental
+	err = config.DB.Collection("rentals").FindOne(context.TODO(), bson.M{"_id": rentalID}).Decode(&rental)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rental not found"})
+		return
+	}
+	if rental.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not own this rental"})
+		return
+	}
+	if rental.Status != models.RentalActive || !time.Now().Before(rental.ExpireAt) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Rental is no longer active, rent the game again"})
+		return
+	}
+	if req.Plan == "" {
+		req.Plan = rental.Plan
+	}
+	if req.Plan == "" {
+		req.Plan = rentalplan.DefaultPlan
+	}
+
+	var game models.Game
+	if err := config.DB.Collection("games").FindOne(context.TODO(), bson.M{"_id": rental.GameID}).Decode(&game); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Game not found"})
+		return
+	}
+	plan, err := rentalplan.Find(context.TODO(), game, req.Plan)
+	if errors.Is(err, rentalplan.ErrPlanNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rental plan not available for this game"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load rental plans"})
+		return
+	}
+
+	extension := models.RentalExtension{
+		Plan:       plan.Code,
+		Price:      plan.Price,
+		ExtendedAt: time.Now(),
+		FromExpire: rental.ExpireAt,
+		ToExpire:   rental.ExpireAt.AddDate(0, 0, plan.DurationDays),
+	}
+
+	err = config.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		// Điều kiện expire_at cũ đảm bảo hai lần gia hạn đồng thời không
+		// cùng tính từ một mốc.
+		update := bson.M{
+			"$set":  bson.M{"expire_at": extension.ToExpire},
+			"$push": bson.M{"extensions": extension},
+		}
+		if rental.TotalPaid == 0 {
+			update["$set"].(bson.M)["total_paid"] = rental.Price + plan.Price
+		} else {
+			update["$inc"] = bson.M{"total_paid": plan.Price}
+		}
+		res, err := config.DB.Collection("rentals").UpdateOne(sessCtx, bson.M{
+			"_id":       rental.ID,
+			"status":    models.RentalActive,
+			"expire_at": rental.ExpireAt,
+		}, update)
+		if err != nil {
+			return err
+		}
+		if res.ModifiedCount == 0 {
+			return errRentalChanged
+		}
+
+		_, err = ledger.Debit(sessCtx, ledger.Movement{
+			UserID:  userID,
+			Amount:  plan.Price,
+			Type:    ledger.TypeRental,
+			RefType: "rental_extension",
+			RefID:   rental.ID,
+		})
+		return err
+	})
+	if errors.Is(err, errRentalChanged) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Rental was modified, please retry"})
+		return
+	}
+	if err != nil {
+		respondTransactionError(c, err, "Failed to extend rental")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Rental extended",
+		"expire_at": extension.ToExpire,
+		"extension": extension,
+	})
+}
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/models"
+	"go-mvc-demo/rentalplan"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetGameRentalPlans godoc
+// @Summary Lấy các gói thuê của một game
+// @Description Trả về các gói thuê đang áp dụng cho game cùng giá thuê đã tính
+// @Tags Games
+// @Produce json
+// @Param id path string true "Game ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,404,500 {object} map[string]string
+// @Router /games/{id}/rental-plans [get]
+func GetGameRentalPlans(c *gin.Context) {
+	gameID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var game models.Game
+	if err := config.DB.Collection("games").FindOne(context.TODO(), bson.M{"_id": gameID}).Decode(&game); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Game not found"})
+		return
+	}
+
+	plans, err := rentalplan.Resolve(context.TODO(), game)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load rental plans"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"game_id": game.ID, "plans": plans})
+}
+
+// ListRentalPlans godoc
+// @Summary Liệt kê cấu hình gói thuê (admin)
+// @Tags Admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/rental-plans [get]
+func ListRentalPlans(c *gin.Context) {
+	cursor, err := config.DB.Collection("rental_plans").Find(context.TODO(), bson.M{},
+		options.Find().SetSort(bson.D{{Key: "scope", Value: 1}, {Key: "duration_days", Value: 1}}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rental plans"})
+		return
+	}
+	plans := []models.RentalPlan{}
+	if err := cursor.All(context.TODO(), &plans); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode rental plans"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"plans": plans, "defaults": rentalplan.Defaults})
+}
+
+// UpsertRentalPlan godoc
+// @Summary Tạo hoặc cập nhật một gói thuê (admin)
+// @Description Gói được xác định bởi (code, scope, genre, game_id)
+// @Tags Admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param plan body models.RentalPlan true "Rental plan"
+// @Success 200 {object} models.RentalPlan
+// @Failure 400,500 {object} map[string]string
+// @Router /admin/rental-plans [put]
+func UpsertRentalPlan(c *gin.Context) {
+	var plan models.RentalPlan
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if plan.Code == "" || plan.PricePercent < 0 || plan.MinPrice < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required and prices must not be negative"})
+		return
+	}
+	if plan.Active && plan.DurationDays <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "duration_days must be positive"})
+		return
+	}
+
+	filter := bson.M{"code": plan.Code, "scope": plan.Scope}
+	switch plan.Scope {
+	case models.PlanScopeGlobal:
+		plan.Genre, plan.GameID = "", nil
+	case models.PlanScopeGenre:
+		if plan.Genre == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "genre is required for genre scope"})
+			return
+		}
+		plan.GameID = nil
+		filter["genre"] = plan.Genre
+	case models.PlanScopeGame:
+		if plan.GameID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "game_id is required for game scope"})
+			return
+		}
+		plan.Genre = ""
+		filter["game_id"] = *plan.GameID
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be global, genre or game"})
+		return
+	}
+
+	plan.UpdatedAt = time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"code":          plan.Code,
+			"name":          plan.Name,
+			"duration_days": plan.DurationDays,
+			"price_percent": plan.PricePercent,
+			"min_price":     plan.MinPrice,
+			"scope":         plan.Scope,
+			"genre":         plan.Genre,
+			"game_id":       plan.GameID,
+			"active":        plan.Active,
+			"updated_at":    plan.UpdatedAt,
+		},
+	}
+
+	err := config.DB.Collection("rental_plans").FindOneAndUpdate(context.TODO(), filter, update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&plan)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rental plan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// DeleteRentalPlan godoc
+// @Summary Xoá một cấu hình gói thuê (admin)
+// @Tags Admin
+// @Security BearerAuth
+// @Param id path string true "Rental plan ID"
+// @Success 200 {object} map[string]string
+// @Router /admin/rental-plans/{id} [delete]
+func DeleteRentalPlan(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	res, err := config.DB.Collection("rental_plans").DeleteOne(context.TODO(), bson.M{"_id": id})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Delete failed"})
+		return
+	}
+	if res.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rental plan not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Rental plan deleted"})
+}
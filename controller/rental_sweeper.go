@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/events"
+	"go-mvc-demo/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ExpireRentals chuyển các rental active đã quá ExpireAt sang expired theo
+// từng lô batchSize và phát sự kiện rental.expired cho mỗi rental.
+// Trả về tổng số rental đã được chuyển trạng thái.
+func ExpireRentals(ctx context.Context, batchSize int) (int, error) {
+	rentals := config.DB.Collection("rentals")
+	total := 0
+
+	for {
+		now := time.Now()
+		cursor, err := rentals.Find(ctx,
+			bson.M{"status": models.RentalActive, "expire_at": bson.M{"$lte": now}},
+			options.Find().SetSort(bson.M{"expire_at": 1}).SetLimit(int64(batchSize)),
+		)
+		if err != nil {
+			return total, err
+		}
+		var batch []models.Rental
+		if err := cursor.All(ctx, &batch); err != nil {
+			return total, err
+		}
+		if len(batch) == 0 {
+			return total, nil
+		}
+
+		for _, rental := range batch {
+			// Kiểm tra lại status và expire_at để không ghi đè rental vừa được
+			// trả/hoàn tiền hoặc vừa được gia hạn sau khi đọc lô này.
+			res, err := rentals.UpdateOne(ctx,
+				bson.M{"_id": rental.ID, "status": models.RentalActive, "expire_at": bson.M{"$lte": now}},
+				bson.M{"$set": bson.M{"status": models.RentalExpired, "expired_at": now}},
+			)
+			if err != nil {
+				return total, err
+			}
+			if res.ModifiedCount == 0 {
+				continue
+			}
+			total++
+
+			err = events.Emit(ctx, events.RentalExpired, map[string]interface{}{
+				"rental_id": rental.ID,
+				"user_id":   rental.UserID,
+				"game_id":   rental.GameID,
+				"expire_at": rental.ExpireAt,
+			})
+			if err != nil {
+				log.Printf("⚠️ Không ghi được sự kiện rental.expired cho %s: %v", rental.ID.Hex(), err)
+			}
+		}
+
+		if len(batch) < batchSize {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-mvc-demo/auth"
+	"go-mvc-demo/mailer"
+	"go-mvc-demo/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxDeviceNameLength giới hạn device_name do client gửi lên.
+const maxDeviceNameLength = 100
+
+// sessionInfo lấy thông tin thiết bị của request. deviceName do client đặt
+// (có thể rỗng); header X-Device-ID giúp nhận ra thiết bị khi user agent đổi.
+func sessionInfo(c *gin.Context, deviceName string) auth.SessionInfo {
+	deviceName = strings.TrimSpace(deviceName)
+	if len(deviceName) > maxDeviceNameLength {
+		deviceName = deviceName[:maxDeviceNameLength]
+	}
+	return auth.SessionInfo{
+		DeviceName: deviceName,
+		DeviceID:   strings.TrimSpace(c.GetHeader("X-Device-ID")),
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+}
+
+// issueSession tạo phiên đăng nhập mới và gửi email cảnh báo nếu đăng nhập
+// từ thiết bị lạ.
+func issueSession(c *gin.Context, user models.User, deviceName string) (auth.TokenPair, error) {
+	info := sessionInfo(c, deviceName)
+	tokens, err := auth.IssueTokenPair(context.TODO(), user, info)
+	if err != nil {
+		return tokens, err
+	}
+	if tokens.NewDevice {
+		// Không để SMTP chậm làm chậm đăng nhập.
+		go notifyNewSignIn(user, info, time.Now(), settingsFrom(c).Server.PublicBaseURL)
+	}
+	return tokens, nil
+}
+
+func notifyNewSignIn(user models.User, info auth.SessionInfo, at time.Time, baseURL string) {
+	device := info.DeviceName
+	if device == "" {
+		device = auth.DeviceNameFromUserAgent(info.UserAgent)
+	}
+	err := mailSender.Send(context.Background(), mailer.Message{
+		To:      user.Email,
+		Subject: "Đăng nhập mới vào tài khoản Game Library",
+		Body: fmt.Sprintf("Xin chào %s,\n\nTài khoản của bạn vừa được đăng nhập từ một thiết bị mới:\n\nThiết bị: %s\nIP: %s\nThời gian: %s\n\nNếu đó là bạn, hãy bỏ qua email này. Nếu không, hãy đăng xuất phiên đó trong mục Phiên đăng nhập (%s/api/sessions) và đổi mật khẩu ngay.\n",
+			user.Name, device, info.IP, at.Format(time.RFC1123), baseURL),
+	})
+	if err != nil {
+		log.Printf("⚠️ Không gửi được email đăng nhập mới cho %s: %v", user.Email, err)
+	}
+}
+
+// ListSessions godoc
+// @Summary Danh sách phiên đăng nhập
+// @Description Các thiết bị đang đăng nhập vào tài khoản, dùng gần nhất trước. current = true là phiên của token đang gọi.
+// @Tags Auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} map[string]interface{}
+// @Failure 400,500 {object} ErrorResponse
+// @Router /api/sessions [get]
+func ListSessions(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.MustGet("user_id").(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	list, err := auth.ListSessions(context.TODO(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot list sessions"})
+		return
+	}
+
+	current := c.GetString("session_id")
+	result := make([]gin.H, 0, len(list))
+	for _, s := range list {
+		result = append(result, gin.H{
+			"id":           s.ID,
+			"device_name":  s.DeviceName,
+			"ip":           s.IP,
+			"user_agent":   s.UserAgent,
+			"created_at":   s.CreatedAt,
+			"last_seen_at": s.LastSeenAt,
+			"last_seen_ip": s.LastSeenIP,
+			"expires_at":   s.ExpiresAt,
+			"current":      s.ID.Hex() == current,
+		})
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// RevokeSession godoc
+// @Summary Đăng xuất một phiên
+// @Description Thu hồi phiên cùng mọi access/refresh token của nó; thiết bị đó phải đăng nhập lại.
+// @Tags Auth
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Session ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400,404,500 {object} ErrorResponse
+// @Router /api/sessions/{id} [delete]
+func RevokeSession(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.MustGet("user_id").(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	err = auth.RevokeSession(context.TODO(), userID, sessionID, "session_revoked")
+	if errors.Is(err, auth.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot revoke session"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"go-mvc-demo/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// settingsKey là key chứa cấu hình trong gin.Context.
+const settingsKey = "settings"
+
+// Settings gắn cấu hình đã được kiểm tra lúc khởi động vào mỗi request. main
+// đăng ký middleware này trước mọi route; controller đọc lại qua settingsFrom.
+func Settings(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(settingsKey, cfg)
+		c.Next()
+	}
+}
+
+// settingsFrom trả về cấu hình của request. Route không gắn Settings (ví dụ
+// trong test) nhận cấu hình mặc định.
+func settingsFrom(c *gin.Context) *config.Config {
+	if cfg, ok := c.Get(settingsKey); ok {
+		return cfg.(*config.Config)
+	}
+	return config.Default()
+}
@@ -2,16 +2,44 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"time"
 
 	"go-mvc-demo/config"
+	"go-mvc-demo/entitlements"
+	"go-mvc-demo/ledger"
 	"go-mvc-demo/models"
+	"go-mvc-demo/rentalplan"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
+var (
+	errAlreadyOwned  = errors.New("game already owned")
+	errAlreadyRented = errors.New("game already rented")
+)
+
+// respondTransactionError chuyển lỗi từ transaction thành response phù hợp.
+func respondTransactionError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, ledger.ErrUserNotFound):
+		c.JSON(404, gin.H{"error": "User not found"})
+	case errors.Is(err, ledger.ErrInsufficientBalance):
+		c.JSON(400, gin.H{"error": "Insufficient coin balance"})
+	case errors.Is(err, errAlreadyOwned):
+		c.JSON(409, gin.H{"error": "You already own this game"})
+	case errors.Is(err, errAlreadyRented):
+		c.JSON(409, gin.H{"error": "Game is already rented, extend the current rental instead"})
+	default:
+		c.JSON(500, gin.H{"error": fallback})
+	}
+}
+
 // BuyGame godoc
 // @Summary Buy a game
 // @Tags Games
@@ -26,13 +54,14 @@ func BuyGame(c *gin.Context) {
 	userID := c.MustGet("user_id").(string)
 	gameID := c.Param("id")
 
-	userObjID, _ := primitive.ObjectIDFromHex(userID)
-	gameObjID, _ := primitive.ObjectIDFromHex(gameID)
-
-	var user models.User
-	err := config.DB.Collection("users").FindOne(context.TODO(), bson.M{"_id": userObjID}).Decode(&user)
+	userObjID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
-		c.JSON(404, gin.H{"error": "User not found"})
+		c.JSON(400, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	gameObjID, err := primitive.ObjectIDFromHex(gameID)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid game ID"})
 		return
 	}
 
@@ -43,169 +72,88 @@ func BuyGame(c *gin.Context) {
 		return
 	}
 
-	if user.CoinBalance < game.Price {
-		c.JSON(400, gin.H{"error": "Insufficient coin balance"})
-		return
-	}
-
-	// Trừ tiền và ghi nhận
-	config.DB.Collection("users").UpdateOne(context.TODO(), bson.M{"_id": userObjID}, bson.M{
-		"$inc": bson.M{"coin_balance": -game.Price},
-	})
-
 	purchase := models.Purchase{
 		ID:         primitive.NewObjectID(),
 		UserID:     userObjID,
 		GameID:     gameObjID,
 		PurchaseAt: time.Now(),
+		ListPrice:  game.Price,
 		Price:      game.Price,
+		Status:     models.PurchaseCompleted,
 	}
 
-	_, err = config.DB.Collection("purchases").InsertOne(context.TODO(), purchase)
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to record purchase"})
-		return
-	}
-
-	c.JSON(200, gin.H{"message": "Purchase successful"})
-}
-
-type GameWithPurchaseInfo struct {
-	ID          primitive.ObjectID `json:"id"`
-	Title       string             `json:"title"`
-	Image       string             `json:"image"`
-	Price       int                `json:"price"`
-	IsPurchased bool               `json:"is_purchased"`
-	IsRented    bool               `json:"is_rented"`
-	ExpireAt    *time.Time         `json:"expire_at,omitempty"`
-}
-
-func GetUserGames(c *gin.Context) {
-	userID := c.MustGet("user_id").(string)
-	userObjID, _ := primitive.ObjectIDFromHex(userID)
-
-	// Get all purchases for the user
-	purchaseCursor, err := config.DB.Collection("purchases").Find(context.TODO(), bson.M{"user_id": userObjID})
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch purchases"})
-		return
-	}
-	defer purchaseCursor.Close(context.TODO())
-
-	var purchases []models.Purchase
-	if err = purchaseCursor.All(context.TODO(), &purchases); err != nil {
-		c.JSON(500, gin.H{"error": "Failed to decode purchases"})
-		return
-	}
-
-	// Get all rentals for the user (including expired ones)
-	rentalCursor, err := config.DB.Collection("rentals").Find(context.TODO(), bson.M{"user_id": userObjID})
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch rentals"})
-		return
-	}
-	defer rentalCursor.Close(context.TODO())
-
-	var rentals []models.Rental
-	if err = rentalCursor.All(context.TODO(), &rentals); err != nil {
-		c.JSON(500, gin.H{"error": "Failed to decode rentals"})
-		return
-	}
-
-	// Collect all unique game IDs
-	gameIDs := make([]primitive.ObjectID, 0)
-	gameMap := make(map[primitive.ObjectID]bool)
-
-	for _, p := range purchases {
-		if !gameMap[p.GameID] {
-			gameMap[p.GameID] = true
-			gameIDs = append(gameIDs, p.GameID)
+	// Trừ tiền và ghi nhận trong cùng một transaction
+	rentalCfg := settingsFrom(c).Rental
+	err = config.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		ent, err := entitlements.Check(sessCtx, userObjID, gameObjID)
+		if err != nil {
+			return err
 		}
-	}
-
-	for _, r := range rentals {
-		if !gameMap[r.GameID] {
-			gameMap[r.GameID] = true
-			gameIDs = append(gameIDs, r.GameID)
+		if ent.Kind == entitlements.KindOwned {
+			return errAlreadyOwned
 		}
-	}
 
-	// Get game details for all unique games
-	var games []models.Game
-	if len(gameIDs) > 0 {
-		cursor, err := config.DB.Collection("games").Find(context.TODO(), bson.M{"_id": bson.M{"$in": gameIDs}})
+		credit, rentalIDs, err := rentToOwnCredit(sessCtx, rentalCfg, userObjID, gameObjID, game.Price)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to fetch games"})
-			return
-		}
-		defer cursor.Close(context.TODO())
-
-		if err = cursor.All(context.TODO(), &games); err != nil {
-			c.JSON(500, gin.H{"error": "Failed to decode games"})
-			return
+			return err
 		}
-	}
-
-	// Create a map for quick game lookup
-	gameInfoMap := make(map[primitive.ObjectID]models.Game)
-	for _, g := range games {
-		gameInfoMap[g.ID] = g
-	}
-
-	// Create response with combined information
-	response := make([]GameWithPurchaseInfo, 0)
-
-	// Process purchased games
-	for _, p := range purchases {
-		if game, exists := gameInfoMap[p.GameID]; exists {
-			response = append(response, GameWithPurchaseInfo{
-				ID:          game.ID,
-				Title:       game.Name,
-				Image:       game.ImageURL,
-				Price:       game.Price,
-				IsPurchased: true,
-				IsRented:    false,
-			})
+		purchase.RentalCredit = credit
+		purchase.Price = game.Price - credit
+
+		// Unique index (user_id, game_id) trên purchases completed chặn nốt
+		// trường hợp hai request mua cùng lúc.
+		if _, err := config.DB.Collection("purchases").InsertOne(sessCtx, purchase); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return errAlreadyOwned
+			}
+			return err
 		}
-	}
-
-	// Process rented games
-	for _, r := range rentals {
-		if game, exists := gameInfoMap[r.GameID]; exists {
-			// Check if this game is already in response (purchased)
-			found := false
-			for i, res := range response {
-				if res.ID == r.GameID {
-					response[i].IsRented = true
-					response[i].ExpireAt = &r.ExpireAt
-					found = true
-					break
-				}
+		if len(rentalIDs) > 0 {
+			rentals := config.DB.Collection("rentals")
+			_, err := rentals.UpdateMany(sessCtx,
+				bson.M{"_id": bson.M{"$in": rentalIDs}},
+				bson.M{"$set": bson.M{"credited_purchase_id": purchase.ID}},
+			)
+			if err != nil {
+				return err
 			}
-
-			if !found {
-				response = append(response, GameWithPurchaseInfo{
-					ID:          game.ID,
-					Title:       game.Name,
-					Image:       game.ImageURL,
-					Price:       game.Price,
-					IsPurchased: false,
-					IsRented:    true,
-					ExpireAt:    &r.ExpireAt,
-				})
+			// Rental đã tính vào giá mua thì kết thúc luôn để không thể trả
+			// sớm lấy lại tiền thuê lần nữa.
+			now := time.Now()
+			_, err = rentals.UpdateMany(sessCtx,
+				bson.M{"_id": bson.M{"$in": rentalIDs}, "status": models.RentalActive},
+				bson.M{"$set": bson.M{"status": models.RentalExpired, "expired_at": now}},
+			)
+			if err != nil {
+				return err
 			}
 		}
+		_, err = ledger.Debit(sessCtx, ledger.Movement{
+			UserID:  userObjID,
+			Amount:  purchase.Price,
+			Type:    ledger.TypePurchase,
+			RefType: "purchase",
+			RefID:   purchase.ID,
+		})
+		return err
+	})
+	if err != nil {
+		respondTransactionError(c, err, "Failed to record purchase")
+		return
 	}
 
-	c.JSON(200, gin.H{"games": response})
+	c.JSON(200, gin.H{"message": "Purchase successful", "purchase": purchase})
 }
 
 // RentGame godoc
-// @Summary Rent a game for 3 days
+// @Summary Rent a game
+// @Description Thuê game theo gói (1d, 3d, 7d, 30d...). Mặc định gói 3d.
 // @Tags Games
 // @Security BearerAuth
+// @Accept json
 // @Produce json
 // @Param id path string true "Game ID"
+// @Param body body map[string]string false "Rental plan code"
 // @Success 200 {object} gin.H
 // @Failure 400,404,500 {object} gin.H
 // @Router /rent/:id  [post]
@@ -214,46 +162,108 @@ func RentGame(c *gin.Context) {
 	userID := c.MustGet("user_id").(string)
 	gameID := c.Param("id")
 
-	userObjID, _ := primitive.ObjectIDFromHex(userID)
-	gameObjID, _ := primitive.ObjectIDFromHex(gameID)
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	gameObjID, err := primitive.ObjectIDFromHex(gameID)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid game ID"})
+		return
+	}
 
 	var game models.Game
-	err := config.DB.Collection("games").FindOne(context.TODO(), bson.M{"_id": gameObjID}).Decode(&game)
+	err = config.DB.Collection("games").FindOne(context.TODO(), bson.M{"_id": gameObjID}).Decode(&game)
 	if err != nil {
 		c.JSON(404, gin.H{"error": "Game not found"})
 		return
 	}
 
-	var user models.User
-	config.DB.Collection("users").FindOne(context.TODO(), bson.M{"_id": userObjID}).Decode(&user)
-
-	rentPrice := game.Price / 10
-	if user.CoinBalance < rentPrice {
-		c.JSON(400, gin.H{"error": "Insufficient coin balance"})
+	var req struct {
+		Plan string `json:"plan"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(400, gin.H{"error": "Invalid input"})
 		return
 	}
+	if req.Plan == "" {
+		req.Plan = rentalplan.DefaultPlan
+	}
 
-	config.DB.Collection("users").UpdateOne(context.TODO(), bson.M{"_id": userObjID}, bson.M{
-		"$inc": bson.M{"coin_balance": -rentPrice},
-	})
+	plan, err := rentalplan.Find(context.TODO(), game, req.Plan)
+	if errors.Is(err, rentalplan.ErrPlanNotFound) {
+		c.JSON(400, gin.H{"error": "Rental plan not available for this game"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to load rental plans"})
+		return
+	}
+	rentPrice := plan.Price
 
 	now := time.Now()
 	rental := models.Rental{
-		ID:       primitive.NewObjectID(),
-		UserID:   userObjID,
-		GameID:   gameObjID,
-		RentAt:   now,
-		ExpireAt: now.Add(3 * 24 * time.Hour),
-		Status:   "active",
+		ID:        primitive.NewObjectID(),
+		UserID:    userObjID,
+		GameID:    gameObjID,
+		RentAt:    now,
+		ExpireAt:  now.AddDate(0, 0, plan.DurationDays),
+		Status:    models.RentalActive,
+		Plan:      plan.Code,
+		Price:     rentPrice,
+		TotalPaid: rentPrice,
 	}
 
-	_, err = config.DB.Collection("rentals").InsertOne(context.TODO(), rental)
+	err = config.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		ent, err := entitlements.Check(sessCtx, userObjID, gameObjID)
+		if err != nil {
+			return err
+		}
+		switch ent.Kind {
+		case entitlements.KindOwned:
+			return errAlreadyOwned
+		case entitlements.KindRented:
+			return errAlreadyRented
+		}
+
+		// Rental đã quá hạn nhưng sweeper chưa chạy tới: chuyển sang expired
+		// ngay để không vướng unique index trên rental active.
+		_, err = config.DB.Collection("rentals").UpdateMany(sessCtx, bson.M{
+			"user_id":   userObjID,
+			"game_id":   gameObjID,
+			"status":    models.RentalActive,
+			"expire_at": bson.M{"$lte": now},
+		}, bson.M{"$set": bson.M{"status": models.RentalExpired, "expired_at": now}})
+		if err != nil {
+			return err
+		}
+
+		// Không cho thuê chồng: nếu đang có rental còn hạn thì phải gia hạn.
+		if _, err := config.DB.Collection("rentals").InsertOne(sessCtx, rental); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return errAlreadyRented
+			}
+			return err
+		}
+		_, err = ledger.Debit(sessCtx, ledger.Movement{
+			UserID:  userObjID,
+			Amount:  rentPrice,
+			Type:    ledger.TypeRental,
+			RefType: "rental",
+			RefID:   rental.ID,
+		})
+		return err
+	})
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to record rental"})
+		respondTransactionError(c, err, "Failed to record rental")
 		return
 	}
 
-	c.JSON(200, gin.H{"message": "Game rented for 3 days"})
+	c.JSON(200, gin.H{
+		"message": fmt.Sprintf("Game rented for %d days", plan.DurationDays),
+		"rental":  rental,
+	})
 }
 
 // CheckActiveRental godoc
@@ -272,17 +282,11 @@ func CheckActiveRental(c *gin.Context) {
 	userObjID, _ := primitive.ObjectIDFromHex(userID)
 	gameObjID, _ := primitive.ObjectIDFromHex(gameID)
 
-	var rental models.Rental
-	err := config.DB.Collection("rentals").FindOne(context.TODO(), bson.M{
-		"user_id": userObjID,
-		"game_id": gameObjID,
-		"status":  "active",
-	}).Decode(&rental)
-
-	if err != nil || time.Now().After(rental.ExpireAt) {
+	ent, err := entitlements.Check(context.TODO(), userObjID, gameObjID)
+	if err != nil || ent.Kind != entitlements.KindRented {
 		c.JSON(200, gin.H{"active": false})
 		return
 	}
 
-	c.JSON(200, gin.H{"active": true, "expire_at": rental.ExpireAt})
+	c.JSON(200, gin.H{"active": true, "expire_at": ent.ExpireAt})
 }
@@ -2,14 +2,19 @@ package controllers
 
 import (
 	"context"
+	"go-mvc-demo/auth"
 	"go-mvc-demo/config"
+	"go-mvc-demo/ledger"
 	"go-mvc-demo/models"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // GetUsers godoc
@@ -73,6 +78,21 @@ func PromoteUserToAdmin(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "User promoted to admin"})
 }
 
+// hasPermission kiểm tra role của user đang đăng nhập có quyền p hay không.
+// Request dùng API key còn cần key được cấp scope p.
+func hasPermission(c *gin.Context, p auth.Permission) bool {
+	if scopes, isKey := c.Get("api_key_scopes"); isKey && !auth.ScopeIncludes(scopes.([]auth.Permission), p) {
+		return false
+	}
+	return auth.HasPermission(c.GetString("role"), p)
+}
+
+// isSelfOrUserAdmin: user thường chỉ được thao tác trên chính tài khoản của
+// mình; user có quyền users:admin thao tác được trên mọi tài khoản.
+func isSelfOrUserAdmin(c *gin.Context, userID primitive.ObjectID) bool {
+	return c.GetString("user_id") == userID.Hex() || hasPermission(c, auth.PermUsersAdmin)
+}
+
 func GetUserByID(c *gin.Context) {
 	idParam := c.Param("id")
 	objID, err := primitive.ObjectIDFromHex(idParam)
@@ -80,6 +100,10 @@ func GetUserByID(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
+	if !isSelfOrUserAdmin(c, objID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
 
 	var user models.User
 	err = config.DB.Collection("users").FindOne(context.TODO(), bson.M{"_id": objID}).Decode(&user)
@@ -98,15 +122,23 @@ func CreateUser(c *gin.Context) {
 		return
 	}
 
+	email, ok := normalizeEmail(input.Email)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email"})
+		return
+	}
 	input.ID = primitive.NewObjectID()
-	input.CoinBalance = 1000
+	input.Email = email
 	input.Role = "user"
 
-	_, err := config.DB.Collection("users").InsertOne(context.TODO(), input)
-	if err != nil {
+	if err := createUserWithBonus(context.TODO(), input); err != nil {
+		if respondDuplicateEmail(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	input.CoinBalance = signupBonus
 
 	c.JSON(http.StatusCreated, input)
 }
@@ -119,29 +151,108 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
-	var input models.User
+	if !isSelfOrUserAdmin(c, objID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	var input struct {
+		Name        *string `json:"name"`
+		Email       *string `json:"email"`
+		CoinBalance *int    `json:"coin_balance"`
+		Role        *string `json:"role"`
+	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
 
-	update := bson.M{
-		"$set": bson.M{
-			"name":         input.Name,
-			"email":        input.Email,
-			"coin_balance": input.CoinBalance,
-			"role":         input.Role,
-			"updated_at":   time.Now(),
-		},
+	// User tự sửa hồ sơ chỉ được đổi name/email; role và coin_balance cần quyền riêng.
+	if input.Role != nil && !hasPermission(c, auth.PermUsersAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to change role", "permission": auth.PermUsersAdmin})
+		return
+	}
+	if input.CoinBalance != nil && !hasPermission(c, auth.PermWalletAdjust) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to change coin balance", "permission": auth.PermWalletAdjust})
+		return
+	}
+	if input.Role != nil && !auth.ValidRole(*input.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	set := bson.M{"updated_at": time.Now()}
+	if input.Name != nil {
+		set["name"] = *input.Name
 	}
+	// Email mới chỉ được lưu vào pending_email; email đăng nhập đổi khi user
+	// xác minh qua link gửi tới địa chỉ mới.
+	newEmail := ""
+	if input.Email != nil {
+		email, ok := normalizeEmail(*input.Email)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email"})
+			return
+		}
+		newEmail = email
+	}
+	if input.Role != nil {
+		set["role"] = *input.Role
+	}
+	update := bson.M{"$set": set}
 
-	_, err = config.DB.Collection("users").UpdateByID(context.TODO(), objID, update)
+	// coin_balance không được ghi đè trực tiếp: phần chênh lệch được ghi
+	// thành một bút toán điều chỉnh trong ledger.
+	var user models.User
+	err = config.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		if err := config.DB.Collection("users").FindOne(sessCtx, bson.M{"_id": objID}).Decode(&user); err != nil {
+			return ledger.ErrUserNotFound
+		}
+		if newEmail == user.Email {
+			newEmail = ""
+		}
+		if newEmail != "" {
+			taken, err := config.DB.Collection("users").CountDocuments(sessCtx,
+				bson.M{"email": newEmail}, options.Count().SetCollation(config.EmailCollation))
+			if err != nil {
+				return err
+			}
+			if taken > 0 {
+				return errEmailTaken
+			}
+			set["pending_email"] = newEmail
+		}
+		if _, err := config.DB.Collection("users").UpdateByID(sessCtx, objID, update); err != nil {
+			return err
+		}
+		if input.CoinBalance == nil {
+			return nil
+		}
+		note := "admin update via PUT /users/:id by " + c.GetString("user_id")
+		_, err := ledger.Adjust(sessCtx, objID, *input.CoinBalance-user.CoinBalance, note)
+		return err
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if respondDuplicateEmail(c, err) {
+			return
+		}
+		respondTransactionError(c, err, "Failed to update user")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User updated"})
+	if newEmail == "" {
+		c.JSON(http.StatusOK, gin.H{"message": "User updated"})
+		return
+	}
+	if err := sendEmailChangeEmail(context.TODO(), settingsFrom(c).Server.PublicBaseURL, user, newEmail); err != nil {
+		log.Printf("⚠️ Không gửi được email xác minh đổi email cho user %s: %v", user.ID.Hex(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot send verification email"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "User updated; the new email takes effect after it is verified",
+		"pending_email": newEmail,
+	})
 }
 
 func DeleteUser(c *gin.Context) {
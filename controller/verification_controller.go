@@ -0,0 +1,267 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-mvc-demo/auth"
+	"go-mvc-demo/config"
+	"go-mvc-demo/ledger"
+	"go-mvc-demo/mailer"
+	"go-mvc-demo/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// emailVerificationTTL là thời hạn của link xác minh email.
+const emailVerificationTTL = 24 * time.Hour
+
+// errEmailTaken: email mới đã được tài khoản khác dùng.
+var errEmailTaken = errors.New("email already registered")
+
+// resendVerificationLimit: mỗi user tối đa 1 email/phút và 5 email/ngày.
+var resendVerificationLimit = auth.RateLimit{MinInterval: time.Minute, MaxPerDay: 5}
+
+var mailSender mailer.Mailer = mailer.LogMailer{}
+
+// SetMailer cấu hình mailer dùng để gửi email cho user.
+func SetMailer(m mailer.Mailer) {
+	mailSender = m
+}
+
+// normalizeEmail kiểm tra định dạng và chuyển email về chữ thường.
+func normalizeEmail(raw string) (string, bool) {
+	email := strings.ToLower(strings.TrimSpace(raw))
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", false
+	}
+	return email, true
+}
+
+func sendVerificationEmail(ctx context.Context, baseURL string, user models.User, limit auth.RateLimit) error {
+	token, err := auth.IssueOneTimeToken(ctx, user.ID, auth.PurposeVerifyEmail, emailVerificationTTL, limit)
+	if err != nil {
+		return err
+	}
+	link := baseURL + "/auth/verify?token=" + url.QueryEscape(token)
+	return mailSender.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Xác minh email Game Library",
+		Body: fmt.Sprintf("Xin chào %s,\n\nNhấn vào link sau để xác minh email và nhận %d coin khởi tạo:\n%s\n\nLink hết hạn sau %d giờ.\n",
+			user.Name, signupBonus, link, int(emailVerificationTTL.Hours())),
+	})
+}
+
+// sendEmailChangeEmail gửi link xác minh tới email mới và báo cho email
+// cũ biết có yêu cầu đổi email.
+func sendEmailChangeEmail(ctx context.Context, baseURL string, user models.User, newEmail string) error {
+	token, err := auth.IssueOneTimeToken(ctx, user.ID, auth.PurposeChangeEmail, emailVerificationTTL, resendVerificationLimit)
+	if err != nil {
+		return err
+	}
+	link := baseURL + "/auth/confirm-email?token=" + url.QueryEscape(token)
+	err = mailSender.Send(ctx, mailer.Message{
+		To:      newEmail,
+		Subject: "Xác minh email mới cho Game Library",
+		Body: fmt.Sprintf("Xin chào %s,\n\nNhấn vào link sau để dùng địa chỉ này làm email đăng nhập:\n%s\n\nLink hết hạn sau %d giờ.\n",
+			user.Name, link, int(emailVerificationTTL.Hours())),
+	})
+	if err != nil {
+		return err
+	}
+	if err := mailSender.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Yêu cầu đổi email Game Library",
+		Body: fmt.Sprintf("Xin chào %s,\n\nCó yêu cầu đổi email đăng nhập của bạn sang %s. Email chỉ đổi sau khi địa chỉ mới được xác minh. Nếu bạn không yêu cầu, hãy đổi mật khẩu ngay.\n",
+			user.Name, newEmail),
+	}); err != nil {
+		log.Printf("⚠️ Không gửi được thông báo đổi email cho %s: %v", user.Email, err)
+	}
+	return nil
+}
+
+// ConfirmEmailChange godoc
+// @Summary Xác nhận đổi email
+// @Description Đổi email đăng nhập sang pending_email bằng token trong link đã gửi tới email mới.
+// @Tags Auth
+// @Produce json
+// @Param token query string true "Email change token"
+// @Success 200 {object} MessageResponse
+// @Failure 400,409 {object} ErrorResponse
+// @Router /auth/confirm-email [get]
+func ConfirmEmailChange(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	ctx := context.TODO()
+	userID, err := auth.ConsumeOneTimeToken(ctx, auth.PurposeChangeEmail, token)
+	if errors.Is(err, auth.ErrInvalidOneTimeToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification link"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot change email"})
+		return
+	}
+
+	var user models.User
+	err = config.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+	if err != nil || user.PendingEmail == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification link"})
+		return
+	}
+	now := time.Now()
+	_, err = config.DB.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": userID, "pending_email": user.PendingEmail},
+		bson.M{
+			"$set":   bson.M{"email": user.PendingEmail, "email_verified": true, "email_verified_at": now, "updated_at": now},
+			"$unset": bson.M{"pending_email": ""},
+		},
+	)
+	if err != nil {
+		if respondDuplicateEmail(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot change email"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Email changed", "email": user.PendingEmail})
+}
+
+// VerifyEmail godoc
+// @Summary Xác minh email
+// @Description Xác minh email bằng token trong link đã gửi và cộng coin khởi tạo
+// @Tags Auth
+// @Produce json
+// @Param token query string true "Verification token"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /auth/verify [get]
+func VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	userID, err := auth.ConsumeOneTimeToken(context.TODO(), auth.PurposeVerifyEmail, token)
+	if errors.Is(err, auth.ErrInvalidOneTimeToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification link"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot verify email"})
+		return
+	}
+
+	// Chỉ lần chuyển email_verified false -> true mới cộng coin, nên bấm
+	// link nhiều lần (hoặc request đồng thời) cũng không nhận coin hai lần.
+	granted := false
+	err = config.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		granted = false
+		now := time.Now()
+		res, err := config.DB.Collection("users").UpdateOne(sessCtx,
+			bson.M{"_id": userID, "email_verified": false},
+			bson.M{"$set": bson.M{"email_verified": true, "email_verified_at": now}},
+		)
+		if err != nil || res.ModifiedCount == 0 {
+			return err
+		}
+		granted = true
+		_, err = ledger.Credit(sessCtx, ledger.Movement{
+			UserID:  userID,
+			Amount:  signupBonus,
+			Type:    ledger.TypeBonus,
+			RefType: "signup",
+			RefID:   userID,
+		})
+		return err
+	})
+	if err != nil {
+		respondTransactionError(c, err, "Cannot verify email")
+		return
+	}
+
+	if !granted {
+		c.JSON(http.StatusOK, gin.H{"message": "Email already verified"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified", "bonus": signupBonus})
+}
+
+// ResendVerification godoc
+// @Summary Gửi lại email xác minh
+// @Description Trả về cùng một thông báo dù email có tồn tại hay không. Giới hạn 1 lần/phút và 5 lần/ngày cho mỗi tài khoản.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param body body map[string]string true "email"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /auth/resend-verification [post]
+func ResendVerification(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is required"})
+		return
+	}
+
+	response := gin.H{"message": "If the account exists and is not verified, a verification email has been sent"}
+
+	var user models.User
+	err := config.DB.Collection("users").FindOne(context.TODO(),
+		bson.M{"email": strings.TrimSpace(input.Email), "email_verified": false},
+		options.FindOne().SetCollation(config.EmailCollation),
+	).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot send verification email"})
+		return
+	}
+
+	err = sendVerificationEmail(context.TODO(), settingsFrom(c).Server.PublicBaseURL, user, resendVerificationLimit)
+	var limited *auth.RateLimitError
+	if errors.As(err, &limited) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many verification emails, try again later"})
+		return
+	}
+	if err != nil {
+		log.Printf("⚠️ Không gửi được email xác minh cho %s: %v", user.Email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// respondDuplicateEmail trả về 409 nếu err là lỗi trùng email.
+func respondDuplicateEmail(c *gin.Context, err error) bool {
+	if mongo.IsDuplicateKeyError(err) || errors.Is(err, errEmailTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+		return true
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/config/mongotest"
+	"go-mvc-demo/ledger"
+	"go-mvc-demo/models"
+	"go-mvc-demo/payment"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func seedGames(t *testing.T, n, price int) []primitive.ObjectID {
+	t.Helper()
+	ids := make([]primitive.ObjectID, n)
+	docs := make([]interface{}, n)
+	for i := range ids {
+		ids[i] = primitive.NewObjectID()
+		docs[i] = models.Game{ID: ids[i], Name: "Game " + ids[i].Hex(), Price: price}
+	}
+	if _, err := config.DB.Collection("games").InsertMany(context.Background(), docs); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+// TestWalletConcurrency chạy song song mua, thuê và webhook nạp coin trên
+// cùng một ví: số dư không bao giờ âm, khớp sổ cái và khớp với số giao
+// dịch thành công.
+func TestWalletConcurrency(t *testing.T) {
+	mongotest.Setup(t)
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+
+	const opening, buyPrice, rentListPrice, rechargeAmount = 1000, 300, 1000, 200
+	userID := primitive.NewObjectID()
+	if _, err := config.DB.Collection("users").InsertOne(ctx, models.User{ID: userID, Email: "wallet@example.com", Role: "user", EmailVerified: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ledger.Credit(ctx, ledger.Movement{UserID: userID, Amount: opening, Type: ledger.TypeBonus}); err != nil {
+		t.Fatal(err)
+	}
+
+	buyGames := seedGames(t, 8, buyPrice)
+	rentGames := seedGames(t, 8, rentListPrice) // gói 3d mặc định: 10% = 100 coin
+
+	var recharges []models.Recharge
+	for i := 0; i < 6; i++ {
+		r := models.Recharge{
+			ID:        primitive.NewObjectID(),
+			UserID:    userID,
+			Amount:    rechargeAmount,
+			Status:    models.RechargePending,
+			Provider:  "fake",
+			Reference: "chk_" + primitive.NewObjectID().Hex(),
+			CreatedAt: time.Now(),
+		}
+		recharges = append(recharges, r)
+		if _, err := config.DB.Collection("recharges").InsertOne(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", userID.Hex()) })
+	r.POST("/buy/:id", BuyGame)
+	r.POST("/rent/:id", RentGame)
+
+	var wg sync.WaitGroup
+	call := func(path string) {
+		defer wg.Done()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+		switch w.Code {
+		case http.StatusOK, http.StatusBadRequest, http.StatusConflict:
+		default:
+			t.Errorf("POST %s = %d: %s", path, w.Code, w.Body)
+		}
+	}
+	for _, id := range buyGames {
+		// Mua trùng cùng một game: chỉ một lần được trừ tiền.
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go call("/buy/" + id.Hex())
+		}
+	}
+	for _, id := range rentGames {
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go call("/rent/" + id.Hex())
+		}
+	}
+	for _, rc := range recharges {
+		// Cổng thanh toán gửi mỗi webhook hai lần.
+		event := payment.Event{ID: "evt_" + rc.ID.Hex(), Reference: rc.Reference, Status: payment.StatusSucceeded, Amount: rc.Amount, OccurredAt: time.Now()}
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				applyPaymentEvent(ctx, "fake", event)
+			}()
+		}
+	}
+	wg.Wait()
+
+	purchases, err := config.DB.Collection("purchases").CountDocuments(ctx, bson.M{"user_id": userID, "status": models.PurchaseCompleted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rentals, err := config.DB.Collection("rentals").CountDocuments(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	credited, err := config.DB.Collection("recharges").CountDocuments(ctx, bson.M{"user_id": userID, "status": models.RechargeSuccess})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credited != int64(len(recharges)) {
+		t.Errorf("%d recharges succeeded, want %d", credited, len(recharges))
+	}
+	for _, id := range buyGames {
+		n, _ := config.DB.Collection("purchases").CountDocuments(ctx, bson.M{"user_id": userID, "game_id": id})
+		if n > 1 {
+			t.Errorf("game %s purchased %d times", id.Hex(), n)
+		}
+	}
+	for _, id := range rentGames {
+		n, _ := config.DB.Collection("rentals").CountDocuments(ctx, bson.M{"user_id": userID, "game_id": id, "status": models.RentalActive})
+		if n > 1 {
+			t.Errorf("game %s has %d active rentals", id.Hex(), n)
+		}
+	}
+
+	rec, err := ledger.Reconcile(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.StoredBalance < 0 {
+		t.Fatalf("coin_balance went negative: %d", rec.StoredBalance)
+	}
+	if !rec.Reconciled {
+		t.Fatalf("ledger drift: %+v", rec)
+	}
+	want := opening + int(credited)*rechargeAmount - int(purchases)*buyPrice - int(rentals)*rentListPrice/10
+	if rec.StoredBalance != want {
+		t.Fatalf("balance = %d, want %d (purchases %d, rentals %d, recharges %d)", rec.StoredBalance, want, purchases, rentals, credited)
+	}
+	negative, err := config.DB.Collection("ledger_entries").CountDocuments(ctx, bson.M{"user_id": userID, "balance_after": bson.M{"$lt": 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if negative > 0 {
+		t.Fatalf("%d ledger entries recorded a negative balance", negative)
+	}
+}
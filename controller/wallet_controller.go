@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/ledger"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetLedger godoc
+// @Summary Xem sổ cái ví coin của người dùng
+// @Description Trả về các bút toán của ví (mới nhất trước) kèm đối soát số dư
+// @Tags Wallet
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Page size" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,500 {object} map[string]string
+// @Router /wallet/ledger [get]
+func GetLedger(c *gin.Context) {
+	userIDStr, _ := c.Get("user_id")
+	userID, err := primitive.ObjectIDFromHex(userIDStr.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	page, limit, ok := parsePagination(c, 20)
+	if !ok {
+		return
+	}
+
+	entries, total, err := ledger.Entries(context.TODO(), userID, int64((page-1)*limit), int64(limit))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ledger"})
+		return
+	}
+
+	reconciliation, err := ledger.Reconcile(context.TODO(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile balance"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries":        entries,
+		"page":           page,
+		"limit":          limit,
+		"total":          total,
+		"reconciliation": reconciliation,
+	})
+}
+
+// ReconcileWallet godoc
+// @Summary Đối soát ví coin của một user với sổ cái
+// @Description Ghi bút toán số dư đầu kỳ nếu coin_balance lệch với ledger (tài khoản cũ trước khi có ledger)
+// @Tags Wallet
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} ledger.Reconciliation
+// @Failure 400,404,500 {object} map[string]string
+// @Router /wallet/reconcile/{id} [post]
+func ReconcileWallet(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var result ledger.Reconciliation
+	err = config.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		var err error
+		result, err = ledger.PostOpeningBalance(sessCtx, userID)
+		return err
+	})
+	if errors.Is(err, ledger.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile balance"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// parsePagination đọc page/limit từ query; tự trả 400 nếu không hợp lệ.
+func parsePagination(c *gin.Context, defaultLimit int) (int, int, bool) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+		return 0, 0, false
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultLimit)))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit (1-100)"})
+		return 0, 0, false
+	}
+	return page, limit, true
+}
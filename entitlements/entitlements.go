@@ -0,0 +1,226 @@
+// Package entitlements trả lời câu hỏi "user X có được chơi game Y không,
+// và tới khi nào" dựa trên purchases và rentals.
+//
+// Purchase đã hoàn tiền và rental đã hoàn tiền không tạo quyền chơi. Rental
+// còn status active nhưng đã quá ExpireAt được coi là expired dù sweeper
+// chưa kịp cập nhật.
+package entitlements
+
+import (
+	"context"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Loại quyền của user với một game.
+const (
+	KindNone    = "none"
+	KindOwned   = "owned"
+	KindRented  = "rented"
+	KindExpired = "expired"
+)
+
+// Entitlement là quyền hiện tại của user với một game.
+type Entitlement struct {
+	GameID     primitive.ObjectID  `json:"game_id"`
+	Kind       string              `json:"kind"` // none, owned, rented, expired
+	CanPlay    bool                `json:"can_play"`
+	ExpireAt   *time.Time          `json:"expire_at,omitempty"` // nil nếu owned
+	AcquiredAt *time.Time          `json:"acquired_at,omitempty"`
+	PurchaseID *primitive.ObjectID `json:"purchase_id,omitempty"`
+	RentalID   *primitive.ObjectID `json:"rental_id,omitempty"`
+}
+
+// Check trả về quyền của user với một game.
+func Check(ctx context.Context, userID, gameID primitive.ObjectID) (Entitlement, error) {
+	result, err := ForGames(ctx, userID, []primitive.ObjectID{gameID})
+	if err != nil {
+		return Entitlement{}, err
+	}
+	if e, ok := result[gameID]; ok {
+		return e, nil
+	}
+	return Entitlement{GameID: gameID, Kind: KindNone}, nil
+}
+
+// ForGames trả về quyền của user với nhiều game chỉ bằng hai truy vấn.
+// Game mà user chưa từng mua hay thuê sẽ không có trong map.
+func ForGames(ctx context.Context, userID primitive.ObjectID, gameIDs []primitive.ObjectID) (map[primitive.ObjectID]Entitlement, error) {
+	if len(gameIDs) == 0 {
+		return map[primitive.ObjectID]Entitlement{}, nil
+	}
+	return load(ctx, bson.M{"user_id": userID, "game_id": bson.M{"$in": gameIDs}})
+}
+
+// All trả về quyền của user với mọi game từng mua hoặc thuê.
+func All(ctx context.Context, userID primitive.ObjectID) (map[primitive.ObjectID]Entitlement, error) {
+	return load(ctx, bson.M{"user_id": userID})
+}
+
+// List trả về một trang quyền của user, lọc theo kind (để trống = tất cả),
+// mới nhất trước, kèm tổng số phần tử sau khi lọc. Việc gộp purchase/rental
+// theo game, lọc và phân trang chạy trong Mongo; game cùng thời điểm nhận
+// quyền được xếp theo game_id giảm dần để trang ổn định.
+func List(ctx context.Context, userID primitive.ObjectID, kind string, skip, limit int) ([]Entitlement, int, error) {
+	now := time.Now()
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userID, "status": bson.M{"$ne": models.PurchaseRefunded}}}},
+		{{Key: "$project", Value: bson.M{
+			"game_id":     1,
+			"rank":        bson.M{"$literal": 1},
+			"acquired_at": "$purchase_at",
+			"purchase_id": "$_id",
+		}}},
+		{{Key: "$unionWith", Value: bson.M{
+			"coll": "rentals",
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{"user_id": userID, "status": bson.M{"$in": bson.A{models.RentalActive, models.RentalExpired}}}},
+				bson.M{"$project": bson.M{
+					"game_id":     1,
+					"rank":        bson.M{"$literal": 0},
+					"acquired_at": "$rent_at",
+					"expire_at":   1,
+					"rental_id":   "$_id",
+					"active": bson.M{"$and": bson.A{
+						bson.M{"$eq": bson.A{"$status", models.RentalActive}},
+						bson.M{"$gt": bson.A{"$expire_at", now}},
+					}},
+				}},
+			},
+		}}},
+		// Mỗi game giữ purchase nếu có, nếu không thì rental hết hạn muộn nhất.
+		{{Key: "$sort", Value: bson.D{{Key: "game_id", Value: 1}, {Key: "rank", Value: -1}, {Key: "expire_at", Value: -1}, {Key: "_id", Value: -1}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$game_id", "doc": bson.M{"$first": "$$ROOT"}}}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": bson.M{"$mergeObjects": bson.A{"$doc", bson.M{"_id": "$_id"}}}}}},
+		{{Key: "$addFields", Value: bson.M{"kind": bson.M{"$switch": bson.M{
+			"branches": bson.A{
+				bson.M{"case": bson.M{"$eq": bson.A{"$rank", 1}}, "then": KindOwned},
+				bson.M{"case": "$active", "then": KindRented},
+			},
+			"default": KindExpired,
+		}}}}},
+	}
+	if kind != "" {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"kind": kind}}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "acquired_at", Value: -1}, {Key: "_id", Value: -1}}}},
+		bson.D{{Key: "$facet", Value: bson.M{
+			"items": bson.A{bson.M{"$skip": skip}, bson.M{"$limit": limit}},
+			"total": bson.A{bson.M{"$count": "n"}},
+		}}},
+	)
+
+	cursor, err := config.DB.Collection("purchases").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, 0, err
+	}
+	var result []struct {
+		Items []struct {
+			GameID     primitive.ObjectID  `bson:"_id"`
+			Kind       string              `bson:"kind"`
+			AcquiredAt *time.Time          `bson:"acquired_at"`
+			ExpireAt   *time.Time          `bson:"expire_at"`
+			PurchaseID *primitive.ObjectID `bson:"purchase_id"`
+			RentalID   *primitive.ObjectID `bson:"rental_id"`
+		} `bson:"items"`
+		Total []struct {
+			N int `bson:"n"`
+		} `bson:"total"`
+	}
+	if err := cursor.All(ctx, &result); err != nil {
+		return nil, 0, err
+	}
+
+	items := []Entitlement{}
+	total := 0
+	if len(result) > 0 {
+		if len(result[0].Total) > 0 {
+			total = result[0].Total[0].N
+		}
+		for _, r := range result[0].Items {
+			items = append(items, Entitlement{
+				GameID:     r.GameID,
+				Kind:       r.Kind,
+				CanPlay:    r.Kind == KindOwned || r.Kind == KindRented,
+				ExpireAt:   r.ExpireAt,
+				AcquiredAt: r.AcquiredAt,
+				PurchaseID: r.PurchaseID,
+				RentalID:   r.RentalID,
+			})
+		}
+	}
+	return items, total, nil
+}
+
+func load(ctx context.Context, filter bson.M) (map[primitive.ObjectID]Entitlement, error) {
+	result := map[primitive.ObjectID]Entitlement{}
+
+	purchaseFilter := bson.M{"status": bson.M{"$ne": models.PurchaseRefunded}}
+	rentalFilter := bson.M{"status": bson.M{"$in": bson.A{models.RentalActive, models.RentalExpired}}}
+	for k, v := range filter {
+		purchaseFilter[k] = v
+		rentalFilter[k] = v
+	}
+
+	cursor, err := config.DB.Collection("purchases").Find(ctx, purchaseFilter)
+	if err != nil {
+		return nil, err
+	}
+	var purchases []models.Purchase
+	if err := cursor.All(ctx, &purchases); err != nil {
+		return nil, err
+	}
+	for _, p := range purchases {
+		p := p
+		result[p.GameID] = Entitlement{
+			GameID:     p.GameID,
+			Kind:       KindOwned,
+			CanPlay:    true,
+			AcquiredAt: &p.PurchaseAt,
+			PurchaseID: &p.ID,
+		}
+	}
+
+	cursor, err = config.DB.Collection("rentals").Find(ctx, rentalFilter)
+	if err != nil {
+		return nil, err
+	}
+	var rentals []models.Rental
+	if err := cursor.All(ctx, &rentals); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, r := range rentals {
+		r := r
+		current, seen := result[r.GameID]
+		if seen && current.Kind == KindOwned {
+			continue
+		}
+		// Giữ rental hết hạn muộn nhất cho mỗi game.
+		if seen && current.ExpireAt != nil && !r.ExpireAt.After(*current.ExpireAt) {
+			continue
+		}
+		e := Entitlement{
+			GameID:     r.GameID,
+			Kind:       KindExpired,
+			ExpireAt:   &r.ExpireAt,
+			AcquiredAt: &r.RentAt,
+			RentalID:   &r.ID,
+		}
+		if r.Status == models.RentalActive && now.Before(r.ExpireAt) {
+			e.Kind = KindRented
+			e.CanPlay = true
+		}
+		result[r.GameID] = e
+	}
+
+	return result, nil
+}
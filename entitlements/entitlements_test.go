@@ -0,0 +1,100 @@
+package entitlements
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/config/mongotest"
+	"go-mvc-demo/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestListPaginatesWithStableOrder(t *testing.T) {
+	mongotest.Setup(t)
+	ctx := context.Background()
+	user := primitive.NewObjectID()
+	now := time.Now().Truncate(time.Millisecond)
+	same := now.Add(-time.Hour)
+
+	owned, rented, expired := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	tieA, tieB := primitive.NewObjectID(), primitive.NewObjectID()
+	refunded := primitive.NewObjectID()
+	purchases := []interface{}{
+		models.Purchase{ID: primitive.NewObjectID(), UserID: user, GameID: owned, PurchaseAt: now, Price: 100, Status: models.PurchaseCompleted},
+		models.Purchase{ID: primitive.NewObjectID(), UserID: user, GameID: tieA, PurchaseAt: same, Price: 100, Status: models.PurchaseCompleted},
+		models.Purchase{ID: primitive.NewObjectID(), UserID: user, GameID: tieB, PurchaseAt: same, Price: 100, Status: models.PurchaseCompleted},
+		models.Purchase{ID: primitive.NewObjectID(), UserID: user, GameID: refunded, PurchaseAt: now, Price: 100, Status: models.PurchaseRefunded},
+	}
+	if _, err := config.DB.Collection("purchases").InsertMany(ctx, purchases); err != nil {
+		t.Fatal(err)
+	}
+	rentals := []interface{}{
+		// Game đã mua vẫn là owned dù còn rental cũ.
+		models.Rental{ID: primitive.NewObjectID(), UserID: user, GameID: owned, RentAt: now.Add(-48 * time.Hour), ExpireAt: now.Add(time.Hour), Status: models.RentalActive},
+		// Rental hết hạn muộn nhất quyết định trạng thái.
+		models.Rental{ID: primitive.NewObjectID(), UserID: user, GameID: rented, RentAt: now.Add(-3 * time.Hour), ExpireAt: now.Add(-2 * time.Hour), Status: models.RentalExpired},
+		models.Rental{ID: primitive.NewObjectID(), UserID: user, GameID: rented, RentAt: now.Add(-2 * time.Hour), ExpireAt: now.Add(time.Hour), Status: models.RentalActive},
+		// Còn status active nhưng đã quá hạn.
+		models.Rental{ID: primitive.NewObjectID(), UserID: user, GameID: expired, RentAt: now.Add(-4 * time.Hour), ExpireAt: now.Add(-time.Minute), Status: models.RentalActive},
+		models.Rental{ID: primitive.NewObjectID(), UserID: user, GameID: refunded, RentAt: now, ExpireAt: now.Add(time.Hour), Status: models.RentalRefunded},
+	}
+	if _, err := config.DB.Collection("rentals").InsertMany(ctx, rentals); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cùng acquired_at thì game_id lớn hơn đứng trước.
+	first, second := tieA, tieB
+	if tieB.Hex() > tieA.Hex() {
+		first, second = tieB, tieA
+	}
+	want := []struct {
+		game primitive.ObjectID
+		kind string
+	}{
+		{owned, KindOwned},
+		{first, KindOwned},
+		{second, KindOwned},
+		{rented, KindRented},
+		{expired, KindExpired},
+	}
+
+	var got []Entitlement
+	for skip := 0; ; skip += 2 {
+		page, total, err := List(ctx, user, "", skip, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != len(want) {
+			t.Fatalf("total = %d, want %d", total, len(want))
+		}
+		if len(page) == 0 {
+			break
+		}
+		got = append(got, page...)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("%d entitlements across pages, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].GameID != w.game || got[i].Kind != w.kind {
+			t.Fatalf("item %d = %+v, want %s %s", i, got[i], w.game.Hex(), w.kind)
+		}
+	}
+	if got[3].ExpireAt == nil || !got[3].ExpireAt.Equal(now.Add(time.Hour)) || !got[3].CanPlay || got[3].RentalID == nil {
+		t.Fatalf("rented entitlement = %+v", got[3])
+	}
+	if got[0].PurchaseID == nil || got[0].ExpireAt != nil || !got[0].CanPlay {
+		t.Fatalf("owned entitlement = %+v", got[0])
+	}
+
+	page, total, err := List(ctx, user, KindExpired, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(page) != 1 || page[0].GameID != expired || page[0].CanPlay {
+		t.Fatalf("expired filter = %d %+v", total, page)
+	}
+}
@@ -0,0 +1,56 @@
+// Package events lưu và phát các sự kiện nghiệp vụ.
+//
+// Mỗi sự kiện được ghi vào collection events (để các hệ thống khác đọc lại)
+// rồi chuyển cho các handler đăng ký trong cùng process.
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	RentalExpired = "rental.expired"
+)
+
+// Handler xử lý một sự kiện. Handler không nên block lâu.
+type Handler func(ctx context.Context, event models.Event)
+
+var (
+	mu       sync.RWMutex
+	handlers = map[string][]Handler{}
+)
+
+// Subscribe đăng ký handler cho một loại sự kiện.
+func Subscribe(eventType string, h Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers[eventType] = append(handlers[eventType], h)
+}
+
+// Emit ghi sự kiện vào Mongo và gọi các handler đã đăng ký.
+func Emit(ctx context.Context, eventType string, payload map[string]interface{}) error {
+	event := models.Event{
+		ID:        primitive.NewObjectID(),
+		Type:      eventType,
+		Payload:   payload,
+		CreatedAt: time.Now(),
+	}
+	if _, err := config.DB.Collection("events").InsertOne(ctx, event); err != nil {
+		return err
+	}
+
+	mu.RLock()
+	hs := handlers[eventType]
+	mu.RUnlock()
+	for _, h := range hs {
+		h(ctx, event)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/models"
+	"go-mvc-demo/rawg"
+	"go-mvc-demo/sanitize"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// maxScreenshots là số ảnh chụp màn hình lưu cho mỗi game.
+	maxScreenshots = 20
+	// enrichRetryAfter là thời gian chờ trước khi thử lại game enrich lỗi.
+	enrichRetryAfter = 6 * time.Hour
+)
+
+var (
+	ErrGameNotFound = errors.New("game not found")
+	ErrNotRAWGGame  = errors.New("game was not imported from RAWG")
+	ErrFetchFailed  = errors.New("fetching game details from RAWG failed")
+)
+
+// EnrichGame lấy lại chi tiết của một game từ RAWG (mô tả, nhà phát triển,
+// ảnh, trailer...) và trả về game sau khi cập nhật.
+func EnrichGame(ctx context.Context, id primitive.ObjectID) (models.Game, error) {
+	var game models.Game
+	if source == nil {
+		return game, ErrNotConfigured
+	}
+	games := config.DB.Collection("games")
+	err := games.FindOne(ctx, bson.M{"_id": id}).Decode(&game)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return game, ErrGameNotFound
+	}
+	if err != nil {
+		return game, err
+	}
+	if game.RawgID <= 0 {
+		return game, ErrNotRAWGGame
+	}
+
+	if err := enrich(ctx, game); err != nil {
+		return game, err
+	}
+	err = games.FindOne(ctx, bson.M{"_id": id}).Decode(&game)
+	return game, err
+}
+
+// EnrichPending enrich tối đa batch game chưa enrich (hoặc đã đổi trên RAWG
+// sau lần enrich trước) bằng concurrency worker song song. Tốc độ gọi RAWG
+// do rate limiter của client giới hạn. Trả về số game enrich thành công.
+func EnrichPending(ctx context.Context, batch, concurrency int) (int, error) {
+	if source == nil {
+		return 0, nil
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	now := time.Now()
+	filter := bson.M{
+		"rawg_id": bson.M{"$gt": 0},
+		"$and": bson.A{
+			bson.M{"$or": bson.A{
+				bson.M{"enriched_at": bson.M{"$exists": false}},
+				bson.M{"$expr": bson.M{"$gt": bson.A{"$source_updated_at", "$enriched_at"}}},
+			}},
+			bson.M{"$or": bson.A{
+				bson.M{"enrich_failed_at": bson.M{"$exists": false}},
+				bson.M{"enrich_failed_at": bson.M{"$lt": now.Add(-enrichRetryAfter)}},
+			}},
+		},
+	}
+	cursor, err := config.DB.Collection("games").Find(ctx, filter,
+		options.Find().
+			SetProjection(bson.M{"_id": 1, "rawg_id": 1, "name": 1}).
+			SetSort(bson.M{"_id": 1}).
+			SetLimit(int64(batch)),
+	)
+	if err != nil {
+		return 0, err
+	}
+	var pending []models.Game
+	if err := cursor.All(ctx, &pending); err != nil {
+		return 0, err
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		enriched int
+	)
+	work := make(chan models.Game)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for game := range work {
+				if err := enrich(ctx, game); err != nil {
+					if ctx.Err() == nil {
+						log.Printf("⚠️ Enrich game %d (%s): %v", game.RawgID, game.Name, err)
+					}
+					continue
+				}
+				mu.Lock()
+				enriched++
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for _, game := range pending {
+		select {
+		case work <- game:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+	return enriched, ctx.Err()
+}
+
+// enrich lấy chi tiết game từ RAWG và ghi vào catalog. Lỗi được lưu vào
+// enrich_error để game được thử lại sau enrichRetryAfter.
+func enrich(ctx context.Context, game models.Game) error {
+	games := config.DB.Collection("games")
+	set, err := fetchDetail(ctx, game.RawgID)
+	if err != nil {
+		if ctx.Err() == nil {
+			games.UpdateOne(ctx, bson.M{"_id": game.ID}, bson.M{"$set": bson.M{
+				"enrich_error":     err.Error(),
+				"enrich_failed_at": time.Now(),
+			}})
+		}
+		return err
+	}
+	_, err = games.UpdateOne(ctx, bson.M{"_id": game.ID}, bson.M{
+		"$set":   set,
+		"$unset": bson.M{"enrich_error": "", "enrich_failed_at": ""},
+	})
+	return err
+}
+
+// fetchDetail gọi các API chi tiết của RAWG và trả về các field cần $set.
+// Price và các field khác do admin quản lý không bị đụng tới.
+func fetchDetail(ctx context.Context, rawgID int) (bson.M, error) {
+	detail, err := source.GetGame(ctx, rawgID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: detail: %w", ErrFetchFailed, err)
+	}
+	shots, err := source.ListGameScreenshots(ctx, rawgID, rawg.ListOptions{PageSize: maxScreenshots})
+	if err != nil && !errors.Is(err, rawg.ErrNotFound) {
+		return nil, fmt.Errorf("%w: screenshots: %w", ErrFetchFailed, err)
+	}
+	movies, err := source.ListGameMovies(ctx, rawgID)
+	if err != nil && !errors.Is(err, rawg.ErrNotFound) {
+		return nil, fmt.Errorf("%w: movies: %w", ErrFetchFailed, err)
+	}
+
+	text := sanitize.Text(detail.Description)
+	if text == "" {
+		text = strings.TrimSpace(detail.DescriptionRaw)
+	}
+	if text == "" {
+		text = "No description available"
+	}
+
+	screenshots := []models.Screenshot{}
+	for _, s := range shots.Results {
+		if url := sanitize.URL(s.Image); url != "" && !s.IsDeleted && len(screenshots) < maxScreenshots {
+			screenshots = append(screenshots, models.Screenshot{URL: url, Width: s.Width, Height: s.Height})
+		}
+	}
+	trailers := []models.Trailer{}
+	for _, m := range movies.Results {
+		t := models.Trailer{
+			Name:    m.Name,
+			Preview: sanitize.URL(m.Preview),
+			URL480:  sanitize.URL(m.Data["480"]),
+			URLMax:  sanitize.URL(m.Data["max"]),
+		}
+		if t.URL480 != "" || t.URLMax != "" {
+			trailers = append(trailers, t)
+		}
+	}
+
+	now := time.Now()
+	set := bson.M{
+		"description":      text,
+		"description_html": sanitize.HTML(detail.Description),
+		"developers":       companyNames(detail.Developers),
+		"publishers":       companyNames(detail.Publishers),
+		"esrb_rating":      "",
+		"metacritic":       detail.Metacritic,
+		"website":          sanitize.URL(detail.Website),
+		"screenshots":      screenshots,
+		"trailers":         trailers,
+		"enriched_at":      now,
+		"updated_at":       now,
+	}
+	if detail.ESRBRating != nil {
+		set["esrb_rating"] = detail.ESRBRating.Name
+	}
+	if released := parseReleased(detail.Released); released != nil {
+		set["released"] = released
+	}
+	return set, nil
+}
+
+func companyNames(list []rawg.Company) []string {
+	names := make([]string, 0, len(list))
+	for _, c := range list {
+		names = append(names, c.Name)
+	}
+	return names
+}
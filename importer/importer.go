@@ -0,0 +1,321 @@
+// Package importer chạy các job import game từ RAWG ở nền.
+//
+// Mỗi job là một document trong import_jobs. Một replica nhận job bằng cách
+// ghi owner/lease_until, import từng trang và lưu tiến độ sau mỗi trang. Job
+// bị huỷ sẽ dừng sau trang đang chạy; job của replica đã chết được replica
+// khác chạy tiếp khi lease hết hạn.
+package importer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/models"
+	"go-mvc-demo/rawg"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	SourceRAWG = "rawg"
+
+	// MaxPageSize là page_size lớn nhất RAWG cho phép.
+	MaxPageSize = rawg.MaxPageSize
+
+	leaseTTL         = 2 * time.Minute
+	pageAttempts     = 3
+	pageRetryBackoff = 5 * time.Second
+)
+
+var (
+	ErrNotConfigured = errors.New("RAWG_API_KEY is not configured")
+	ErrJobNotFound   = errors.New("import job not found")
+	ErrJobActive     = errors.New("another import job is already queued or running")
+	ErrJobNotRunning = errors.New("import job is not queued or running")
+	ErrJobNotStopped = errors.New("only failed or cancelled import jobs can be resumed")
+	errCancelled     = errors.New("import job cancelled")
+)
+
+// owner định danh process này khi giữ lease của job.
+var owner = func() string {
+	host, _ := os.Hostname()
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(buf))
+}()
+
+// source lấy dữ liệu từ RAWG; được gán qua Configure.
+var source Source
+
+// Configure đặt nguồn dữ liệu cho các job import và enrichment.
+func Configure(s Source) {
+	source = s
+}
+
+func jobs() *mongo.Collection {
+	return config.DB.Collection("import_jobs")
+}
+
+// Params là tham số của một job import mới.
+type Params struct {
+	StartPage int
+	Pages     int
+	PageSize  int
+	CreatedBy primitive.ObjectID
+}
+
+// Start tạo job ở trạng thái queued; scheduler sẽ chạy nó. Mỗi lúc chỉ có
+// một job queued/running: unique index uniq_active_import chặn job thứ hai
+// kể cả khi hai request tạo job cùng lúc.
+func Start(ctx context.Context, p Params) (models.ImportJob, error) {
+	now := time.Now()
+	job := models.ImportJob{
+		ID:        primitive.NewObjectID(),
+		Source:    SourceRAWG,
+		Status:    models.ImportQueued,
+		StartPage: p.StartPage,
+		EndPage:   p.StartPage + p.Pages - 1,
+		PageSize:  p.PageSize,
+		NextPage:  p.StartPage,
+		Active:    true,
+		CreatedBy: p.CreatedBy,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	_, err := jobs().InsertOne(ctx, job)
+	if mongo.IsDuplicateKeyError(err) {
+		return models.ImportJob{}, ErrJobActive
+	}
+	if err != nil {
+		return models.ImportJob{}, err
+	}
+	return job, nil
+}
+
+// Get trả về job theo id.
+func Get(ctx context.Context, id primitive.ObjectID) (models.ImportJob, error) {
+	var job models.ImportJob
+	err := jobs().FindOne(ctx, bson.M{"_id": id}).Decode(&job)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return job, ErrJobNotFound
+	}
+	return job, err
+}
+
+// List trả về các job gần nhất.
+func List(ctx context.Context, limit int64) ([]models.ImportJob, error) {
+	cursor, err := jobs().Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	list := []models.ImportJob{}
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Cancel dừng job queued/running. Job đang chạy dừng sau trang hiện tại.
+func Cancel(ctx context.Context, id primitive.ObjectID) (models.ImportJob, error) {
+	now := time.Now()
+	return transition(ctx, id,
+		bson.A{models.ImportQueued, models.ImportRunning},
+		bson.M{"status": models.ImportCancelled, "active": false, "finished_at": now, "updated_at": now},
+		ErrJobNotRunning,
+	)
+}
+
+// Resume xếp lại hàng một job failed/cancelled; job chạy tiếp từ NextPage.
+// Trả về ErrJobActive nếu đã có job khác queued/running.
+func Resume(ctx context.Context, id primitive.ObjectID) (models.ImportJob, error) {
+	job, err := transition(ctx, id,
+		bson.A{models.ImportFailed, models.ImportCancelled},
+		bson.M{"status": models.ImportQueued, "active": true, "finished_at": nil, "owner": nil, "lease_until": nil, "updated_at": time.Now()},
+		ErrJobNotStopped,
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return job, ErrJobActive
+	}
+	return job, err
+}
+
+func transition(ctx context.Context, id primitive.ObjectID, from bson.A, set bson.M, invalid error) (models.ImportJob, error) {
+	var job models.ImportJob
+	err := jobs().FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": bson.M{"$in": from}},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&job)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if _, err := Get(ctx, id); err != nil {
+			return job, err
+		}
+		return job, invalid
+	}
+	return job, err
+}
+
+// RunPending nhận và chạy các job đang chờ (hoặc job running mà replica giữ
+// nó đã chết) cho tới khi hết job hay ctx bị huỷ. Được scheduler gọi định kỳ;
+// lần chạy đầu tiên khi server khởi động sẽ chạy tiếp job dang dở.
+func RunPending(ctx context.Context) error {
+	for ctx.Err() == nil {
+		job, ok, err := claim(ctx)
+		if err != nil || !ok {
+			return err
+		}
+		log.Printf("Import job %s: chạy từ trang %d/%d", job.ID.Hex(), job.NextPage, job.EndPage)
+		if err := run(ctx, job); err != nil && ctx.Err() == nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func claim(ctx context.Context) (models.ImportJob, bool, error) {
+	now := time.Now()
+	var job models.ImportJob
+	err := jobs().FindOneAndUpdate(ctx,
+		bson.M{"$or": bson.A{
+			bson.M{"status": models.ImportQueued},
+			bson.M{"status": models.ImportRunning, "lease_until": bson.M{"$lt": now}},
+		}},
+		bson.M{
+			"$set": bson.M{"status": models.ImportRunning, "owner": owner, "lease_until": now.Add(leaseTTL), "updated_at": now},
+			"$min": bson.M{"started_at": now},
+		},
+		options.FindOneAndUpdate().SetSort(bson.M{"created_at": 1}).SetReturnDocument(options.After),
+	).Decode(&job)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return job, false, nil
+	}
+	return job, err == nil, err
+}
+
+// run import lần lượt từng trang còn lại của job.
+func run(ctx context.Context, job models.ImportJob) error {
+	if source == nil {
+		return finish(ctx, job.ID, models.ImportFailed, ErrNotConfigured.Error())
+	}
+
+	for page := job.NextPage; page <= job.EndPage; page++ {
+		result, hasMore, err := importPageWithRetry(ctx, job, page)
+		if errors.Is(err, errCancelled) {
+			log.Printf("Import job %s: đã huỷ tại trang %d", job.ID.Hex(), page)
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				// Server tắt: giữ trạng thái running nhưng trả lease ngay để
+				// replica khác hoặc lần khởi động sau chạy tiếp từ trang này.
+				releaseLease(job.ID)
+				return ctx.Err()
+			}
+			return finish(ctx, job.ID, models.ImportFailed, fmt.Sprintf("page %d: %v", page, err))
+		}
+
+		if err := saveProgress(ctx, job.ID, page, result); err != nil {
+			return err
+		}
+		if !hasMore {
+			break
+		}
+	}
+	return finish(ctx, job.ID, models.ImportCompleted, "")
+}
+
+func importPageWithRetry(ctx context.Context, job models.ImportJob, page int) (pageResult, bool, error) {
+	var lastErr error
+	for attempt := 1; attempt <= pageAttempts; attempt++ {
+		if err := renewLease(ctx, job.ID); err != nil {
+			return pageResult{}, false, err
+		}
+		result, hasMore, err := importPage(ctx, job.ID, page, job.PageSize)
+		if err == nil {
+			return result, hasMore, nil
+		}
+		if ctx.Err() != nil {
+			return pageResult{}, false, ctx.Err()
+		}
+		lastErr = err
+		recordError(ctx, job.ID, fmt.Sprintf("page %d attempt %d: %v", page, attempt, err))
+		if attempt < pageAttempts {
+			select {
+			case <-ctx.Done():
+				return pageResult{}, false, ctx.Err()
+			case <-time.After(time.Duration(attempt) * pageRetryBackoff):
+			}
+		}
+	}
+	return pageResult{}, false, lastErr
+}
+
+// renewLease gia hạn lease; trả về errCancelled nếu job đã bị huỷ hoặc đã
+// bị replica khác nhận.
+func renewLease(ctx context.Context, id primitive.ObjectID) error {
+	res, err := jobs().UpdateOne(ctx,
+		bson.M{"_id": id, "owner": owner, "status": models.ImportRunning},
+		bson.M{"$set": bson.M{"lease_until": time.Now().Add(leaseTTL)}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errCancelled
+	}
+	return nil
+}
+
+func releaseLease(id primitive.ObjectID) {
+	// ctx của job đã bị huỷ nên dùng context riêng.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	jobs().UpdateOne(ctx, bson.M{"_id": id, "owner": owner}, bson.M{"$set": bson.M{"lease_until": time.Now()}})
+}
+
+func saveProgress(ctx context.Context, id primitive.ObjectID, page int, r pageResult) error {
+	now := time.Now()
+	inc := bson.M{"pages_done": 1, "inserted": r.Inserted, "updated": r.Updated, "unchanged": r.Unchanged, "skipped": r.Skipped}
+	for field, n := range r.Fields {
+		inc["changed_fields."+field] = n
+	}
+	_, err := jobs().UpdateOne(ctx,
+		bson.M{"_id": id, "owner": owner, "next_page": page},
+		bson.M{
+			"$set": bson.M{"next_page": page + 1, "updated_at": now, "lease_until": now.Add(leaseTTL)},
+			"$inc": inc,
+		},
+	)
+	return err
+}
+
+func recordError(ctx context.Context, id primitive.ObjectID, msg string) {
+	now := time.Now()
+	log.Printf("⚠️ Import job %s: %s", id.Hex(), msg)
+	jobs().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_error": msg, "last_error_at": now}})
+}
+
+func finish(ctx context.Context, id primitive.ObjectID, status, lastError string) error {
+	now := time.Now()
+	set := bson.M{"status": status, "active": false, "finished_at": now, "updated_at": now, "owner": nil, "lease_until": nil}
+	if lastError != "" {
+		set["last_error"] = lastError
+		set["last_error_at"] = now
+	}
+	// Không ghi đè job vừa bị huỷ.
+	_, err := jobs().UpdateOne(ctx, bson.M{"_id": id, "owner": owner, "status": models.ImportRunning}, bson.M{"$set": set})
+	if err == nil {
+		log.Printf("Import job %s: %s", id.Hex(), status)
+	}
+	return err
+}
@@ -0,0 +1,254 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/config/mongotest"
+	"go-mvc-demo/models"
+	"go-mvc-demo/rawg/rawgtest"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// setup nối importer vào database test và RAWG giả.
+func setup(t *testing.T) *rawgtest.Server {
+	t.Helper()
+	mongotest.Setup(t)
+	s := rawgtest.New(t)
+	prev := source
+	Configure(s.Client())
+	t.Cleanup(func() { Configure(prev) })
+	return s
+}
+
+func TestRunPendingImportsUntilLastPage(t *testing.T) {
+	s := setup(t)
+	ctx := context.Background()
+
+	job, err := Start(ctx, Params{StartPage: 1, Pages: 5, PageSize: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RunPending(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err = Get(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Trang 2 không có next nên job dừng trước trang 5.
+	if job.Status != models.ImportCompleted || job.PagesDone != 2 || job.NextPage != 3 {
+		t.Fatalf("job = %+v, want completed after 2 pages", job)
+	}
+	if job.Inserted != 4 || job.Skipped != 1 || job.Updated != 0 {
+		t.Fatalf("counts = inserted %d, updated %d, skipped %d", job.Inserted, job.Updated, job.Skipped)
+	}
+	if job.Owner != "" || job.LeaseUntil != nil || job.FinishedAt == nil {
+		t.Fatalf("finished job still holds a lease: %+v", job)
+	}
+	if n, _ := config.DB.Collection("games").CountDocuments(ctx, bson.M{}); n != 4 {
+		t.Fatalf("%d games, want 4", n)
+	}
+	reqs := s.Requests()
+	if len(reqs) != 2 || !strings.Contains(reqs[0], "page=1") || !strings.Contains(reqs[1], "page=2") {
+		t.Fatalf("requests = %v", reqs)
+	}
+
+	// Chạy lại cùng khoảng trang: mọi game đều không đổi.
+	again, err := Start(ctx, Params{StartPage: 1, Pages: 2, PageSize: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RunPending(ctx); err != nil {
+		t.Fatal(err)
+	}
+	again, _ = Get(ctx, again.ID)
+	if again.Status != models.ImportCompleted || again.Inserted != 0 || again.Unchanged != 4 {
+		t.Fatalf("rerun = %+v, want 4 unchanged", again)
+	}
+}
+
+func TestRunPendingSurvivesRateLimiting(t *testing.T) {
+	s := setup(t)
+	ctx := context.Background()
+	s.FailNextWithRetryAfter(2, http.StatusTooManyRequests, "0")
+	s.FailNext(1, http.StatusServiceUnavailable)
+
+	job, err := Start(ctx, Params{StartPage: 1, Pages: 1, PageSize: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RunPending(ctx); err != nil {
+		t.Fatal(err)
+	}
+	job, _ = Get(ctx, job.ID)
+	if job.Status != models.ImportCompleted || job.Inserted != 3 {
+		t.Fatalf("job = %+v, want completed with 3 inserted", job)
+	}
+	if n := len(s.Requests()); n != 4 {
+		t.Fatalf("%d requests, want 3 failures and 1 success", n)
+	}
+}
+
+func TestRunPendingFailsWithoutSource(t *testing.T) {
+	setup(t)
+	ctx := context.Background()
+	Configure(nil)
+
+	job, err := Start(ctx, Params{StartPage: 1, Pages: 1, PageSize: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RunPending(ctx); err != nil {
+		t.Fatal(err)
+	}
+	job, _ = Get(ctx, job.ID)
+	if job.Status != models.ImportFailed || job.LastError != ErrNotConfigured.Error() {
+		t.Fatalf("job = %+v, want failed with %q", job, ErrNotConfigured)
+	}
+}
+
+func TestStartRejectsSecondActiveJob(t *testing.T) {
+	setup(t)
+	ctx := context.Background()
+
+	first, err := Start(ctx, Params{StartPage: 1, Pages: 1, PageSize: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Start(ctx, Params{StartPage: 1, Pages: 1, PageSize: 3}); !errors.Is(err, ErrJobActive) {
+		t.Fatalf("second Start: err = %v, want ErrJobActive", err)
+	}
+	if _, err := Cancel(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Start(ctx, Params{StartPage: 1, Pages: 1, PageSize: 3}); err != nil {
+		t.Fatalf("Start after cancel: %v", err)
+	}
+	if _, err := Resume(ctx, first.ID); !errors.Is(err, ErrJobActive) {
+		t.Fatalf("Resume while another job is queued: err = %v, want ErrJobActive", err)
+	}
+}
+
+func TestStartAllowsOneActiveJobUnderConcurrency(t *testing.T) {
+	setup(t)
+	ctx := context.Background()
+
+	const callers = 20
+	var started, rejected atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := Start(ctx, Params{StartPage: 1, Pages: 1, PageSize: 3})
+			switch {
+			case err == nil:
+				started.Add(1)
+			case errors.Is(err, ErrJobActive):
+				rejected.Add(1)
+			default:
+				t.Errorf("Start: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if started.Load() != 1 || rejected.Load() != callers-1 {
+		t.Fatalf("started %d, rejected %d; want exactly one job", started.Load(), rejected.Load())
+	}
+
+	// Job xong thì trả slot cho job mới.
+	if err := RunPending(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Start(ctx, Params{StartPage: 1, Pages: 1, PageSize: 3}); err != nil {
+		t.Fatalf("Start after the job finished: %v", err)
+	}
+}
+
+func TestEnrichGameSanitizesDetail(t *testing.T) {
+	s := setup(t)
+	ctx := context.Background()
+	items := fetchPage(t, s.Client(), 1)
+	if _, err := syncGames(ctx, primitive.NewObjectID(), items); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := EnrichGame(ctx, gameByRawgID(t, 3498).ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.EnrichedAt == nil || g.ESRBRating != "Mature" || g.Metacritic != 92 {
+		t.Fatalf("enriched game = %+v", g)
+	}
+	if strings.Contains(g.DescriptionHTML, "<script") || strings.Contains(g.DescriptionHTML, "javascript:") || strings.Contains(g.DescriptionHTML, "onclick") {
+		t.Fatalf("description_html not sanitized: %q", g.DescriptionHTML)
+	}
+	if strings.Contains(g.Description, "<") || strings.Contains(g.Description, "alert") {
+		t.Fatalf("description not plain text: %q", g.Description)
+	}
+	if len(g.Developers) != 1 || g.Developers[0] != "Rockstar North" || len(g.Publishers) != 1 {
+		t.Fatalf("companies = %v / %v", g.Developers, g.Publishers)
+	}
+	// Ảnh đã bị xoá trên RAWG không được lưu.
+	if len(g.Screenshots) != 2 || len(g.Trailers) != 1 || g.Trailers[0].URLMax == "" {
+		t.Fatalf("media = %d screenshots, %+v", len(g.Screenshots), g.Trailers)
+	}
+}
+
+func TestEnrichGameRecordsFailureWithoutExposingIt(t *testing.T) {
+	s := setup(t)
+	ctx := context.Background()
+	items := fetchPage(t, s.Client(), 1)
+	if _, err := syncGames(ctx, primitive.NewObjectID(), items); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fixture không có chi tiết của 3328: RAWG trả 404.
+	id := gameByRawgID(t, 3328).ID
+	if _, err := EnrichGame(ctx, id); !errors.Is(err, ErrFetchFailed) {
+		t.Fatalf("err = %v, want ErrFetchFailed", err)
+	}
+	g := gameByRawgID(t, 3328)
+	if g.EnrichError == "" || g.EnrichFailedAt == nil || g.EnrichedAt != nil {
+		t.Fatalf("failure not recorded: %+v", g)
+	}
+	out, _ := json.Marshal(g)
+	if strings.Contains(string(out), "enrich_") {
+		t.Fatalf("enrich failure exposed in JSON: %s", out)
+	}
+
+	// Game lỗi được bỏ qua cho tới khi hết enrichRetryAfter; 3498 có fixture,
+	// 4200 thì không.
+	before := countRequests(s, "/games/3328?")
+	n, err := EnrichPending(ctx, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("EnrichPending enriched %d games, want 1", n)
+	}
+	if after := countRequests(s, "/games/3328?"); after != before {
+		t.Fatalf("failed game was retried before enrichRetryAfter (%d -> %d requests)", before, after)
+	}
+}
+
+func countRequests(s *rawgtest.Server, prefix string) int {
+	n := 0
+	for _, r := range s.Requests() {
+		if strings.HasPrefix(r, prefix) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,36 @@
+package importer
+
+import (
+	"context"
+	"errors"
+
+	"go-mvc-demo/rawg"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Source là các API RAWG mà importer dùng; *rawg.Client thoả mãn interface này.
+type Source interface {
+	ListGames(ctx context.Context, opts rawg.ListOptions) (rawg.Page[rawg.Game], error)
+	GetGame(ctx context.Context, id int) (rawg.GameDetail, error)
+	ListGameScreenshots(ctx context.Context, id int, opts rawg.ListOptions) (rawg.Page[rawg.Screenshot], error)
+	ListGameMovies(ctx context.Context, id int) (rawg.Page[rawg.Movie], error)
+}
+
+// importPage đồng bộ một trang game từ RAWG vào catalog.
+func importPage(ctx context.Context, jobID primitive.ObjectID, page, pageSize int) (pageResult, bool, error) {
+	data, err := source.ListGames(ctx, rawg.ListOptions{Page: page, PageSize: pageSize})
+	if errors.Is(err, rawg.ErrNotFound) {
+		// RAWG trả 404 khi page vượt quá số trang hiện có.
+		return pageResult{}, false, nil
+	}
+	if err != nil {
+		return pageResult{}, false, err
+	}
+
+	r, err := syncGames(ctx, jobID, data.Results)
+	if err != nil {
+		return r, false, err
+	}
+	return r, data.HasNext(), nil
+}
@@ -0,0 +1,255 @@
+package importer
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/models"
+	"go-mvc-demo/pricing"
+	"go-mvc-demo/rawg"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// rawgTimeLayout là định dạng field "updated" của RAWG (không có múi giờ, UTC).
+const rawgTimeLayout = "2006-01-02T15:04:05"
+
+// pageResult đếm kết quả đồng bộ một trang. Fields đếm số game bị đổi theo
+// từng field.
+type pageResult struct {
+	Inserted  int
+	Updated   int
+	Unchanged int
+	Skipped   int
+	Fields    map[string]int
+}
+
+// sourceFields trả về các field của catalog lấy từ RAWG, theo tên bson. Các
+// field còn lại (price, description...) do admin quản lý nên sync không ghi đè.
+func sourceFields(item rawg.Game) bson.M {
+	return bson.M{
+		"name":      item.Name,
+		"image_url": item.BackgroundImage,
+		"genres":    item.GenreNames(),
+		"platforms": item.PlatformNames(),
+		"rating":    item.Rating,
+		"released":  parseReleased(item.Released),
+	}
+}
+
+// currentFields là giá trị hiện tại của các field trong sourceFields.
+func currentFields(g models.Game) bson.M {
+	genres, platforms := g.Genres, g.Platforms
+	if genres == nil {
+		genres = []string{}
+	}
+	if platforms == nil {
+		platforms = []string{}
+	}
+	return bson.M{
+		"name":      g.Name,
+		"image_url": g.ImageURL,
+		"genres":    genres,
+		"platforms": platforms,
+		"rating":    g.Rating,
+		"released":  g.Released,
+	}
+}
+
+// parseReleased đọc ngày phát hành dạng "2006-01-02"; game chưa phát hành
+// (TBA) trả về nil.
+func parseReleased(v string) *time.Time {
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// sameValue so sánh giá trị field; thời gian so theo thời điểm vì location
+// sau khi đọc từ Mongo có thể khác.
+func sameValue(a, b interface{}) bool {
+	ta, okA := a.(*time.Time)
+	tb, okB := b.(*time.Time)
+	if okA && okB {
+		if ta == nil || tb == nil {
+			return ta == tb
+		}
+		return ta.Equal(*tb)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func parseSourceTime(v string) *time.Time {
+	t, err := time.Parse(rawgTimeLayout, v)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// syncGames upsert một trang game theo rawg_id bằng một bulk write. Game đã
+// có chỉ được ghi khi có field thay đổi; mỗi thay đổi được lưu vào
+// game_changes để xem lại theo job.
+func syncGames(ctx context.Context, jobID primitive.ObjectID, items []rawg.Game) (pageResult, error) {
+	r := pageResult{Fields: map[string]int{}}
+
+	// Bỏ bản ghi thiếu id/tên; game trùng trong cùng trang lấy bản cuối.
+	byID := map[int]rawg.Game{}
+	ids := []int{}
+	for _, item := range items {
+		if item.ID == 0 || item.Name == "" {
+			r.Skipped++
+			continue
+		}
+		if _, dup := byID[item.ID]; dup {
+			r.Skipped++
+		} else {
+			ids = append(ids, item.ID)
+		}
+		byID[item.ID] = item
+	}
+	if len(ids) == 0 {
+		return r, nil
+	}
+
+	games := config.DB.Collection("games")
+	cursor, err := games.Find(ctx, bson.M{"rawg_id": bson.M{"$in": ids}})
+	if err != nil {
+		return r, err
+	}
+	var existing []models.Game
+	if err := cursor.All(ctx, &existing); err != nil {
+		return r, err
+	}
+	current := map[int]models.Game{}
+	for _, g := range existing {
+		current[g.RawgID] = g
+	}
+
+	now := time.Now()
+	var writes []mongo.WriteModel
+	var changes []models.GameChange
+	// inserts[i] là vị trí trong changes của game mới ứng với write thứ i.
+	inserts := map[int]int{}
+	for _, id := range ids {
+		item := byID[id]
+		fields := sourceFields(item)
+		sourceUpdatedAt := parseSourceTime(item.Updated)
+
+		old, ok := current[id]
+		if !ok {
+			// Giá chỉ được tính khi thêm game; sau đó giá do admin quản lý
+			// (đổi qua /admin/pricing/apply).
+			price, _ := pricing.Price(pricing.Input{
+				Released: parseReleased(item.Released),
+				Rating:   item.Rating,
+				Genres:   item.GenreNames(),
+			})
+			set := bson.M{"source_updated_at": sourceUpdatedAt}
+			for k, v := range fields {
+				set[k] = v
+			}
+			inserts[len(writes)] = len(changes)
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"rawg_id": id}).
+				SetUpdate(bson.M{
+					"$set": set,
+					"$setOnInsert": bson.M{
+						"_id":         primitive.NewObjectID(),
+						"description": "No description available",
+						"price":       price,
+						"created_at":  now,
+						"updated_at":  now,
+					},
+				}).
+				SetUpsert(true))
+			changes = append(changes, models.GameChange{
+				JobID: jobID, RawgID: id, Name: item.Name, Created: true, CreatedAt: now,
+			})
+			continue
+		}
+
+		set := bson.M{}
+		diff := map[string]models.FieldChange{}
+		before := currentFields(old)
+		for k, v := range fields {
+			if !sameValue(before[k], v) {
+				set[k] = v
+				diff[k] = models.FieldChange{From: before[k], To: v}
+				r.Fields[k]++
+			}
+		}
+		if len(diff) > 0 {
+			set["updated_at"] = now
+			r.Updated++
+			changes = append(changes, models.GameChange{
+				JobID: jobID, GameID: old.ID, RawgID: id, Name: item.Name, Fields: diff, CreatedAt: now,
+			})
+		} else {
+			r.Unchanged++
+		}
+		if sourceUpdatedAt != nil && (old.SourceUpdatedAt == nil || !old.SourceUpdatedAt.Equal(*sourceUpdatedAt)) {
+			set["source_updated_at"] = sourceUpdatedAt
+		}
+		if len(set) > 0 {
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": old.ID}).
+				SetUpdate(bson.M{"$set": set}))
+		}
+	}
+	if len(writes) == 0 {
+		return r, nil
+	}
+
+	res, err := games.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return r, err
+	}
+	for idx, pos := range inserts {
+		id, ok := res.UpsertedIDs[int64(idx)]
+		if !ok {
+			// Game vừa được thêm bởi nơi khác giữa lúc đọc và ghi: upsert đã
+			// cập nhật nó, không tính là game mới.
+			changes[pos].Created = false
+			r.Updated++
+			continue
+		}
+		changes[pos].GameID, _ = id.(primitive.ObjectID)
+		r.Inserted++
+	}
+
+	if len(changes) > 0 {
+		docs := make([]interface{}, len(changes))
+		for i := range changes {
+			docs[i] = changes[i]
+		}
+		if _, err := config.DB.Collection("game_changes").InsertMany(ctx, docs, options.InsertMany().SetOrdered(false)); err != nil {
+			// Catalog đã được cập nhật; thiếu nhật ký thay đổi không làm hỏng job.
+			log.Printf("⚠️ Import job %s: không lưu được game_changes: %v", jobID.Hex(), err)
+		}
+	}
+	return r, nil
+}
+
+// Changes trả về các thay đổi catalog của một job, mới nhất trước.
+func Changes(ctx context.Context, jobID primitive.ObjectID, limit int64) ([]models.GameChange, error) {
+	cursor, err := config.DB.Collection("game_changes").Find(ctx,
+		bson.M{"job_id": jobID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+	list := []models.GameChange{}
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
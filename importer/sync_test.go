@@ -0,0 +1,169 @@
+package importer
+
+import (
+	"context"
+	"testing"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/config/mongotest"
+	"go-mvc-demo/models"
+	"go-mvc-demo/rawg"
+	"go-mvc-demo/rawg/rawgtest"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func fetchPage(t *testing.T, client *rawg.Client, page int) []rawg.Game {
+	t.Helper()
+	data, err := client.ListGames(context.Background(), rawg.ListOptions{Page: page, PageSize: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data.Results
+}
+
+func gameByRawgID(t *testing.T, rawgID int) models.Game {
+	t.Helper()
+	var g models.Game
+	if err := config.DB.Collection("games").FindOne(context.Background(), bson.M{"rawg_id": rawgID}).Decode(&g); err != nil {
+		t.Fatalf("game %d: %v", rawgID, err)
+	}
+	return g
+}
+
+func TestSyncGamesInsertsThenSkipsUnchanged(t *testing.T) {
+	mongotest.Setup(t)
+	client := rawgtest.New(t).Client()
+	ctx := context.Background()
+	items := fetchPage(t, client, 1)
+
+	jobID := primitive.NewObjectID()
+	r, err := syncGames(ctx, jobID, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Inserted != 3 || r.Updated != 0 || r.Unchanged != 0 || r.Skipped != 0 {
+		t.Fatalf("first sync = %+v, want 3 inserted", r)
+	}
+	g := gameByRawgID(t, 3328)
+	if g.Name != "The Witcher 3: Wild Hunt" || g.Price == 0 || g.SourceUpdatedAt == nil || g.CreatedAt == nil {
+		t.Fatalf("inserted game = %+v", g)
+	}
+	created, err := config.DB.Collection("game_changes").CountDocuments(ctx, bson.M{"job_id": jobID, "created": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created != 3 {
+		t.Fatalf("%d created changes, want 3", created)
+	}
+
+	again := primitive.NewObjectID()
+	r, err = syncGames(ctx, again, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Inserted != 0 || r.Updated != 0 || r.Unchanged != 3 || len(r.Fields) != 0 {
+		t.Fatalf("second sync = %+v, want 3 unchanged", r)
+	}
+	changes, err := Changes(ctx, again, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("unchanged sync recorded %d changes", len(changes))
+	}
+	if n, _ := config.DB.Collection("games").CountDocuments(ctx, bson.M{}); n != 3 {
+		t.Fatalf("%d games after resync, want 3", n)
+	}
+}
+
+func TestSyncGamesRecordsFieldDiff(t *testing.T) {
+	mongotest.Setup(t)
+	client := rawgtest.New(t).Client()
+	ctx := context.Background()
+	items := fetchPage(t, client, 1)
+
+	if _, err := syncGames(ctx, primitive.NewObjectID(), items); err != nil {
+		t.Fatal(err)
+	}
+	// Catalog bị sửa lệch khỏi RAWG; price và description do admin quản lý.
+	_, err := config.DB.Collection("games").UpdateOne(ctx, bson.M{"rawg_id": 4200}, bson.M{"$set": bson.M{
+		"name":        "Portal Two",
+		"rating":      1.5,
+		"price":       42,
+		"description": "edited by admin",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobID := primitive.NewObjectID()
+	r, err := syncGames(ctx, jobID, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Inserted != 0 || r.Updated != 1 || r.Unchanged != 2 {
+		t.Fatalf("sync = %+v, want 1 updated and 2 unchanged", r)
+	}
+	if r.Fields["name"] != 1 || r.Fields["rating"] != 1 || len(r.Fields) != 2 {
+		t.Fatalf("fields = %v, want name and rating", r.Fields)
+	}
+
+	g := gameByRawgID(t, 4200)
+	if g.Name != "Portal 2" || g.Rating != 4.61 {
+		t.Fatalf("source fields not restored: %q %v", g.Name, g.Rating)
+	}
+	if g.Price != 42 || g.Description != "edited by admin" {
+		t.Fatalf("sync overwrote admin fields: price %d, description %q", g.Price, g.Description)
+	}
+
+	changes, err := Changes(ctx, jobID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("%d changes, want 1", len(changes))
+	}
+	c := changes[0]
+	if c.RawgID != 4200 || c.GameID != g.ID || c.Created {
+		t.Fatalf("change = %+v", c)
+	}
+	if name := c.Fields["name"]; name.From != "Portal Two" || name.To != "Portal 2" {
+		t.Fatalf("name change = %+v", name)
+	}
+	if rating := c.Fields["rating"]; rating.From != 1.5 || rating.To != 4.61 {
+		t.Fatalf("rating change = %+v", rating)
+	}
+}
+
+func TestSyncGamesSkipsInvalidAndDuplicateRecords(t *testing.T) {
+	mongotest.Setup(t)
+	client := rawgtest.New(t).Client()
+	ctx := context.Background()
+
+	// Trang 2 có một bản ghi thiếu id/tên.
+	items := fetchPage(t, client, 2)
+	items = append(items, items[0])
+
+	r, err := syncGames(ctx, primitive.NewObjectID(), items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Inserted != 1 || r.Skipped != 2 {
+		t.Fatalf("sync = %+v, want 1 inserted and 2 skipped", r)
+	}
+	g := gameByRawgID(t, 5286)
+	if len(g.Genres) != 0 {
+		t.Fatalf("genres = %v, want empty", g.Genres)
+	}
+
+	// Lần sau genres null vẫn khớp với [] đã lưu.
+	r, err = syncGames(ctx, primitive.NewObjectID(), items[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Unchanged != 1 {
+		t.Fatalf("resync = %+v, want 1 unchanged", r)
+	}
+}
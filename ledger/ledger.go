@@ -0,0 +1,324 @@
+// Package ledger ghi nhận mọi biến động coin dưới dạng bút toán kép.
+//
+// coin_balance trên users vẫn được giữ làm số dư "nóng" để kiểm tra nhanh,
+// nhưng chỉ được thay đổi thông qua Debit/Credit của package này để luôn có
+// ledger_entries đi kèm. Các hàm nên được gọi bên trong config.WithTransaction
+// để cập nhật số dư và ghi bút toán là một khối nguyên tử.
+package ledger
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Loại nghiệp vụ của một biến động coin.
+const (
+	TypeRecharge   = "recharge"
+	TypePurchase   = "purchase"
+	TypeRental     = "rental"
+	TypeRefund     = "refund"
+	TypeAdjustment = "adjustment"
+	TypeBonus      = "bonus"
+)
+
+const (
+	DirectionDebit  = "debit"
+	DirectionCredit = "credit"
+)
+
+// Các tài khoản đối ứng phía hệ thống.
+const (
+	AccountRevenue     = "system:revenue"
+	AccountPayments    = "system:payments"
+	AccountAdjustments = "system:adjustments"
+	AccountPromotions  = "system:promotions"
+)
+
+var (
+	ErrUserNotFound        = errors.New("user not found")
+	ErrInsufficientBalance = errors.New("insufficient coin balance")
+	ErrInvalidAmount       = errors.New("amount must not be negative")
+)
+
+// Movement mô tả một biến động coin của user.
+type Movement struct {
+	UserID  primitive.ObjectID
+	Amount  int
+	Type    string
+	RefType string
+	RefID   primitive.ObjectID
+	Note    string
+	// Counterparty là tài khoản đối ứng; để trống sẽ dùng mặc định theo Type.
+	Counterparty string
+}
+
+func collection() *mongo.Collection {
+	return config.DB.Collection("ledger_entries")
+}
+
+// UserAccount trả về tên tài khoản ví của user trong sổ cái.
+func UserAccount(userID primitive.ObjectID) string {
+	return "user:" + userID.Hex()
+}
+
+func counterparty(m Movement) string {
+	if m.Counterparty != "" {
+		return m.Counterparty
+	}
+	switch m.Type {
+	case TypeRecharge:
+		return AccountPayments
+	case TypePurchase, TypeRental, TypeRefund:
+		return AccountRevenue
+	case TypeBonus:
+		return AccountPromotions
+	default:
+		return AccountAdjustments
+	}
+}
+
+// Debit trừ coin khỏi ví user (chỉ khi số dư đủ) và ghi cặp bút toán
+// debit ví user / credit tài khoản đối ứng.
+func Debit(ctx context.Context, m Movement) (int, error) {
+	if m.Amount < 0 {
+		return 0, ErrInvalidAmount
+	}
+	if m.Amount == 0 {
+		return currentBalance(ctx, m.UserID)
+	}
+	balance, err := applyBalance(ctx, m.UserID, -m.Amount,
+		bson.M{"_id": m.UserID, "coin_balance": bson.M{"$gte": m.Amount}})
+	if err != nil {
+		return 0, err
+	}
+	if err := post(ctx, m, DirectionDebit, balance); err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+// Credit cộng coin vào ví user và ghi cặp bút toán credit ví user /
+// debit tài khoản đối ứng.
+func Credit(ctx context.Context, m Movement) (int, error) {
+	if m.Amount < 0 {
+		return 0, ErrInvalidAmount
+	}
+	if m.Amount == 0 {
+		return currentBalance(ctx, m.UserID)
+	}
+	balance, err := applyBalance(ctx, m.UserID, m.Amount, bson.M{"_id": m.UserID})
+	if err != nil {
+		return 0, err
+	}
+	if err := post(ctx, m, DirectionCredit, balance); err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+// Adjust ghi một điều chỉnh thủ công có dấu: delta > 0 cộng, delta < 0 trừ.
+func Adjust(ctx context.Context, userID primitive.ObjectID, delta int, note string) (int, error) {
+	m := Movement{UserID: userID, Type: TypeAdjustment, RefType: "admin", Note: note}
+	if delta >= 0 {
+		m.Amount = delta
+		return Credit(ctx, m)
+	}
+	m.Amount = -delta
+	return Debit(ctx, m)
+}
+
+func applyBalance(ctx context.Context, userID primitive.ObjectID, delta int, filter bson.M) (int, error) {
+	var user models.User
+	err := config.DB.Collection("users").FindOneAndUpdate(ctx, filter,
+		bson.M{"$inc": bson.M{"coin_balance": delta}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err == nil {
+		return user.CoinBalance, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, err
+	}
+	count, err := config.DB.Collection("users").CountDocuments(ctx, bson.M{"_id": userID})
+	if err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, ErrUserNotFound
+	}
+	return 0, ErrInsufficientBalance
+}
+
+func currentBalance(ctx context.Context, userID primitive.ObjectID) (int, error) {
+	var user models.User
+	err := config.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, ErrUserNotFound
+	}
+	return user.CoinBalance, err
+}
+
+func post(ctx context.Context, m Movement, userDirection string, balanceAfter int) error {
+	now := time.Now()
+	txID := primitive.NewObjectID()
+	userID := m.UserID
+
+	var refID *primitive.ObjectID
+	if !m.RefID.IsZero() {
+		id := m.RefID
+		refID = &id
+	}
+
+	otherDirection := DirectionCredit
+	if userDirection == DirectionCredit {
+		otherDirection = DirectionDebit
+	}
+
+	entries := []interface{}{
+		models.LedgerEntry{
+			ID:            primitive.NewObjectID(),
+			TransactionID: txID,
+			Account:       UserAccount(m.UserID),
+			UserID:        &userID,
+			Direction:     userDirection,
+			Amount:        m.Amount,
+			Type:          m.Type,
+			RefType:       m.RefType,
+			RefID:         refID,
+			Note:          m.Note,
+			BalanceAfter:  &balanceAfter,
+			CreatedAt:     now,
+		},
+		models.LedgerEntry{
+			ID:            primitive.NewObjectID(),
+			TransactionID: txID,
+			Account:       counterparty(m),
+			Direction:     otherDirection,
+			Amount:        m.Amount,
+			Type:          m.Type,
+			RefType:       m.RefType,
+			RefID:         refID,
+			Note:          m.Note,
+			CreatedAt:     now,
+		},
+	}
+	_, err := collection().InsertMany(ctx, entries)
+	return err
+}
+
+// Balance tính số dư của user từ sổ cái: tổng credit trừ tổng debit.
+func Balance(ctx context.Context, userID primitive.ObjectID) (int, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"account": UserAccount(userID)}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": nil,
+			"balance": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$direction", DirectionCredit}},
+				"$amount",
+				bson.M{"$multiply": bson.A{"$amount", -1}},
+			}}},
+		}}},
+	}
+	cursor, err := collection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		Balance int `bson:"balance"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	return rows[0].Balance, nil
+}
+
+// Reconciliation so sánh số dư lưu trên users với số dư tính từ sổ cái.
+type Reconciliation struct {
+	StoredBalance int  `json:"stored_balance"`
+	LedgerBalance int  `json:"ledger_balance"`
+	Drift         int  `json:"drift"`
+	Reconciled    bool `json:"reconciled"`
+}
+
+// Reconcile trả về chênh lệch giữa coin_balance và sổ cái của user.
+func Reconcile(ctx context.Context, userID primitive.ObjectID) (Reconciliation, error) {
+	stored, err := currentBalance(ctx, userID)
+	if err != nil {
+		return Reconciliation{}, err
+	}
+	computed, err := Balance(ctx, userID)
+	if err != nil {
+		return Reconciliation{}, err
+	}
+	return Reconciliation{
+		StoredBalance: stored,
+		LedgerBalance: computed,
+		Drift:         stored - computed,
+		Reconciled:    stored == computed,
+	}, nil
+}
+
+// PostOpeningBalance ghi một bút toán điều chỉnh để sổ cái khớp với
+// coin_balance hiện tại (dùng cho tài khoản có số dư từ trước khi có ledger).
+// coin_balance không bị thay đổi.
+func PostOpeningBalance(ctx context.Context, userID primitive.ObjectID) (Reconciliation, error) {
+	rec, err := Reconcile(ctx, userID)
+	if err != nil || rec.Reconciled {
+		return rec, err
+	}
+
+	m := Movement{
+		UserID:  userID,
+		Type:    TypeAdjustment,
+		RefType: "reconciliation",
+		Note:    "opening balance",
+		Amount:  rec.Drift,
+	}
+	direction := DirectionCredit
+	if rec.Drift < 0 {
+		m.Amount = -rec.Drift
+		direction = DirectionDebit
+	}
+	if err := post(ctx, m, direction, rec.StoredBalance); err != nil {
+		return rec, err
+	}
+	return Reconcile(ctx, userID)
+}
+
+// Entries trả về các bút toán của ví user, mới nhất trước.
+func Entries(ctx context.Context, userID primitive.ObjectID, skip, limit int64) ([]models.LedgerEntry, int64, error) {
+	filter := bson.M{"account": UserAccount(userID)}
+	total, err := collection().CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cursor, err := collection().Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(skip).
+		SetLimit(limit))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []models.LedgerEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
@@ -0,0 +1,147 @@
+package ledger_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/config/mongotest"
+	"go-mvc-demo/ledger"
+	"go-mvc-demo/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// newUser tạo user có số dư opening, ghi qua ledger để sổ cái khớp ngay từ đầu.
+func newUser(t *testing.T, opening int) primitive.ObjectID {
+	t.Helper()
+	id := primitive.NewObjectID()
+	if _, err := config.DB.Collection("users").InsertOne(context.Background(), models.User{ID: id, Email: id.Hex() + "@example.com", Role: "user"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ledger.Credit(context.Background(), ledger.Movement{UserID: id, Amount: opening, Type: ledger.TypeBonus}); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// assertConsistent kiểm tra số dư không âm, khớp sổ cái và không bút toán
+// nào ghi balance_after âm.
+func assertConsistent(t *testing.T, userID primitive.ObjectID, want int) {
+	t.Helper()
+	ctx := context.Background()
+	rec, err := ledger.Reconcile(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.StoredBalance < 0 {
+		t.Fatalf("coin_balance went negative: %d", rec.StoredBalance)
+	}
+	if !rec.Reconciled {
+		t.Fatalf("ledger drift: %+v", rec)
+	}
+	if rec.StoredBalance != want {
+		t.Fatalf("balance = %d, want %d", rec.StoredBalance, want)
+	}
+	n, err := config.DB.Collection("ledger_entries").CountDocuments(ctx, bson.M{"user_id": userID, "balance_after": bson.M{"$lt": 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n > 0 {
+		t.Fatalf("%d ledger entries recorded a negative balance", n)
+	}
+}
+
+func TestConcurrentDebitsNeverOverdraw(t *testing.T) {
+	mongotest.Setup(t)
+	userID := newUser(t, 1000)
+
+	const workers, amount = 40, 100
+	var ok, insufficient atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+				_, err := ledger.Debit(sessCtx, ledger.Movement{UserID: userID, Amount: amount, Type: ledger.TypePurchase})
+				return err
+			})
+			switch {
+			case err == nil:
+				ok.Add(1)
+			case errors.Is(err, ledger.ErrInsufficientBalance):
+				insufficient.Add(1)
+			default:
+				t.Errorf("Debit: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ok.Load() != 10 || insufficient.Load() != workers-10 {
+		t.Fatalf("succeeded %d, insufficient %d; want 10 and %d", ok.Load(), insufficient.Load(), workers-10)
+	}
+	assertConsistent(t, userID, 0)
+}
+
+func TestConcurrentDebitsAndCreditsStayConsistent(t *testing.T) {
+	mongotest.Setup(t)
+	userID := newUser(t, 300)
+
+	const debits, credits = 60, 30
+	const debitAmount, creditAmount = 70, 50
+	var debited, credited atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < debits+credits; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+				if i%3 == 2 {
+					_, err := ledger.Credit(sessCtx, ledger.Movement{UserID: userID, Amount: creditAmount, Type: ledger.TypeRecharge})
+					return err
+				}
+				_, err := ledger.Debit(sessCtx, ledger.Movement{UserID: userID, Amount: debitAmount, Type: ledger.TypeRental})
+				return err
+			})
+			switch {
+			case err == nil && i%3 == 2:
+				credited.Add(1)
+			case err == nil:
+				debited.Add(1)
+			case !errors.Is(err, ledger.ErrInsufficientBalance):
+				t.Errorf("transaction: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if credited.Load() != credits {
+		t.Fatalf("credited %d times, want %d", credited.Load(), credits)
+	}
+	want := 300 + int(credited.Load())*creditAmount - int(debited.Load())*debitAmount
+	assertConsistent(t, userID, want)
+}
+
+func TestFailedTransactionRollsBackDebit(t *testing.T) {
+	mongotest.Setup(t)
+	userID := newUser(t, 500)
+
+	boom := errors.New("boom")
+	err := config.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		if _, err := ledger.Debit(sessCtx, ledger.Movement{UserID: userID, Amount: 200, Type: ledger.TypePurchase}); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("WithTransaction = %v, want boom", err)
+	}
+	assertConsistent(t, userID, 500)
+}
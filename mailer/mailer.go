@@ -0,0 +1,98 @@
+// Package mailer gửi email giao dịch (xác minh email, đặt lại mật khẩu...).
+//
+// SMTPMailer gửi qua một SMTP server bất kỳ, kể cả SMTP sink chạy local như
+// MailHog hay Mailpit. LogMailer chỉ ghi nội dung ra log, dùng khi chưa cấu
+// hình SMTP.
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message là một email dạng text.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer gửi email.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var ErrInvalidRecipient = errors.New("invalid recipient")
+
+// SMTPMailer gửi email qua SMTP. Nếu Username rỗng thì không xác thực
+// (phù hợp với SMTP sink local).
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// Send gửi msg qua SMTP server đã cấu hình.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") || msg.To == "" {
+		return ErrInvalidRecipient
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, m.From, []string{msg.To}, m.build(msg))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *SMTPMailer) build(msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", strings.NewReplacer("\r", "", "\n", "").Replace(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// secretPattern khớp các token bí mật (base64url/hex từ 32 ký tự trở lên)
+// trong link xác minh, đặt lại mật khẩu...
+var secretPattern = regexp.MustCompile(`[A-Za-z0-9_-]{32,}`)
+
+// Redact che các token bí mật trong nội dung email để không lộ qua log.
+func Redact(body string) string {
+	return secretPattern.ReplaceAllString(body, "[REDACTED]")
+}
+
+// LogMailer ghi email ra log thay vì gửi. Token trong nội dung bị che đi,
+// nên link trong log không dùng được.
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("📧 [mail] to=%s subject=%q\n%s", msg.To, msg.Subject, Redact(msg.Body))
+	return nil
+}
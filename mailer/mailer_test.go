@@ -0,0 +1,219 @@
+package mailer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sink là một SMTP server tối giản nhận mail và lưu lại để kiểm tra, giống
+// MailHog/Mailpit nhưng chạy trong test.
+type sink struct {
+	ln   net.Listener
+	mu   sync.Mutex
+	mail []received
+}
+
+type received struct {
+	From string
+	To   []string
+	Data string
+}
+
+func newSink(t *testing.T) *sink {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &sink{ln: ln}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *sink) mailer() *SMTPMailer {
+	host, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return &SMTPMailer{Host: host, Port: p, From: "noreply@example.com"}
+}
+
+func (s *sink) messages() []received {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]received(nil), s.mail...)
+}
+
+func (s *sink) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *sink) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 sink ready")
+	var cur received
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+		upper := strings.ToUpper(cmd)
+		switch {
+		case strings.HasPrefix(upper, "EHLO"), strings.HasPrefix(upper, "HELO"):
+			reply("250 sink")
+		case strings.HasPrefix(upper, "MAIL FROM:"):
+			cur = received{From: strings.Trim(cmd[len("MAIL FROM:"):], "<> ")}
+			reply("250 OK")
+		case strings.HasPrefix(upper, "RCPT TO:"):
+			cur.To = append(cur.To, strings.Trim(cmd[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case upper == "DATA":
+			reply("354 end with .")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			cur.Data = data.String()
+			s.mu.Lock()
+			s.mail = append(s.mail, cur)
+			s.mu.Unlock()
+			reply("250 queued")
+		case upper == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPMailerDeliversToSink(t *testing.T) {
+	s := newSink(t)
+	err := s.mailer().Send(context.Background(), Message{
+		To:      "user@example.com",
+		Subject: "Xác minh email\r\nBcc: victim@example.com",
+		Body:    "Dòng 1\nDòng 2",
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	got := s.messages()
+	if len(got) != 1 {
+		t.Fatalf("sink received %d messages, want 1", len(got))
+	}
+	m := got[0]
+	if m.From != "noreply@example.com" || len(m.To) != 1 || m.To[0] != "user@example.com" {
+		t.Errorf("envelope = from %q to %v", m.From, m.To)
+	}
+	header, body, ok := strings.Cut(m.Data, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("message has no header/body separator: %q", m.Data)
+	}
+	for _, want := range []string{
+		"From: noreply@example.com",
+		"To: user@example.com",
+		"Subject: Xác minh emailBcc: victim@example.com",
+		"Content-Type: text/plain; charset=UTF-8",
+	} {
+		if !strings.Contains(header, want) {
+			t.Errorf("header missing %q:\n%s", want, header)
+		}
+	}
+	if strings.Contains(header, "\r\nBcc:") {
+		t.Errorf("subject injected a header:\n%s", header)
+	}
+	if body != "Dòng 1\r\nDòng 2\r\n" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestSMTPMailerRejectsInvalidRecipient(t *testing.T) {
+	s := newSink(t)
+	for _, to := range []string{"", "a@example.com\r\nRCPT TO:<b@example.com>"} {
+		err := s.mailer().Send(context.Background(), Message{To: to, Subject: "x", Body: "x"})
+		if !errors.Is(err, ErrInvalidRecipient) {
+			t.Errorf("Send(to=%q) = %v, want ErrInvalidRecipient", to, err)
+		}
+	}
+	if n := len(s.messages()); n != 0 {
+		t.Errorf("sink received %d messages, want 0", n)
+	}
+}
+
+func TestSMTPMailerHonorsContext(t *testing.T) {
+	// Server nhận kết nối nhưng không bao giờ gửi lời chào.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	m := &SMTPMailer{Host: host, Port: p, From: "noreply@example.com"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = m.Send(ctx, Message{To: "user@example.com", Subject: "x", Body: "x"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Send = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestLogMailerRedactsTokens(t *testing.T) {
+	var buf bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(prev)
+
+	token := "Zk3x9Qp0Lm2Nv8Rt4Yw6Ab1Cd5Ef7Gh-_Ij0Kl2Mn4"
+	body := "Mở link: https://example.com/reset-password?token=" + token + "\nMã đặt lại: " + token + "\nLink hết hạn sau 30 phút."
+	if err := (LogMailer{}).Send(context.Background(), Message{To: "user@example.com", Subject: "Reset", Body: body}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, token) {
+		t.Fatalf("log contains the token:\n%s", out)
+	}
+	for _, want := range []string{"to=user@example.com", "token=[REDACTED]", "Mã đặt lại: [REDACTED]", "Link hết hạn sau 30 phút."} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log missing %q:\n%s", want, out)
+		}
+	}
+}
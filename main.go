@@ -1,13 +1,29 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go-mvc-demo/auth"
 	"go-mvc-demo/config"
 	controllers "go-mvc-demo/controller"
 	_ "go-mvc-demo/docs"
+	"go-mvc-demo/importer"
+	"go-mvc-demo/mailer"
 	"go-mvc-demo/middleware"
+	"go-mvc-demo/migrations"
+	"go-mvc-demo/oidc"
+	"go-mvc-demo/payment"
+	"go-mvc-demo/rawg"
 	routes "go-mvc-demo/router"
-	"math/rand"
+	"go-mvc-demo/worker"
+	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -24,25 +40,79 @@ import (
 // @schemes https
 
 func main() {
-	config.ConnectDB()
+	migrate := len(os.Args) > 1 && os.Args[1] == "migrate"
+	flagArgs := os.Args[1:]
+	if migrate {
+		flagArgs = nil
+	}
+	cfg, err := config.Load(flagArgs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	config.ConnectDB(cfg.Mongo)
+
+	if migrate {
+		runMigration(os.Args[2:])
+		return
+	}
+
+	if err := config.EnsureIndexes(); err != nil {
+		log.Fatal(err)
+	}
+
+	auth.Configure(cfg.Auth)
+	controllers.SetMailer(newMailer(cfg.Mail))
+	controllers.SetPaymentProvider(newPaymentProvider(cfg))
+	if cfg.OIDC.IssuerURL != "" {
+		controllers.SetOIDCProvider(oidc.NewProvider(oidc.Config{
+			Name:         cfg.OIDC.Provider,
+			Issuer:       cfg.OIDC.IssuerURL,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: cfg.OIDC.ClientSecret,
+			RedirectURL:  cfg.OIDC.RedirectURL,
+			Scopes:       cfg.OIDC.Scopes,
+		}))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.RAWG.APIKey != "" {
+		importer.Configure(rawg.New(cfg.RAWG.APIKey, rawg.Options{}))
+	}
+
+	scheduler := newScheduler(cfg)
+	scheduler.Start(ctx)
 
 	r := gin.Default()
-	rand.Seed(time.Now().UnixNano())
+	r.Use(controllers.Settings(cfg))
 
 	// Configure CORS middleware properly at the beginning
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
+		AllowOrigins:     cfg.Server.CORSOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Accept"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Accept", "Idempotency-Key", "X-API-Key", "X-Device-ID"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
-	auth := r.Group("/auth")
+	authGroup := r.Group("/auth")
 	{
-		auth.POST("/register", controllers.Register)
-		auth.POST("/login", controllers.Login)
+		authGroup.POST("/register", controllers.Register)
+		authGroup.POST("/login", controllers.Login)
+		authGroup.POST("/refresh", controllers.RefreshToken)
+		authGroup.GET("/verify", controllers.VerifyEmail)
+		authGroup.GET("/confirm-email", controllers.ConfirmEmailChange)
+		authGroup.POST("/resend-verification", controllers.ResendVerification)
+		authGroup.POST("/forgot-password", controllers.ForgotPassword)
+		authGroup.POST("/reset-password", controllers.ResetPassword)
+		authGroup.POST("/mfa/verify", controllers.VerifyMFALogin)
+		authGroup.GET("/oidc/:provider/login", controllers.OIDCLogin)
+		authGroup.GET("/oidc/:provider/callback", controllers.OIDCCallback)
+		authGroup.POST("/logout", middleware.AuthMiddleware(), controllers.Logout)
+		authGroup.POST("/logout-all", middleware.AuthMiddleware(), controllers.LogoutAll)
 	}
 
 	protected := r.Group("/api")
@@ -54,15 +124,167 @@ func main() {
 		})
 		protected.GET("/my-purchases", controllers.GetPurchasedGames)
 		protected.GET("/my-rentals", controllers.GetRentedGames)
+		protected.GET("/library", controllers.GetLibrary)
+		protected.POST("/change-password", controllers.ChangePassword)
+		protected.GET("/login-history", controllers.GetLoginHistory)
+		protected.POST("/mfa/enroll", controllers.EnrollMFA)
+		protected.POST("/mfa/confirm", controllers.ConfirmMFA)
+		protected.POST("/mfa/disable", controllers.DisableMFA)
+		protected.POST("/mfa/recovery-codes", controllers.RegenerateRecoveryCodes)
+		protected.GET("/api-keys", controllers.ListAPIKeys)
+		protected.POST("/api-keys", controllers.CreateAPIKey)
+		protected.DELETE("/api-keys/:id", controllers.RevokeAPIKey)
+		protected.GET("/sessions", controllers.ListSessions)
+		protected.DELETE("/sessions/:id", controllers.RevokeSession)
 
 	}
 	routes.UserRoutes(r)
 	routes.GameRoutes(r)
-	routes.TransactionRoutes(r)
+	routes.TransactionRoutes(r, cfg.Idempotency)
+	routes.PaymentRoutes(r, cfg.Payment.Provider == config.PaymentFake)
+	routes.AdminRoutes(r)
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	srv := &http.Server{
+		Addr:         ":" + cfg.Server.Port,
+		Handler:      r,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Đang tắt server...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("⚠️ Shutdown lỗi: %v", err)
+	}
+	scheduler.Wait()
+	config.DisconnectDB(shutdownCtx)
+}
+
+// newMailer dùng SMTP khi đã cấu hình SMTP_HOST, nếu không thì chỉ ghi email ra log.
+func newMailer(cfg config.MailConfig) mailer.Mailer {
+	if cfg.SMTPHost == "" {
+		log.Println("⚠️ SMTP_HOST chưa được cấu hình, email chỉ được ghi ra log")
+		return mailer.LogMailer{}
+	}
+	return &mailer.SMTPMailer{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.From,
 	}
-	r.Run(":" + port)
+}
+
+// runMigration chạy một migration dữ liệu: server migrate <tên> [-apply].
+// Mặc định chỉ chạy thử và in báo cáo.
+func runMigration(args []string) {
+	if len(args) == 0 {
+		log.Fatal("usage: server migrate dedupe-entitlements|dedupe-games [-apply]")
+	}
+	fs := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+	apply := fs.Bool("apply", false, "ghi thay đổi vào DB (mặc định chỉ chạy thử)")
+	fs.Parse(args[1:])
+
+	ctx := context.Background()
+	defer config.DisconnectDB(ctx)
+
+	switch args[0] {
+	case "dedupe-entitlements":
+		report, err := migrations.DedupeEntitlements(ctx, *apply)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printReport(report, *apply)
+	case "dedupe-games":
+		report, err := migrations.DedupeGames(ctx, *apply)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printReport(report, *apply)
+	default:
+		log.Fatalf("unknown migration %q", args[0])
+	}
+}
+
+// printReport in báo cáo migration và, khi đã ghi vào DB, tạo lại các index
+// mà dữ liệu cũ chặn.
+func printReport(report interface{}, applied bool) {
+	out, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(out))
+	if !applied {
+		return
+	}
+	if err := config.EnsureIndexes(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newPaymentProvider chọn cổng thanh toán theo cấu hình. config.Load đã
+// chặn fake gateway ngoài development/test.
+func newPaymentProvider(cfg *config.Config) payment.Provider {
+	if cfg.Payment.Provider == config.PaymentFake {
+		log.Printf("⚠️ Đang dùng fake payment gateway (APP_ENV=%s)", cfg.Server.Env)
+		return payment.NewFakeGateway(cfg.Payment.WebhookSecret, cfg.Server.PublicBaseURL)
+	}
+	return payment.NewHostedGateway(cfg.Payment.WebhookSecret, cfg.Payment.CheckoutURL, cfg.Server.PublicBaseURL)
+}
+
+// newScheduler đăng ký các job nền chạy trong process server.
+func newScheduler(cfg *config.Config) *worker.Scheduler {
+	scheduler := worker.NewScheduler()
+
+	scheduler.Add(worker.Job{
+		Name:     "rental-expiry",
+		Interval: cfg.Rental.SweepInterval,
+		Run: func(ctx context.Context) error {
+			n, err := controllers.ExpireRentals(ctx, cfg.Rental.SweepBatch)
+			if n > 0 {
+				log.Printf("Đã expire %d rental quá hạn", n)
+			}
+			return err
+		},
+	})
+
+	scheduler.Add(worker.Job{
+		Name:     "recharge-expiry",
+		Interval: time.Minute,
+		Run: func(ctx context.Context) error {
+			n, err := controllers.ExpirePendingRecharges(ctx)
+			if n > 0 {
+				log.Printf("Đã expire %d recharge quá hạn", n)
+			}
+			return err
+		},
+	})
+
+	// Job import dài hơn lease của scheduler; việc mỗi import job chỉ chạy
+	// trên một replica do lease riêng trong import_jobs đảm bảo.
+	scheduler.Add(worker.Job{
+		Name:     "rawg-import",
+		Interval: 10 * time.Second,
+		Run:      importer.RunPending,
+	})
+
+	scheduler.Add(worker.Job{
+		Name:     "rawg-enrich",
+		Interval: time.Minute,
+		LeaseTTL: 10 * time.Minute,
+		Run: func(ctx context.Context) error {
+			n, err := importer.EnrichPending(ctx, cfg.RAWG.EnrichBatch, cfg.RAWG.EnrichConcurrency)
+			if n > 0 {
+				log.Printf("Đã enrich %d game từ RAWG", n)
+			}
+			return err
+		},
+	})
+
+	return scheduler
 }
@@ -1,17 +1,101 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strings"
 
+	"go-mvc-demo/auth"
+
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("SECRET_KEY")
+// setClaims gán thông tin của access token vào context cho các handler sau.
+func setClaims(c *gin.Context, claims *auth.Claims) {
+	c.Set("user_id", claims.UserID)
+	c.Set("role", claims.Role)
+	c.Set("jti", claims.ID)
+	c.Set("session_id", claims.SessionID)
+	c.Set("token_exp", claims.ExpiresAt.Time)
+}
+
+// mfaEnrollmentRoutes là các route mà token của admin chưa bật 2FA được
+// phép gọi.
+var mfaEnrollmentRoutes = map[string]bool{
+	"/api/mfa/enroll":  true,
+	"/api/mfa/confirm": true,
+	"/auth/logout":     true,
+	"/auth/logout-all": true,
+}
+
+// apiKeyDeniedPrefixes là các route quản lý tài khoản/phiên đăng nhập; API
+// key không được dùng để gọi chúng, tránh key bị lộ thành quyền kiểm soát
+// tài khoản lâu dài (đổi email/mật khẩu, đổi role, xoá user...).
+var apiKeyDeniedPrefixes = []string{
+	"/auth/",
+	"/users/",
+	"/api/change-password",
+	"/api/mfa/",
+	"/api/api-keys",
+	"/api/sessions",
+	"/payments/fake/",
+}
+
+// setAPIKey gán thông tin của API key vào context với cùng key như JWT;
+// api_key_scopes giới hạn thêm các quyền của role.
+func setAPIKey(c *gin.Context, id auth.APIKeyIdentity) {
+	c.Set("user_id", id.User.ID.Hex())
+	c.Set("role", id.User.Role)
+	c.Set("api_key_id", id.Key.ID.Hex())
+	c.Set("api_key_scopes", id.Scopes())
+}
+
+// authenticateAPIKey xử lý request gửi header X-API-Key thay cho Bearer token.
+func authenticateAPIKey(c *gin.Context, key string) {
+	for _, prefix := range apiKeyDeniedPrefixes {
+		if strings.HasPrefix(c.FullPath(), prefix) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot be used for this endpoint"})
+			c.Abort()
+			return
+		}
+	}
+
+	id, err := auth.AuthenticateAPIKey(context.TODO(), key, c.ClientIP())
+	switch {
+	case errors.Is(err, auth.ErrTokenRevoked):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API key revoked"})
+		c.Abort()
+		return
+	case errors.Is(err, auth.ErrInvalidAPIKey):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		c.Abort()
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot verify API key"})
+		c.Abort()
+		return
+	}
+
+	if auth.MFAEnrollmentRequired(id.User) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor enrollment required", "mfa_enroll_required": true})
+		c.Abort()
+		return
+	}
+
+	setAPIKey(c, id)
+	c.Next()
+}
 
+// AuthMiddleware chấp nhận access token (Authorization: Bearer) hoặc API key
+// (X-API-Key).
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key := c.GetHeader("X-API-Key"); key != "" {
+			authenticateAPIKey(c, key)
+			return
+		}
+
 		tokenString := c.GetHeader("Authorization")
 		if tokenString == "" || !strings.HasPrefix(tokenString, "Bearer ") {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
@@ -20,28 +104,61 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		tokenString = strings.TrimPrefix(tokenString, "Bearer ")
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
-		if err != nil {
+		claims, err := auth.Authenticate(context.TODO(), tokenString, c.ClientIP())
+		switch {
+		case errors.Is(err, auth.ErrTokenRevoked):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token revoked"})
+			c.Abort()
+			return
+		case errors.Is(err, auth.ErrInvalidToken):
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot verify token"})
+			c.Abort()
+			return
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			c.Set("user_id", claims["user_id"])
-			c.Set("role", claims["role"])
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		if claims.MFAEnroll && !mfaEnrollmentRoutes[c.FullPath()] {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor enrollment required", "mfa_enroll_required": true})
 			c.Abort()
 			return
 		}
 
+		setClaims(c, claims)
 		c.Next()
 	}
 
 }
+
+// OptionalAuthMiddleware giống AuthMiddleware nhưng không bắt buộc token:
+// token hợp lệ thì gán user_id/role vào context, thiếu hoặc sai thì bỏ qua.
+// Dùng cho các route công khai muốn trả thêm thông tin theo user.
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key := c.GetHeader("X-API-Key"); key != "" {
+			if id, err := auth.AuthenticateAPIKey(context.TODO(), key, c.ClientIP()); err == nil && !auth.MFAEnrollmentRequired(id.User) {
+				setAPIKey(c, id)
+			}
+			c.Next()
+			return
+		}
+
+		tokenString := c.GetHeader("Authorization")
+		if !strings.HasPrefix(tokenString, "Bearer ") {
+			c.Next()
+			return
+		}
+
+		if claims, err := auth.Authenticate(context.TODO(), strings.TrimPrefix(tokenString, "Bearer "), c.ClientIP()); err == nil && !claims.MFAEnroll {
+			setClaims(c, claims)
+		}
+
+		c.Next()
+	}
+}
+
 func AdminMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		role, exists := c.Get("role")
@@ -58,6 +175,40 @@ func AdminMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// API key của admin chỉ vào được khu admin khi được cấp ít nhất một
+		// scope quản trị.
+		if scopes, isKey := c.Get("api_key_scopes"); isKey && !auth.HasAdminScope(scopes.([]auth.Permission)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key has no admin scopes"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePermission chỉ cho request đi tiếp khi role của user có đủ mọi quyền
+// được liệt kê (và API key, nếu dùng, được cấp các scope đó). Phải đặt sau
+// AuthMiddleware.
+func RequirePermission(perms ...auth.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, exists := c.Get("role")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+
+		roleStr, _ := role.(string)
+		scopes, isKey := c.Get("api_key_scopes")
+		for _, p := range perms {
+			if !auth.HasPermission(roleStr, p) || (isKey && !auth.ScopeIncludes(scopes.([]auth.Permission), p)) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Missing permission", "permission": p})
+				c.Abort()
+				return
+			}
+		}
+
 		c.Next()
 	}
 }
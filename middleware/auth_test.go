@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-mvc-demo/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// identity là người gọi đã qua bước xác thực: JWT (scopes nil) hoặc API key.
+type identity struct {
+	role   string
+	apiKey bool
+	scopes []auth.Permission
+}
+
+func (id identity) set(c *gin.Context) {
+	c.Set("user_id", "000000000000000000000001")
+	c.Set("role", id.role)
+	if id.apiKey {
+		c.Set("api_key_scopes", id.scopes)
+	}
+}
+
+var identities = map[string]identity{
+	"user":                  {role: auth.RoleUser},
+	"admin":                 {role: auth.RoleAdmin},
+	"no role":               {role: ""},
+	"user key":              {role: auth.RoleUser, apiKey: true},
+	"user key spend":        {role: auth.RoleUser, apiKey: true, scopes: []auth.Permission{auth.PermWalletSpend}},
+	"admin key":             {role: auth.RoleAdmin, apiKey: true},
+	"admin key spend":       {role: auth.RoleAdmin, apiKey: true, scopes: []auth.Permission{auth.PermWalletSpend}},
+	"admin key games":       {role: auth.RoleAdmin, apiKey: true, scopes: []auth.Permission{auth.PermGamesWrite}},
+	"admin key users+games": {role: auth.RoleAdmin, apiKey: true, scopes: []auth.Permission{auth.PermUsersAdmin, auth.PermGamesWrite}},
+}
+
+// guardedRoutes lặp lại các guard phân quyền đặt trong router/*.go.
+var guardedRoutes = []struct {
+	method, path string
+	guards       []gin.HandlerFunc
+	// allowed là các identity được đi tiếp; còn lại phải nhận 403.
+	allowed []string
+}{
+	{http.MethodPost, "/buy/:id", []gin.HandlerFunc{RequirePermission(auth.PermWalletSpend)},
+		[]string{"user", "admin", "user key spend", "admin key spend"}},
+	{http.MethodPost, "/recharge", []gin.HandlerFunc{RequirePermission(auth.PermWalletSpend)},
+		[]string{"user", "admin", "user key spend", "admin key spend"}},
+	{http.MethodPost, "/wallet/reconcile/:id", []gin.HandlerFunc{RequirePermission(auth.PermWalletAdjust)},
+		[]string{"admin"}},
+	{http.MethodGet, "/users/", []gin.HandlerFunc{RequirePermission(auth.PermUsersAdmin)},
+		[]string{"admin", "admin key users+games"}},
+	{http.MethodPost, "/games/", []gin.HandlerFunc{RequirePermission(auth.PermGamesWrite)},
+		[]string{"admin", "admin key games", "admin key users+games"}},
+	{http.MethodPost, "/admin/imports", []gin.HandlerFunc{AdminMiddleware(), RequirePermission(auth.PermGamesWrite)},
+		[]string{"admin", "admin key games", "admin key users+games"}},
+	{http.MethodPut, "/admin/rental-plans", []gin.HandlerFunc{AdminMiddleware(), RequirePermission(auth.PermRentalPlansWrite)},
+		[]string{"admin"}},
+	{http.MethodPost, "/admin/users/:id/unlock", []gin.HandlerFunc{AdminMiddleware(), RequirePermission(auth.PermUsersAdmin)},
+		[]string{"admin", "admin key users+games"}},
+	{http.MethodPost, "/admin/pricing/apply", []gin.HandlerFunc{AdminMiddleware(), RequirePermission(auth.PermPricingWrite)},
+		[]string{"admin"}},
+}
+
+func TestAccessMatrix(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, route := range guardedRoutes {
+		allowed := map[string]bool{}
+		for _, name := range route.allowed {
+			allowed[name] = true
+		}
+		for name, id := range identities {
+			t.Run(route.method+" "+route.path+"/"+name, func(t *testing.T) {
+				r := gin.New()
+				handlers := append([]gin.HandlerFunc{id.set}, route.guards...)
+				handlers = append(handlers, func(c *gin.Context) { c.Status(http.StatusNoContent) })
+				r.Handle(route.method, route.path, handlers...)
+
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest(route.method, route.path, nil))
+
+				want := http.StatusForbidden
+				if allowed[name] {
+					want = http.StatusNoContent
+				}
+				if w.Code != want {
+					t.Errorf("status = %d, want %d: %s", w.Code, want, w.Body)
+				}
+			})
+		}
+	}
+}
+
+func TestRequirePermissionWithoutAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/buy/:id", RequirePermission(auth.PermWalletSpend), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/buy/1", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", w.Code)
+	}
+}
+
+// Các route quản lý tài khoản bị chặn với API key trước khi key được kiểm
+// tra, nên không cần DB.
+func TestAPIKeyDeniedRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	for _, route := range []struct{ method, path string }{
+		{http.MethodPost, "/auth/logout"},
+		{http.MethodGet, "/users/"},
+		{http.MethodGet, "/users/:id"},
+		{http.MethodPut, "/users/:id"},
+		{http.MethodDelete, "/users/:id"},
+		{http.MethodPut, "/users/:id/promote"},
+		{http.MethodPost, "/api/change-password"},
+		{http.MethodPost, "/api/mfa/disable"},
+		{http.MethodPost, "/api/api-keys"},
+		{http.MethodDelete, "/api/sessions/:id"},
+		{http.MethodPost, "/payments/fake/:reference/:outcome"},
+	} {
+		r.Handle(route.method, route.path, AuthMiddleware(), ok)
+		path := strings.NewReplacer(":id", "x", ":reference", "x", ":outcome", "x").Replace(route.path)
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			req := httptest.NewRequest(route.method, path, nil)
+			req.Header.Set("X-API-Key", auth.APIKeyPrefix+"anything")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want 403: %s", w.Code, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	IdempotencyHeader     = "Idempotency-Key"
+	maxIdempotencyKey     = 255
+	idempotencyCompleted  = "completed"
+	idempotencyProcessing = "processing"
+)
+
+// capturingWriter ghi lại body response để lưu cho các lần retry.
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency lưu kết quả request theo (user_id, Idempotency-Key) trong
+// cfg.TTL. Phải đặt sau AuthMiddleware. Request lặp lại cùng key và cùng body
+// sẽ nhận lại response đã lưu thay vì chạy handler lần nữa; khác body thì bị
+// 422. Request không có header được xử lý bình thường.
+//
+// Khi đang xử lý, key được giữ bằng lease cfg.Lease và được gia hạn định kỳ
+// tới khi handler trả về, nên handler chạy lâu (vd. transaction retry) không
+// bị một request retry chạy song song. Handler panic thì key được trả ngay,
+// process chết giữa chừng thì lease không còn được gia hạn và key hết hạn để
+// client retry được.
+func Idempotency(cfg config.IdempotencyConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key too long"})
+			return
+		}
+
+		userID := fmt.Sprint(c.MustGet("user_id"))
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Cannot read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256([]byte(c.Request.Method + "\n" + c.Request.URL.Path + "\n" + string(body)))
+		requestHash := hex.EncodeToString(sum[:])
+
+		collection := config.DB.Collection("idempotency_keys")
+		now := time.Now()
+		record := models.IdempotencyRecord{
+			ID:          primitive.NewObjectID(),
+			UserID:      userID,
+			Key:         key,
+			RequestHash: requestHash,
+			State:       idempotencyProcessing,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(cfg.Lease),
+		}
+
+		// TTL monitor của Mongo chỉ chạy mỗi phút, nên tự dọn key đã hết hạn
+		// (kể cả key processing đã hết lease).
+		collection.DeleteOne(context.TODO(), bson.M{"user_id": userID, "key": key, "expires_at": bson.M{"$lte": now}})
+
+		_, err = collection.InsertOne(context.TODO(), record)
+		if mongo.IsDuplicateKeyError(err) {
+			replayIdempotent(c, collection, userID, key, requestHash)
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to store idempotency key"})
+			return
+		}
+
+		release := func() {
+			// Chỉ xoá đúng bản ghi của request này khi nó còn đang xử lý.
+			collection.DeleteOne(context.Background(), bson.M{"_id": record.ID, "state": idempotencyProcessing})
+		}
+		stopRenewing := renewLease(collection, record.ID, cfg.Lease)
+		defer func() {
+			if r := recover(); r != nil {
+				stopRenewing()
+				release()
+				panic(r)
+			}
+		}()
+
+		writer := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		stopRenewing()
+
+		status := writer.Status()
+		if status >= http.StatusInternalServerError {
+			// Lỗi phía server: xoá key để client có thể retry.
+			release()
+			return
+		}
+
+		collection.UpdateOne(context.TODO(),
+			bson.M{"_id": record.ID, "state": idempotencyProcessing},
+			bson.M{"$set": bson.M{
+				"state":         idempotencyCompleted,
+				"status_code":   status,
+				"content_type":  writer.Header().Get("Content-Type"),
+				"response_body": writer.body.Bytes(),
+				"expires_at":    time.Now().Add(cfg.TTL),
+			}})
+	}
+}
+
+// renewLease gia hạn expires_at của bản ghi processing thêm lease mỗi lease/3
+// cho tới khi hàm trả về được gọi. Hàm đó chờ goroutine dừng hẳn để không còn
+// lần gia hạn nào chạy sau khi key đã được ghi kết quả hoặc trả lại.
+func renewLease(collection *mongo.Collection, id primitive.ObjectID, lease time.Duration) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				collection.UpdateOne(context.Background(),
+					bson.M{"_id": id, "state": idempotencyProcessing},
+					bson.M{"$set": bson.M{"expires_at": time.Now().Add(lease)}})
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func replayIdempotent(c *gin.Context, collection *mongo.Collection, userID, key, requestHash string) {
+	var existing models.IdempotencyRecord
+	err := collection.FindOne(context.TODO(), bson.M{"user_id": userID, "key": key}).Decode(&existing)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to load idempotency key"})
+		return
+	}
+
+	if existing.RequestHash != requestHash {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+		return
+	}
+	if existing.State != idempotencyCompleted {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+		return
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(existing.StatusCode, existing.ContentType, existing.ResponseBody)
+	c.Abort()
+}
@@ -0,0 +1,199 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/config/mongotest"
+	"go-mvc-demo/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var testIdempotency = config.IdempotencyConfig{TTL: time.Hour, Lease: time.Minute}
+
+// idempotentRouter gắn handler sau Idempotency và đếm số lần handler chạy.
+func idempotentRouter(handler gin.HandlerFunc) (*gin.Engine, *atomic.Int64) {
+	gin.SetMode(gin.TestMode)
+	var calls atomic.Int64
+	r := gin.New()
+	r.Use(gin.CustomRecovery(func(c *gin.Context, _ interface{}) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	r.POST("/buy/:id",
+		func(c *gin.Context) { c.Set("user_id", "000000000000000000000001") },
+		Idempotency(testIdempotency),
+		func(c *gin.Context) {
+			calls.Add(1)
+			handler(c)
+		})
+	return r, &calls
+}
+
+func post(r *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/buy/1", strings.NewReader(body))
+	req.Header.Set(IdempotencyHeader, key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysCompletedResponse(t *testing.T) {
+	mongotest.Setup(t)
+	r, calls := idempotentRouter(func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"n": 1})
+	})
+
+	first := post(r, "k1", `{"a":1}`)
+	second := post(r, "k1", `{"a":1}`)
+	if first.Code != http.StatusOK || second.Code != http.StatusOK || second.Body.String() != first.Body.String() {
+		t.Fatalf("replay = %d %q, first = %d %q", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" || calls.Load() != 1 {
+		t.Fatalf("handler ran %d times, replay header %q", calls.Load(), second.Header().Get("Idempotent-Replayed"))
+	}
+	if w := post(r, "k1", `{"a":2}`); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("different body: status %d, want 422", w.Code)
+	}
+
+	var rec models.IdempotencyRecord
+	if err := config.DB.Collection("idempotency_keys").FindOne(context.Background(), bson.M{"key": "k1"}).Decode(&rec); err != nil {
+		t.Fatal(err)
+	}
+	if until := time.Until(rec.ExpiresAt); until < 50*time.Minute {
+		t.Fatalf("completed key expires in %v, want the 1h TTL", until)
+	}
+}
+
+func TestIdempotencyReleasesKeyOnPanic(t *testing.T) {
+	mongotest.Setup(t)
+	var fail atomic.Bool
+	fail.Store(true)
+	r, calls := idempotentRouter(func(c *gin.Context) {
+		if fail.Load() {
+			panic("boom")
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	if w := post(r, "k2", ""); w.Code != http.StatusInternalServerError {
+		t.Fatalf("panicking handler: status %d, want 500", w.Code)
+	}
+	n, err := config.DB.Collection("idempotency_keys").CountDocuments(context.Background(), bson.M{"key": "k2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatal("key still held after the handler panicked")
+	}
+
+	fail.Store(false)
+	if w := post(r, "k2", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("retry after panic: status %d, want 204", w.Code)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("handler ran %d times, want 2", calls.Load())
+	}
+}
+
+func TestIdempotencyReleasesKeyOnServerError(t *testing.T) {
+	mongotest.Setup(t)
+	r, calls := idempotentRouter(func(c *gin.Context) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "try again"})
+	})
+	post(r, "k3", "")
+	post(r, "k3", "")
+	if calls.Load() != 2 {
+		t.Fatalf("handler ran %d times, want every 5xx to be retryable", calls.Load())
+	}
+}
+
+func TestIdempotencyProcessingKeyExpiresAfterLease(t *testing.T) {
+	mongotest.Setup(t)
+	ctx := context.Background()
+	r, calls := idempotentRouter(func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	keys := config.DB.Collection("idempotency_keys")
+
+	// Bản ghi processing của một process đã chết, lease còn hạn.
+	sum := sha256.Sum256([]byte(http.MethodPost + "\n/buy/1\n"))
+	now := time.Now()
+	stuck := models.IdempotencyRecord{
+		ID:          primitive.NewObjectID(),
+		UserID:      "000000000000000000000001",
+		Key:         "k4",
+		RequestHash: hex.EncodeToString(sum[:]),
+		State:       idempotencyProcessing,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(testIdempotency.Lease),
+	}
+	if _, err := keys.InsertOne(ctx, stuck); err != nil {
+		t.Fatal(err)
+	}
+	if w := post(r, "k4", ""); w.Code != http.StatusConflict {
+		t.Fatalf("key within lease: status %d, want 409", w.Code)
+	}
+
+	// Hết lease: request sau được nhận key và chạy handler.
+	if _, err := keys.UpdateByID(ctx, stuck.ID, bson.M{"$set": bson.M{"expires_at": now.Add(-time.Second)}}); err != nil {
+		t.Fatal(err)
+	}
+	if w := post(r, "k4", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("key after lease: status %d, want 204", w.Code)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls.Load())
+	}
+}
+
+func TestIdempotencyRenewsLeaseWhileHandlerRuns(t *testing.T) {
+	mongotest.Setup(t)
+	gin.SetMode(gin.TestMode)
+	short := config.IdempotencyConfig{TTL: time.Hour, Lease: 150 * time.Millisecond}
+	release := make(chan struct{})
+	var calls atomic.Int64
+	r := gin.New()
+	r.POST("/buy/:id",
+		func(c *gin.Context) { c.Set("user_id", "000000000000000000000001") },
+		Idempotency(short),
+		func(c *gin.Context) {
+			calls.Add(1)
+			<-release
+			c.Status(http.StatusNoContent)
+		})
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- post(r, "k5", "") }()
+
+	// Handler đầu chạy lâu hơn nhiều lần lease: retry vẫn phải nhận 409.
+	time.Sleep(4 * short.Lease)
+	if w := post(r, "k5", ""); w.Code != http.StatusConflict {
+		t.Fatalf("retry while the first request runs: status %d, want 409", w.Code)
+	}
+	close(release)
+	if w := <-first; w.Code != http.StatusNoContent {
+		t.Fatalf("first request: status %d, want 204", w.Code)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls.Load())
+	}
+
+	var rec models.IdempotencyRecord
+	if err := config.DB.Collection("idempotency_keys").FindOne(context.Background(), bson.M{"key": "k5"}).Decode(&rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.State != idempotencyCompleted || time.Until(rec.ExpiresAt) < 50*time.Minute {
+		t.Fatalf("record after completion = %+v, want completed with the 1h TTL", rec)
+	}
+}
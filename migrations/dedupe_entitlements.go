@@ -0,0 +1,228 @@
+// Package migrations chứa các bước chuyển đổi dữ liệu chạy thủ công qua
+// lệnh "server migrate <tên>".
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/ledger"
+	"go-mvc-demo/models"
+	"go-mvc-demo/rentalplan"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DuplicateGroup là một cặp (user, game) có nhiều bản ghi trùng.
+type DuplicateGroup struct {
+	UserID  primitive.ObjectID   `json:"user_id"`
+	GameID  primitive.ObjectID   `json:"game_id"`
+	Kept    primitive.ObjectID   `json:"kept"`
+	Removed []primitive.ObjectID `json:"removed"`
+	Refund  int                  `json:"refund"`
+}
+
+// DedupeReport tóm tắt kết quả của DedupeEntitlements.
+type DedupeReport struct {
+	Applied             bool             `json:"applied"`
+	BackfilledPurchases int64            `json:"backfilled_purchases"`
+	ExpiredRentals      int64            `json:"expired_rentals"`
+	Purchases           []DuplicateGroup `json:"purchases"`
+	Rentals             []DuplicateGroup `json:"rentals"`
+}
+
+// DedupeEntitlements dọn dữ liệu cũ để tạo được các unique index trên
+// purchases và rentals:
+//   - purchase chưa có status được gán completed;
+//   - rental active đã quá hạn được chuyển sang expired;
+//   - purchase trùng (user, game): giữ bản mua sớm nhất, các bản còn lại được
+//     hoàn tiền đầy đủ và đánh dấu refunded;
+//   - rental active trùng: giữ rental hết hạn muộn nhất, các rental còn lại
+//     được hoàn tiền theo thời gian còn lại và đánh dấu refunded.
+//
+// Khi apply=false chỉ báo cáo, không ghi gì vào DB.
+func DedupeEntitlements(ctx context.Context, apply bool) (DedupeReport, error) {
+	report := DedupeReport{Applied: apply}
+	now := time.Now()
+
+	purchases := config.DB.Collection("purchases")
+	rentals := config.DB.Collection("rentals")
+
+	legacyFilter := bson.M{"status": bson.M{"$exists": false}}
+	staleFilter := bson.M{"status": models.RentalActive, "expire_at": bson.M{"$lte": now}}
+	if apply {
+		res, err := purchases.UpdateMany(ctx, legacyFilter, bson.M{"$set": bson.M{"status": models.PurchaseCompleted}})
+		if err != nil {
+			return report, err
+		}
+		report.BackfilledPurchases = res.ModifiedCount
+
+		res, err = rentals.UpdateMany(ctx, staleFilter, bson.M{"$set": bson.M{"status": models.RentalExpired, "expired_at": now}})
+		if err != nil {
+			return report, err
+		}
+		report.ExpiredRentals = res.ModifiedCount
+	} else {
+		var err error
+		if report.BackfilledPurchases, err = purchases.CountDocuments(ctx, legacyFilter); err != nil {
+			return report, err
+		}
+		if report.ExpiredRentals, err = rentals.CountDocuments(ctx, staleFilter); err != nil {
+			return report, err
+		}
+	}
+
+	purchaseGroups, err := duplicateGroups(ctx, purchases,
+		bson.M{"status": bson.M{"$in": bson.A{models.PurchaseCompleted, nil}}},
+		bson.D{{Key: "purchase_at", Value: 1}})
+	if err != nil {
+		return report, err
+	}
+	for _, ids := range purchaseGroups {
+		group, err := dedupePurchases(ctx, ids, apply)
+		if err != nil {
+			return report, err
+		}
+		report.Purchases = append(report.Purchases, group)
+	}
+
+	rentalGroups, err := duplicateGroups(ctx, rentals,
+		bson.M{"status": models.RentalActive, "expire_at": bson.M{"$gt": now}},
+		bson.D{{Key: "expire_at", Value: -1}})
+	if err != nil {
+		return report, err
+	}
+	for _, ids := range rentalGroups {
+		group, err := dedupeRentals(ctx, ids, now, apply)
+		if err != nil {
+			return report, err
+		}
+		report.Rentals = append(report.Rentals, group)
+	}
+
+	return report, nil
+}
+
+// duplicateGroups trả về ID các bản ghi trùng (user_id, game_id), mỗi nhóm
+// sắp xếp theo sort; phần tử đầu tiên là bản được giữ lại.
+func duplicateGroups(ctx context.Context, coll *mongo.Collection, match bson.M, sort bson.D) ([][]primitive.ObjectID, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$sort", Value: sort}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"user_id": "$user_id", "game_id": "$game_id"},
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	}
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		IDs []primitive.ObjectID `bson:"ids"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	groups := make([][]primitive.ObjectID, 0, len(rows))
+	for _, r := range rows {
+		groups = append(groups, r.IDs)
+	}
+	return groups, nil
+}
+
+func dedupePurchases(ctx context.Context, ids []primitive.ObjectID, apply bool) (DuplicateGroup, error) {
+	var group DuplicateGroup
+	err := config.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		group = DuplicateGroup{Kept: ids[0], Removed: ids[1:]}
+		now := time.Now()
+		for i, id := range ids {
+			var p models.Purchase
+			if err := config.DB.Collection("purchases").FindOne(sessCtx, bson.M{"_id": id}).Decode(&p); err != nil {
+				return err
+			}
+			group.UserID, group.GameID = p.UserID, p.GameID
+			if i == 0 {
+				continue
+			}
+			group.Refund += p.Price
+			if !apply {
+				continue
+			}
+			_, err := config.DB.Collection("purchases").UpdateByID(sessCtx, id, bson.M{"$set": bson.M{
+				"status":        models.PurchaseRefunded,
+				"refunded_at":   now,
+				"refund_amount": p.Price,
+				"refund_reason": "duplicate purchase",
+			}})
+			if err != nil {
+				return err
+			}
+			_, err = ledger.Credit(sessCtx, ledger.Movement{
+				UserID:  p.UserID,
+				Amount:  p.Price,
+				Type:    ledger.TypeRefund,
+				RefType: "purchase",
+				RefID:   p.ID,
+				Note:    "duplicate purchase",
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return group, err
+}
+
+func dedupeRentals(ctx context.Context, ids []primitive.ObjectID, now time.Time, apply bool) (DuplicateGroup, error) {
+	var group DuplicateGroup
+	err := config.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		group = DuplicateGroup{Kept: ids[0], Removed: ids[1:]}
+		for i, id := range ids {
+			var r models.Rental
+			if err := config.DB.Collection("rentals").FindOne(sessCtx, bson.M{"_id": id}).Decode(&r); err != nil {
+				return err
+			}
+			group.UserID, group.GameID = r.UserID, r.GameID
+			if i == 0 {
+				continue
+			}
+			paid := r.TotalPaid
+			if paid == 0 {
+				paid = r.Price
+			}
+			amount := rentalplan.ProratedRefund(paid, r.RentAt, r.ExpireAt, now)
+			group.Refund += amount
+			if !apply {
+				continue
+			}
+			_, err := config.DB.Collection("rentals").UpdateByID(sessCtx, id, bson.M{"$set": bson.M{
+				"status":        models.RentalRefunded,
+				"refunded_at":   now,
+				"refund_amount": amount,
+			}})
+			if err != nil {
+				return err
+			}
+			_, err = ledger.Credit(sessCtx, ledger.Movement{
+				UserID:  r.UserID,
+				Amount:  amount,
+				Type:    ledger.TypeRefund,
+				RefType: "rental",
+				RefID:   r.ID,
+				Note:    "duplicate rental",
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return group, err
+}
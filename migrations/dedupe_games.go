@@ -0,0 +1,272 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/ledger"
+	"go-mvc-demo/models"
+	"go-mvc-demo/rentalplan"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GameGroup là các game cùng rawg_id được gộp vào game Kept.
+type GameGroup struct {
+	RawgID            int                  `json:"rawg_id"`
+	Kept              primitive.ObjectID   `json:"kept"`
+	Removed           []primitive.ObjectID `json:"removed"`
+	RefundedPurchases int                  `json:"refunded_purchases"`
+	RefundedRentals   int                  `json:"refunded_rentals"`
+	ExpiredRentals    int                  `json:"expired_rentals"`
+	DroppedPlans      int                  `json:"dropped_plans"`
+	Refund            int                  `json:"refund"`
+}
+
+// DedupeGamesReport tóm tắt kết quả của DedupeGames.
+type DedupeGamesReport struct {
+	Applied bool        `json:"applied"`
+	Games   []GameGroup `json:"games"`
+}
+
+// DedupeGames gộp các game trùng rawg_id để tạo được index uniq_rawg_id.
+// Mỗi nhóm giữ game được tạo sớm nhất và chuyển mọi tham chiếu (purchases,
+// rentals, rental_plans, price_history, game_changes) sang game đó:
+//   - user sở hữu nhiều bản của cùng game: giữ purchase sớm nhất, các bản
+//     còn lại được hoàn tiền đầy đủ;
+//   - user đang thuê nhiều bản: giữ rental hết hạn muộn nhất, rental còn hạn
+//     được hoàn tiền theo thời gian còn lại, rental đã quá hạn được đóng;
+//   - gói thuê theo game trùng code với gói của game giữ lại bị xoá.
+//
+// Khi apply=false chỉ báo cáo, không ghi gì vào DB.
+func DedupeGames(ctx context.Context, apply bool) (DedupeGamesReport, error) {
+	report := DedupeGamesReport{Applied: apply}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"rawg_id": bson.M{"$gt": 0}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$rawg_id",
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+	cursor, err := config.DB.Collection("games").Aggregate(ctx, pipeline)
+	if err != nil {
+		return report, err
+	}
+	var rows []struct {
+		RawgID int                  `bson:"_id"`
+		IDs    []primitive.ObjectID `bson:"ids"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return report, err
+	}
+
+	for _, row := range rows {
+		group, err := mergeGames(ctx, row.RawgID, row.IDs, apply)
+		if err != nil {
+			return report, err
+		}
+		report.Games = append(report.Games, group)
+	}
+	return report, nil
+}
+
+// mergeGames gộp ids[1:] vào ids[0] trong một transaction.
+func mergeGames(ctx context.Context, rawgID int, ids []primitive.ObjectID, apply bool) (GameGroup, error) {
+	var group GameGroup
+	err := config.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		group = GameGroup{RawgID: rawgID, Kept: ids[0], Removed: ids[1:]}
+		now := time.Now()
+		if err := mergePurchases(sessCtx, &group, ids, now, apply); err != nil {
+			return err
+		}
+		if err := mergeRentals(sessCtx, &group, ids, now, apply); err != nil {
+			return err
+		}
+		if err := mergePlans(sessCtx, &group, apply); err != nil {
+			return err
+		}
+		if !apply {
+			return nil
+		}
+
+		// Các bản ghi trùng đã được đóng ở trên nên chuyển game_id không còn
+		// vi phạm unique index của purchases/rentals.
+		repoint := bson.M{"$set": bson.M{"game_id": group.Kept}}
+		removed := bson.M{"game_id": bson.M{"$in": group.Removed}}
+		for _, name := range []string{"purchases", "rentals", "price_history", "game_changes"} {
+			if _, err := config.DB.Collection(name).UpdateMany(sessCtx, removed, repoint); err != nil {
+				return err
+			}
+		}
+		_, err := config.DB.Collection("games").DeleteMany(sessCtx, bson.M{"_id": bson.M{"$in": group.Removed}})
+		return err
+	})
+	return group, err
+}
+
+func mergePurchases(sessCtx mongo.SessionContext, group *GameGroup, ids []primitive.ObjectID, now time.Time, apply bool) error {
+	purchases := config.DB.Collection("purchases")
+	cursor, err := purchases.Find(sessCtx,
+		bson.M{"game_id": bson.M{"$in": ids}, "status": bson.M{"$in": bson.A{models.PurchaseCompleted, nil}}},
+		options.Find().SetSort(bson.D{{Key: "purchase_at", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	var list []models.Purchase
+	if err := cursor.All(sessCtx, &list); err != nil {
+		return err
+	}
+
+	owned := map[primitive.ObjectID]bool{}
+	for _, p := range list {
+		if !owned[p.UserID] {
+			owned[p.UserID] = true
+			continue
+		}
+		group.RefundedPurchases++
+		group.Refund += p.Price
+		if !apply {
+			continue
+		}
+		_, err := purchases.UpdateByID(sessCtx, p.ID, bson.M{"$set": bson.M{
+			"status":        models.PurchaseRefunded,
+			"refunded_at":   now,
+			"refund_amount": p.Price,
+			"refund_reason": "duplicate game",
+		}})
+		if err != nil {
+			return err
+		}
+		_, err = ledger.Credit(sessCtx, ledger.Movement{
+			UserID:  p.UserID,
+			Amount:  p.Price,
+			Type:    ledger.TypeRefund,
+			RefType: "purchase",
+			RefID:   p.ID,
+			Note:    "duplicate game",
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mergeRentals(sessCtx mongo.SessionContext, group *GameGroup, ids []primitive.ObjectID, now time.Time, apply bool) error {
+	rentals := config.DB.Collection("rentals")
+	cursor, err := rentals.Find(sessCtx,
+		bson.M{"game_id": bson.M{"$in": ids}, "status": models.RentalActive},
+		options.Find().SetSort(bson.D{{Key: "expire_at", Value: -1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	var list []models.Rental
+	if err := cursor.All(sessCtx, &list); err != nil {
+		return err
+	}
+
+	renting := map[primitive.ObjectID]bool{}
+	for _, r := range list {
+		if !renting[r.UserID] {
+			renting[r.UserID] = true
+			continue
+		}
+		if !r.ExpireAt.After(now) {
+			group.ExpiredRentals++
+			if !apply {
+				continue
+			}
+			if _, err := rentals.UpdateByID(sessCtx, r.ID, bson.M{"$set": bson.M{"status": models.RentalExpired, "expired_at": now}}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		paid := r.TotalPaid
+		if paid == 0 {
+			paid = r.Price
+		}
+		amount := rentalplan.ProratedRefund(paid, r.RentAt, r.ExpireAt, now)
+		group.RefundedRentals++
+		group.Refund += amount
+		if !apply {
+			continue
+		}
+		_, err := rentals.UpdateByID(sessCtx, r.ID, bson.M{"$set": bson.M{
+			"status":        models.RentalRefunded,
+			"refunded_at":   now,
+			"refund_amount": amount,
+		}})
+		if err != nil {
+			return err
+		}
+		_, err = ledger.Credit(sessCtx, ledger.Movement{
+			UserID:  r.UserID,
+			Amount:  amount,
+			Type:    ledger.TypeRefund,
+			RefType: "rental",
+			RefID:   r.ID,
+			Note:    "duplicate game",
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergePlans chuyển gói thuê theo game sang game giữ lại. Gói của game giữ
+// lại được ưu tiên, sau đó tới gói cập nhật gần nhất.
+func mergePlans(sessCtx mongo.SessionContext, group *GameGroup, apply bool) error {
+	plans := config.DB.Collection("rental_plans")
+	cursor, err := plans.Find(sessCtx, bson.M{"scope": models.PlanScopeGame, "game_id": group.Kept})
+	if err != nil {
+		return err
+	}
+	var kept []models.RentalPlan
+	if err := cursor.All(sessCtx, &kept); err != nil {
+		return err
+	}
+	codes := map[string]bool{}
+	for _, p := range kept {
+		codes[p.Code] = true
+	}
+
+	cursor, err = plans.Find(sessCtx,
+		bson.M{"scope": models.PlanScopeGame, "game_id": bson.M{"$in": group.Removed}},
+		options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	var moved []models.RentalPlan
+	if err := cursor.All(sessCtx, &moved); err != nil {
+		return err
+	}
+	for _, p := range moved {
+		if codes[p.Code] {
+			group.DroppedPlans++
+			if apply {
+				if _, err := plans.DeleteOne(sessCtx, bson.M{"_id": p.ID}); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		codes[p.Code] = true
+		if apply {
+			if _, err := plans.UpdateByID(sessCtx, p.ID, bson.M{"$set": bson.M{"game_id": group.Kept}}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
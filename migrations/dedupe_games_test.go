@@ -0,0 +1,136 @@
+package migrations_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/config/mongotest"
+	"go-mvc-demo/ledger"
+	"go-mvc-demo/migrations"
+	"go-mvc-demo/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDedupeGamesMergesReferences(t *testing.T) {
+	mongotest.Setup(t)
+	ctx := context.Background()
+	games := config.DB.Collection("games")
+
+	// Dữ liệu cũ được tạo trước khi có uniq_rawg_id.
+	if _, err := games.Indexes().DropOne(ctx, "uniq_rawg_id"); err != nil {
+		t.Fatal(err)
+	}
+	older, newer := time.Now().Add(-48*time.Hour), time.Now()
+	kept, dup := primitive.NewObjectID(), primitive.NewObjectID()
+	manual := primitive.NewObjectID()
+	for _, g := range []models.Game{
+		{ID: dup, RawgID: 3498, Name: "GTA V", Price: 500, CreatedAt: &newer},
+		{ID: kept, RawgID: 3498, Name: "GTA V", Price: 500, CreatedAt: &older},
+		{ID: manual, Name: "Manual game", Price: 100},
+		{ID: primitive.NewObjectID(), Name: "Another manual game", Price: 100},
+	} {
+		if _, err := games.InsertOne(ctx, g); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// owner mua cả hai bản, other chỉ mua bản trùng; renter thuê cả hai bản.
+	owner, other, renter := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	for _, id := range []primitive.ObjectID{owner, other, renter} {
+		if _, err := config.DB.Collection("users").InsertOne(ctx, models.User{ID: id, Email: id.Hex() + "@example.com", Role: "user"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	purchases := []interface{}{
+		models.Purchase{ID: primitive.NewObjectID(), UserID: owner, GameID: kept, PurchaseAt: now.Add(-time.Hour), Price: 500, Status: models.PurchaseCompleted},
+		models.Purchase{ID: primitive.NewObjectID(), UserID: owner, GameID: dup, PurchaseAt: now, Price: 450, Status: models.PurchaseCompleted},
+		models.Purchase{ID: primitive.NewObjectID(), UserID: other, GameID: dup, PurchaseAt: now, Price: 500, Status: models.PurchaseCompleted},
+	}
+	if _, err := config.DB.Collection("purchases").InsertMany(ctx, purchases); err != nil {
+		t.Fatal(err)
+	}
+	rentals := []interface{}{
+		models.Rental{ID: primitive.NewObjectID(), UserID: renter, GameID: kept, RentAt: now.Add(-time.Hour), ExpireAt: now.Add(24 * time.Hour), Status: models.RentalActive, Price: 50, TotalPaid: 50},
+		models.Rental{ID: primitive.NewObjectID(), UserID: renter, GameID: dup, RentAt: now.Add(-time.Hour), ExpireAt: now.Add(72 * time.Hour), Status: models.RentalActive, Price: 100, TotalPaid: 100},
+	}
+	if _, err := config.DB.Collection("rentals").InsertMany(ctx, rentals); err != nil {
+		t.Fatal(err)
+	}
+	plans := []interface{}{
+		models.RentalPlan{Code: "3d", Scope: models.PlanScopeGame, GameID: &kept, DurationDays: 3, PricePercent: 10, Active: true},
+		models.RentalPlan{Code: "3d", Scope: models.PlanScopeGame, GameID: &dup, DurationDays: 3, PricePercent: 20, Active: true},
+		models.RentalPlan{Code: "7d", Scope: models.PlanScopeGame, GameID: &dup, DurationDays: 7, PricePercent: 30, Active: true},
+	}
+	if _, err := config.DB.Collection("rental_plans").InsertMany(ctx, plans); err != nil {
+		t.Fatal(err)
+	}
+
+	dry, err := migrations.DedupeGames(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dry.Games) != 1 || dry.Games[0].Kept != kept || len(dry.Games[0].Removed) != 1 || dry.Games[0].Removed[0] != dup {
+		t.Fatalf("dry run = %+v", dry)
+	}
+	if n, _ := games.CountDocuments(ctx, bson.M{}); n != 4 {
+		t.Fatalf("dry run deleted games: %d left", n)
+	}
+
+	report, err := migrations.DedupeGames(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := report.Games[0]
+	if g.RefundedPurchases != 1 || g.RefundedRentals != 1 || g.DroppedPlans != 1 {
+		t.Fatalf("report = %+v", g)
+	}
+	if n, _ := games.CountDocuments(ctx, bson.M{"_id": dup}); n != 0 {
+		t.Fatal("duplicate game was not deleted")
+	}
+	if n, _ := games.CountDocuments(ctx, bson.M{"_id": manual}); n != 1 {
+		t.Fatal("manual game was deleted")
+	}
+	for _, name := range []string{"purchases", "rentals", "rental_plans"} {
+		if n, _ := config.DB.Collection(name).CountDocuments(ctx, bson.M{"game_id": dup}); n != 0 {
+			t.Fatalf("%d %s still point at the removed game", n, name)
+		}
+	}
+	if n, _ := config.DB.Collection("purchases").CountDocuments(ctx, bson.M{"game_id": kept, "status": models.PurchaseCompleted}); n != 2 {
+		t.Fatalf("%d completed purchases on kept game, want 2", n)
+	}
+	var refunded models.Purchase
+	if err := config.DB.Collection("purchases").FindOne(ctx, bson.M{"user_id": owner, "status": models.PurchaseRefunded}).Decode(&refunded); err != nil {
+		t.Fatal(err)
+	}
+	if refunded.Price != 450 || refunded.RefundAmount != 450 {
+		t.Fatalf("refunded purchase = %+v, want the later 450 coin purchase", refunded)
+	}
+	var active models.Rental
+	if err := config.DB.Collection("rentals").FindOne(ctx, bson.M{"user_id": renter, "status": models.RentalActive}).Decode(&active); err != nil {
+		t.Fatal(err)
+	}
+	if active.TotalPaid != 100 {
+		t.Fatalf("kept rental = %+v, want the one expiring last", active)
+	}
+	for _, id := range []primitive.ObjectID{owner, renter} {
+		rec, err := ledger.Reconcile(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rec.Reconciled || rec.StoredBalance <= 0 {
+			t.Fatalf("user %s after refund: %+v", id.Hex(), rec)
+		}
+	}
+
+	if err := config.EnsureIndexes(); err != nil {
+		t.Fatalf("EnsureIndexes after dedupe: %v", err)
+	}
+	if _, err := games.InsertOne(ctx, models.Game{RawgID: 3498, Name: "GTA V again"}); err == nil {
+		t.Fatal("uniq_rawg_id was not recreated")
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKey là khoá truy cập dài hạn cho script/server gọi API thay cho JWT.
+// Chỉ lưu hash; Prefix là vài ký tự đầu của key để user nhận ra key nào.
+type APIKey struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID       primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Name         string             `bson:"name" json:"name"`
+	Prefix       string             `bson:"prefix" json:"prefix"`
+	KeyHash      string             `bson:"key_hash" json:"-"`
+	Scopes       []string           `bson:"scopes" json:"scopes"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt    *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	LastUsedAt   *time.Time         `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	LastUsedIP   string             `bson:"last_used_ip,omitempty" json:"last_used_ip,omitempty"`
+	RevokedAt    *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	RevokeReason string             `bson:"revoke_reason,omitempty" json:"revoke_reason,omitempty"` // trống nếu user tự thu hồi
+}
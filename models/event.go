@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Event là một sự kiện nghiệp vụ đã xảy ra (ví dụ rental.expired).
+type Event struct {
+	ID        primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	Type      string                 `bson:"type" json:"type"`
+	Payload   map[string]interface{} `bson:"payload" json:"payload"`
+	CreatedAt time.Time              `bson:"created_at" json:"created_at"`
+}
@@ -1,6 +1,10 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type Game struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
@@ -12,4 +16,58 @@ type Game struct {
 	Platforms   []string           `bson:"platforms" json:"platforms"`
 	Rating      float64            `bson:"rating" json:"rating"`
 	Price       int                `bson:"price" json:"price"`
+
+	// Các field dưới đây được điền bởi bước enrichment từ /games/{id} của
+	// RAWG. Description là plain text, DescriptionHTML là HTML đã làm sạch.
+	DescriptionHTML string       `bson:"description_html,omitempty" json:"description_html,omitempty"`
+	Released        *time.Time   `bson:"released,omitempty" json:"released,omitempty"`
+	Developers      []string     `bson:"developers,omitempty" json:"developers,omitempty"`
+	Publishers      []string     `bson:"publishers,omitempty" json:"publishers,omitempty"`
+	ESRBRating      string       `bson:"esrb_rating,omitempty" json:"esrb_rating,omitempty"`
+	Metacritic      int          `bson:"metacritic,omitempty" json:"metacritic,omitempty"`
+	Website         string       `bson:"website,omitempty" json:"website,omitempty"`
+	Screenshots     []Screenshot `bson:"screenshots,omitempty" json:"screenshots,omitempty"`
+	Trailers        []Trailer    `bson:"trailers,omitempty" json:"trailers,omitempty"`
+	EnrichedAt      *time.Time   `bson:"enriched_at,omitempty" json:"enriched_at,omitempty"`
+	EnrichError     string       `bson:"enrich_error,omitempty" json:"-"`
+	EnrichFailedAt  *time.Time   `bson:"enrich_failed_at,omitempty" json:"-"`
+
+	CreatedAt *time.Time `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt *time.Time `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	// SourceUpdatedAt là thời điểm RAWG cập nhật game lần cuối (field "updated").
+	SourceUpdatedAt *time.Time `bson:"source_updated_at,omitempty" json:"source_updated_at,omitempty"`
+}
+
+// Screenshot là ảnh chụp màn hình của game.
+type Screenshot struct {
+	URL    string `bson:"url" json:"url"`
+	Width  int    `bson:"width,omitempty" json:"width,omitempty"`
+	Height int    `bson:"height,omitempty" json:"height,omitempty"`
+}
+
+// Trailer là video giới thiệu game.
+type Trailer struct {
+	Name    string `bson:"name" json:"name"`
+	Preview string `bson:"preview,omitempty" json:"preview,omitempty"`
+	URL480  string `bson:"url_480,omitempty" json:"url_480,omitempty"`
+	URLMax  string `bson:"url_max,omitempty" json:"url_max,omitempty"`
+}
+
+// GameChange là một game thay đổi khi đồng bộ catalog: Fields chứa giá trị
+// cũ/mới của các field đã đổi. Game mới thêm có Created = true và Fields rỗng.
+type GameChange struct {
+	ID        primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	JobID     primitive.ObjectID     `bson:"job_id" json:"job_id"`
+	GameID    primitive.ObjectID     `bson:"game_id" json:"game_id"`
+	RawgID    int                    `bson:"rawg_id" json:"rawg_id"`
+	Name      string                 `bson:"name" json:"name"`
+	Created   bool                   `bson:"created,omitempty" json:"created,omitempty"`
+	Fields    map[string]FieldChange `bson:"fields,omitempty" json:"fields,omitempty"`
+	CreatedAt time.Time              `bson:"created_at" json:"created_at"`
+}
+
+// FieldChange là giá trị trước và sau của một field.
+type FieldChange struct {
+	From interface{} `bson:"from" json:"from"`
+	To   interface{} `bson:"to" json:"to"`
 }
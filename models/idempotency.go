@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IdempotencyRecord lưu kết quả của một request có header Idempotency-Key.
+// Khi còn processing, ExpiresAt là hạn lease của request đang xử lý; khi đã
+// completed, ExpiresAt là hạn giữ response.
+type IdempotencyRecord struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID       string             `bson:"user_id" json:"user_id"`
+	Key          string             `bson:"key" json:"key"`
+	RequestHash  string             `bson:"request_hash" json:"request_hash"`
+	State        string             `bson:"state" json:"state"` // processing, completed
+	StatusCode   int                `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	ContentType  string             `bson:"content_type,omitempty" json:"content_type,omitempty"`
+	ResponseBody []byte             `bson:"response_body,omitempty" json:"-"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt    time.Time          `bson:"expires_at" json:"expires_at"`
+}
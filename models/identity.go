@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserIdentity liên kết một tài khoản ở identity provider bên ngoài (OIDC)
+// với user. Cặp (provider, subject) là duy nhất.
+type UserIdentity struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Provider    string             `bson:"provider" json:"provider"`
+	Subject     string             `bson:"subject" json:"subject"`
+	Email       string             `bson:"email" json:"email"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	LastLoginAt time.Time          `bson:"last_login_at" json:"last_login_at"`
+}
+
+// OIDCState lưu state/nonce/PKCE verifier giữa bước chuyển hướng tới
+// provider và callback. _id là hash của state; bản ghi bị xoá khi dùng.
+type OIDCState struct {
+	StateHash    string    `bson:"_id"`
+	Provider     string    `bson:"provider"`
+	Nonce        string    `bson:"nonce"`
+	CodeVerifier string    `bson:"code_verifier"`
+	ExpiresAt    time.Time `bson:"expires_at"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Trạng thái của một import job.
+const (
+	ImportQueued    = "queued"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+	ImportCancelled = "cancelled"
+)
+
+// ImportJob là một lần import game từ RAWG chạy nền. Tiến độ được lưu sau
+// mỗi trang nên job có thể chạy tiếp từ NextPage sau khi bị huỷ, lỗi hoặc
+// server khởi động lại.
+type ImportJob struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Source    string             `bson:"source" json:"source"`
+	Status    string             `bson:"status" json:"status"`
+	StartPage int                `bson:"start_page" json:"start_page"`
+	EndPage   int                `bson:"end_page" json:"end_page"`
+	PageSize  int                `bson:"page_size" json:"page_size"`
+	// NextPage là trang đầu tiên chưa import xong.
+	NextPage  int `bson:"next_page" json:"next_page"`
+	PagesDone int `bson:"pages_done" json:"pages_done"`
+	Inserted  int `bson:"inserted" json:"inserted"`
+	Updated   int `bson:"updated" json:"updated"`
+	Skipped   int `bson:"skipped" json:"skipped"`
+	// Unchanged là số game đã có và không đổi so với RAWG.
+	Unchanged int `bson:"unchanged" json:"unchanged"`
+	// ChangedFields đếm số game bị đổi theo từng field; chi tiết ở game_changes.
+	ChangedFields map[string]int `bson:"changed_fields,omitempty" json:"changed_fields,omitempty"`
+
+	LastError   string     `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	LastErrorAt *time.Time `bson:"last_error_at,omitempty" json:"last_error_at,omitempty"`
+
+	CreatedBy  primitive.ObjectID `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	StartedAt  *time.Time         `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+	FinishedAt *time.Time         `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+
+	// Active là true khi job queued/running. Unique index trên field này bảo
+	// đảm mỗi lúc chỉ có một job đang hoạt động.
+	Active bool `bson:"active,omitempty" json:"-"`
+
+	// Owner/LeaseUntil: replica đang chạy job. Lease hết hạn (replica chết)
+	// thì replica khác nhận job và chạy tiếp.
+	Owner      string     `bson:"owner,omitempty" json:"-"`
+	LeaseUntil *time.Time `bson:"lease_until,omitempty" json:"-"`
+}
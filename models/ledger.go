@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LedgerEntry là một dòng bút toán kép. Mỗi lần coin thay đổi sẽ sinh ra
+// hai entry cùng TransactionID: một debit và một credit có cùng Amount.
+type LedgerEntry struct {
+	ID            primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	TransactionID primitive.ObjectID  `bson:"transaction_id" json:"transaction_id"`
+	Account       string              `bson:"account" json:"account"` // user:<id>, system:revenue, ...
+	UserID        *primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Direction     string              `bson:"direction" json:"direction"` // debit, credit
+	Amount        int                 `bson:"amount" json:"amount"`
+	Type          string              `bson:"type" json:"type"` // recharge, purchase, rental, refund, adjustment, bonus
+	RefType       string              `bson:"ref_type,omitempty" json:"ref_type,omitempty"`
+	RefID         *primitive.ObjectID `bson:"ref_id,omitempty" json:"ref_id,omitempty"`
+	Note          string              `bson:"note,omitempty" json:"note,omitempty"`
+	BalanceAfter  *int                `bson:"balance_after,omitempty" json:"balance_after,omitempty"`
+	CreatedAt     time.Time           `bson:"created_at" json:"created_at"`
+}
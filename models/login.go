@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginAttempt đếm số lần đăng nhập sai theo một khoá ("account:<email>"
+// hoặc "ip:<địa chỉ>"). Bản ghi tự xoá 24 giờ sau lần sai cuối cùng.
+type LoginAttempt struct {
+	Key           string     `bson:"_id" json:"key"`
+	Failures      int        `bson:"failures" json:"failures"`
+	LastFailureAt time.Time  `bson:"last_failure_at" json:"last_failure_at"`
+	LockedUntil   *time.Time `bson:"locked_until,omitempty" json:"locked_until,omitempty"`
+	ExpiresAt     time.Time  `bson:"expires_at" json:"expires_at"`
+}
+
+// Kết quả của một lần đăng nhập trong login history.
+const (
+	LoginSucceeded          = "succeeded"
+	LoginMFARequired        = "mfa_required"
+	LoginInvalidCredentials = "invalid_credentials"
+	LoginInvalidMFACode     = "invalid_mfa_code"
+	LoginLocked             = "locked"
+)
+
+// LoginHistory là một lần thử đăng nhập. UserID rỗng khi email không tồn tại.
+type LoginHistory struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID    *primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Email     string              `bson:"email" json:"email"`
+	IP        string              `bson:"ip" json:"ip"`
+	UserAgent string              `bson:"user_agent" json:"user_agent"`
+	Result    string              `bson:"result" json:"result"`
+	// Method là "password", "mfa" hoặc "oidc:<provider>"; bản ghi cũ để trống.
+	Method    string    `bson:"method,omitempty" json:"method,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
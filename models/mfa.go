@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserMFA là cấu hình TOTP của một user. Secret được mã hoá; recovery code
+// chỉ lưu hash và bị xoá khỏi danh sách sau khi dùng.
+type UserMFA struct {
+	UserID        primitive.ObjectID `bson:"_id" json:"user_id"`
+	Secret        string             `bson:"secret" json:"-"`
+	ConfirmedAt   *time.Time         `bson:"confirmed_at,omitempty" json:"confirmed_at,omitempty"`
+	RecoveryCodes []string           `bson:"recovery_codes" json:"-"`
+	LastUsedStep  int64              `bson:"last_used_step" json:"-"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// MFAChallenge là bước đăng nhập thứ hai đang chờ mã TOTP sau khi user đã
+// nhập đúng mật khẩu.
+type MFAChallenge struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TokenHash string             `bson:"token_hash" json:"-"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Attempts  int                `bson:"attempts" json:"attempts"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
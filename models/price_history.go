@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PriceHistory ghi lại một lần đổi giá game.
+type PriceHistory struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	GameID   primitive.ObjectID `bson:"game_id" json:"game_id"`
+	OldPrice int                `bson:"old_price" json:"old_price"`
+	NewPrice int                `bson:"new_price" json:"new_price"`
+	// Source là nguồn đổi giá, ví dụ "pricing" khi áp dụng bảng giá.
+	Source string `bson:"source" json:"source"`
+	// BatchID gom các thay đổi của cùng một lần áp dụng bảng giá.
+	BatchID   primitive.ObjectID `bson:"batch_id,omitempty" json:"batch_id,omitempty"`
+	ChangedBy primitive.ObjectID `bson:"changed_by,omitempty" json:"changed_by,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
@@ -6,10 +6,22 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Trạng thái của purchase. Bản ghi cũ không có status được coi là completed.
+const (
+	PurchaseCompleted = "completed"
+	PurchaseRefunded  = "refunded"
+)
+
 type Purchase struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`
-	GameID     primitive.ObjectID `bson:"game_id" json:"game_id"`
-	PurchaseAt time.Time          `bson:"purchase_at" json:"purchase_at"`
-	Price      int                `bson:"price" json:"price"`
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID       primitive.ObjectID `bson:"user_id" json:"user_id"`
+	GameID       primitive.ObjectID `bson:"game_id" json:"game_id"`
+	PurchaseAt   time.Time          `bson:"purchase_at" json:"purchase_at"`
+	Price        int                `bson:"price" json:"price"` // số coin thực trả
+	ListPrice    int                `bson:"list_price,omitempty" json:"list_price,omitempty"`
+	RentalCredit int                `bson:"rental_credit,omitempty" json:"rental_credit,omitempty"`
+	Status       string             `bson:"status,omitempty" json:"status,omitempty"` // completed, refunded
+	RefundedAt   *time.Time         `bson:"refunded_at,omitempty" json:"refunded_at,omitempty"`
+	RefundAmount int                `bson:"refund_amount,omitempty" json:"refund_amount,omitempty"`
+	RefundReason string             `bson:"refund_reason,omitempty" json:"refund_reason,omitempty"`
 }
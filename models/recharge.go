@@ -6,10 +6,36 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Trạng thái của một lần nạp tiền.
+const (
+	RechargePending = "pending"
+	RechargeSuccess = "success"
+	RechargeFailed  = "failed"
+	RechargeExpired = "expired"
+)
+
 type Recharge struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
-	Amount    int                `bson:"amount" json:"amount"`
-	Status    string             `bson:"status" json:"status"` // pending, success, failed
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Amount      int                `bson:"amount" json:"amount"`
+	Status      string             `bson:"status" json:"status"` // pending, success, failed, expired
+	Provider    string             `bson:"provider,omitempty" json:"provider,omitempty"`
+	Reference   string             `bson:"reference,omitempty" json:"reference,omitempty"`
+	CheckoutURL string             `bson:"checkout_url,omitempty" json:"checkout_url,omitempty"`
+	ExpiresAt   *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	CompletedAt *time.Time         `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// PaymentEvent là một webhook đã được xử lý, dùng để bỏ qua bản gửi trùng.
+type PaymentEvent struct {
+	ID         string             `bson:"_id" json:"id"` // <provider>:<event id>
+	Provider   string             `bson:"provider" json:"provider"`
+	Reference  string             `bson:"reference" json:"reference"`
+	Status     string             `bson:"status" json:"status"`
+	Amount     int                `bson:"amount" json:"amount"`
+	RechargeID primitive.ObjectID `bson:"recharge_id" json:"recharge_id"`
+	Applied    bool               `bson:"applied" json:"applied"`
+	OccurredAt time.Time          `bson:"occurred_at" json:"occurred_at"`
+	ReceivedAt time.Time          `bson:"received_at" json:"received_at"`
 }
@@ -6,11 +6,37 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Trạng thái của rental.
+const (
+	RentalActive   = "active"
+	RentalExpired  = "expired"
+	RentalRefunded = "refunded"
+)
+
 type Rental struct {
-	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID   primitive.ObjectID `bson:"user_id" json:"user_id"`
-	GameID   primitive.ObjectID `bson:"game_id" json:"game_id"`
-	RentAt   time.Time          `bson:"rent_at" json:"rent_at"`
-	ExpireAt time.Time          `bson:"expire_at" json:"expire_at"`
-	Status   string             `bson:"status" json:"status"` // active, expired
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID       primitive.ObjectID `bson:"user_id" json:"user_id"`
+	GameID       primitive.ObjectID `bson:"game_id" json:"game_id"`
+	RentAt       time.Time          `bson:"rent_at" json:"rent_at"`
+	ExpireAt     time.Time          `bson:"expire_at" json:"expire_at"`
+	Status       string             `bson:"status" json:"status"` // active, expired, refunded
+	Plan         string             `bson:"plan,omitempty" json:"plan,omitempty"`
+	Price        int                `bson:"price" json:"price"`
+	TotalPaid    int                `bson:"total_paid" json:"total_paid"`
+	Extensions   []RentalExtension  `bson:"extensions,omitempty" json:"extensions,omitempty"`
+	ExpiredAt    *time.Time         `bson:"expired_at,omitempty" json:"expired_at,omitempty"`
+	RefundedAt   *time.Time         `bson:"refunded_at,omitempty" json:"refunded_at,omitempty"`
+	RefundAmount int                `bson:"refund_amount,omitempty" json:"refund_amount,omitempty"`
+
+	// CreditedPurchaseID là purchase đã dùng tiền thuê này làm khoản giảm giá.
+	CreditedPurchaseID *primitive.ObjectID `bson:"credited_purchase_id,omitempty" json:"credited_purchase_id,omitempty"`
+}
+
+// RentalExtension ghi lại một lần gia hạn rental.
+type RentalExtension struct {
+	Plan       string    `bson:"plan" json:"plan"`
+	Price      int       `bson:"price" json:"price"`
+	ExtendedAt time.Time `bson:"extended_at" json:"extended_at"`
+	FromExpire time.Time `bson:"from_expire" json:"from_expire"`
+	ToExpire   time.Time `bson:"to_expire" json:"to_expire"`
 }
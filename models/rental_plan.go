@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Phạm vi áp dụng của một gói thuê. Gói theo game ưu tiên hơn theo thể loại,
+// theo thể loại ưu tiên hơn gói toàn cục.
+const (
+	PlanScopeGlobal = "global"
+	PlanScopeGenre  = "genre"
+	PlanScopeGame   = "game"
+)
+
+// RentalPlan là một gói thuê (ví dụ 3 ngày) và quy tắc tính giá của nó.
+type RentalPlan struct {
+	ID           primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Code         string              `bson:"code" json:"code"` // 1d, 3d, 7d, 30d
+	Name         string              `bson:"name" json:"name"`
+	DurationDays int                 `bson:"duration_days" json:"duration_days"`
+	PricePercent int                 `bson:"price_percent" json:"price_percent"` // % giá mua của game
+	MinPrice     int                 `bson:"min_price" json:"min_price"`
+	Scope        string              `bson:"scope" json:"scope"` // global, genre, game
+	Genre        string              `bson:"genre,omitempty" json:"genre,omitempty"`
+	GameID       *primitive.ObjectID `bson:"game_id,omitempty" json:"game_id,omitempty"`
+	Active       bool                `bson:"active" json:"active"`
+	UpdatedAt    time.Time           `bson:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session là một lần đăng nhập trên một thiết bị. _id trùng với family_id
+// của các refresh token và claim sid của access token cấp cho phiên này.
+type Session struct {
+	ID           primitive.ObjectID `bson:"_id" json:"id"`
+	UserID       primitive.ObjectID `bson:"user_id" json:"user_id"`
+	DeviceName   string             `bson:"device_name" json:"device_name"`
+	DeviceHash   string             `bson:"device_hash" json:"-"`
+	IP           string             `bson:"ip" json:"ip"`
+	UserAgent    string             `bson:"user_agent" json:"user_agent"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	LastSeenAt   time.Time          `bson:"last_seen_at" json:"last_seen_at"`
+	LastSeenIP   string             `bson:"last_seen_ip" json:"last_seen_ip"`
+	ExpiresAt    time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt    *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	RevokeReason string             `bson:"revoke_reason,omitempty" json:"revoke_reason,omitempty"`
+}
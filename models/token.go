@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken lưu hash của một refresh token. Các token sinh ra từ cùng
+// một lần đăng nhập có chung FamilyID; mỗi lần refresh token cũ bị đánh dấu
+// RotatedAt và được thay bằng token mới trong cùng family.
+type RefreshToken struct {
+	ID              primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID          primitive.ObjectID  `bson:"user_id" json:"user_id"`
+	FamilyID        primitive.ObjectID  `bson:"family_id" json:"family_id"`
+	TokenHash       string              `bson:"token_hash" json:"-"`
+	AccessJTI       string              `bson:"access_jti" json:"-"`
+	AccessExpiresAt time.Time           `bson:"access_expires_at" json:"-"`
+	ExpiresAt       time.Time           `bson:"expires_at" json:"expires_at"`
+	CreatedAt       time.Time           `bson:"created_at" json:"created_at"`
+	RotatedAt       *time.Time          `bson:"rotated_at,omitempty" json:"rotated_at,omitempty"`
+	ReplacedBy      *primitive.ObjectID `bson:"replaced_by,omitempty" json:"replaced_by,omitempty"`
+	RevokedAt       *time.Time          `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	RevokeReason    string              `bson:"revoke_reason,omitempty" json:"revoke_reason,omitempty"`
+}
+
+// RevokedToken là một access token (theo jti) bị thu hồi trước khi hết hạn.
+// Bản ghi tự xoá theo TTL khi token đã hết hạn tự nhiên.
+type RevokedToken struct {
+	JTI       string             `bson:"_id" json:"jti"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt time.Time          `bson:"revoked_at" json:"revoked_at"`
+	Reason    string             `bson:"reason" json:"reason"`
+}
+
+// OneTimeToken là token dùng một lần gửi qua email (xác minh email, đặt lại
+// mật khẩu...). Chỉ lưu hash; bản ghi được giữ 48 giờ để tính rate limit
+// rồi tự xoá theo TTL.
+type OneTimeToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Purpose   string             `bson:"purpose" json:"purpose"`
+	TokenHash string             `bson:"token_hash" json:"-"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty" json:"used_at,omitempty"`
+}
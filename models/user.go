@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -11,4 +13,13 @@ type User struct {
 	Password    string             `bson:"password" json:"-"`
 	CoinBalance int                `bson:"coin_balance" json:"coin_balance"`
 	Role        string             `bson:"role" json:"role"` // user, admin
+	// EmailVerified chỉ được lưu false với tài khoản đăng ký sau khi có xác
+	// minh email; tài khoản cũ không có field này được coi như đã xác minh.
+	EmailVerified   bool       `bson:"email_verified" json:"email_verified"`
+	EmailVerifiedAt *time.Time `bson:"email_verified_at,omitempty" json:"email_verified_at,omitempty"`
+	// PendingEmail là email mới đang chờ xác minh; Email chỉ đổi sau khi
+	// user bấm link gửi tới địa chỉ mới.
+	PendingEmail string    `bson:"pending_email,omitempty" json:"pending_email,omitempty"`
+	CreatedAt    time.Time `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	MFAEnabled   bool      `bson:"mfa_enabled" json:"mfa_enabled"`
 }
@@ -0,0 +1,154 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go-mvc-demo/oidc"
+	"go-mvc-demo/oidc/oidctest"
+)
+
+const redirectURL = "http://app.test/auth/oidc/mock/callback"
+
+var alice = oidctest.Identity{Subject: "alice-1", Email: "Alice@Example.com", EmailVerified: true, Name: "Alice"}
+
+func newProvider(issuer string) *oidc.Provider {
+	return oidc.NewProvider(oidc.Config{
+		Name:        "mock",
+		Issuer:      issuer,
+		ClientID:    oidctest.ClientID,
+		RedirectURL: redirectURL,
+	})
+}
+
+// login chạy bước chuyển hướng tới issuer và trả về code, state, nonce,
+// verifier như controller sẽ lưu.
+func login(t *testing.T, srv *oidctest.Server, p *oidc.Provider, id oidctest.Identity) (code, state, nonce, verifier string) {
+	t.Helper()
+	state, _ = oidc.RandomString(32)
+	nonce, _ = oidc.RandomString(32)
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := p.AuthCodeURL(context.Background(), state, nonce, challenge)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	if !strings.HasPrefix(authURL, srv.Issuer()+"/authorize?") {
+		t.Fatalf("AuthCodeURL = %s, want issuer authorize endpoint", authURL)
+	}
+	code, gotState, err := srv.Authorize(authURL, id)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if gotState != state {
+		t.Fatalf("state round-trip = %q, want %q", gotState, state)
+	}
+	return code, state, nonce, verifier
+}
+
+func TestExchangeReturnsClaims(t *testing.T) {
+	srv := oidctest.New(t)
+	p := newProvider(srv.Issuer())
+	code, _, nonce, verifier := login(t, srv, p, alice)
+
+	claims, err := p.Exchange(context.Background(), code, verifier, nonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if claims.Subject != alice.Subject || claims.Email != alice.Email || !claims.EmailVerified || claims.Name != alice.Name {
+		t.Errorf("claims = %+v", claims)
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	srv := oidctest.New(t)
+	p := newProvider(srv.Issuer())
+
+	t.Run("wrong PKCE verifier", func(t *testing.T) {
+		code, _, nonce, _ := login(t, srv, p, alice)
+		other, _, _ := oidc.NewPKCE()
+		if _, err := p.Exchange(context.Background(), code, other, nonce); !errors.Is(err, oidc.ErrExchangeFailed) {
+			t.Fatalf("Exchange = %v, want ErrExchangeFailed", err)
+		}
+	})
+	t.Run("code reused", func(t *testing.T) {
+		code, _, nonce, verifier := login(t, srv, p, alice)
+		if _, err := p.Exchange(context.Background(), code, verifier, nonce); err != nil {
+			t.Fatalf("first Exchange: %v", err)
+		}
+		if _, err := p.Exchange(context.Background(), code, verifier, nonce); !errors.Is(err, oidc.ErrExchangeFailed) {
+			t.Fatalf("second Exchange = %v, want ErrExchangeFailed", err)
+		}
+	})
+	t.Run("nonce from another login", func(t *testing.T) {
+		code, _, _, verifier := login(t, srv, p, alice)
+		if _, err := p.Exchange(context.Background(), code, verifier, "other-nonce"); !errors.Is(err, oidc.ErrNonceMismatch) {
+			t.Fatalf("Exchange = %v, want ErrNonceMismatch", err)
+		}
+	})
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	srv := oidctest.New(t)
+	p := newProvider(srv.Issuer())
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		mutate func(map[string]interface{})
+		want   error
+	}{
+		{"valid", func(map[string]interface{}) {}, nil},
+		{"wrong audience", func(c map[string]interface{}) { c["aud"] = "someone-else" }, oidc.ErrInvalidIDToken},
+		{"wrong issuer", func(c map[string]interface{}) { c["iss"] = "https://evil.test" }, oidc.ErrInvalidIDToken},
+		{"expired", func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, oidc.ErrInvalidIDToken},
+		{"no expiry", func(c map[string]interface{}) { delete(c, "exp") }, oidc.ErrInvalidIDToken},
+		{"no subject", func(c map[string]interface{}) { c["sub"] = "" }, oidc.ErrInvalidIDToken},
+		{"nonce mismatch", func(c map[string]interface{}) { c["nonce"] = "other" }, oidc.ErrNonceMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := srv.Claims(alice, "n-1")
+			tt.mutate(claims)
+			_, err := p.VerifyIDToken(ctx, srv.Sign(claims), "n-1")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("VerifyIDToken = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenRefetchesKeysAfterRotation(t *testing.T) {
+	srv := oidctest.New(t)
+	p := newProvider(srv.Issuer())
+	ctx := context.Background()
+
+	old := srv.Sign(srv.Claims(alice, "n"))
+	if _, err := p.VerifyIDToken(ctx, old, "n"); err != nil {
+		t.Fatalf("token before rotation: %v", err)
+	}
+	if err := srv.RotateKey(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.VerifyIDToken(ctx, srv.Sign(srv.Claims(alice, "n")), "n"); err != nil {
+		t.Fatalf("token with new kid: %v", err)
+	}
+	if _, err := p.VerifyIDToken(ctx, old, "n"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Fatalf("token with retired kid = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	srv := oidctest.New(t)
+	// Cùng server nhưng cấu hình bằng hostname khác issuer trong discovery.
+	p := newProvider(strings.Replace(srv.Issuer(), "127.0.0.1", "localhost", 1))
+	_, err := p.AuthCodeURL(context.Background(), "s", "n", "c")
+	if err == nil || !strings.Contains(err.Error(), "issuer mismatch") {
+		t.Fatalf("AuthCodeURL = %v, want issuer mismatch", err)
+	}
+}
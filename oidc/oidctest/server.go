@@ -0,0 +1,212 @@
+// Package oidctest cung cấp OpenID Connect issuer giả chạy trên httptest để
+// test luồng đăng nhập mà không cần provider thật:
+//
+//	/.well-known/openid-configuration  discovery document
+//	/jwks                              public key (RSA, kid hiện tại)
+//	/authorize                         không dùng; test gọi Authorize thay trình duyệt
+//	/token                             đổi code lấy ID token, kiểm tra PKCE
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ClientID là client_id mà issuer giả chấp nhận.
+const ClientID = "test-client"
+
+// Identity là user đăng nhập ở issuer giả.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type grant struct {
+	identity    Identity
+	nonce       string
+	challenge   string
+	redirectURI string
+}
+
+// Server là OIDC issuer giả.
+type Server struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	key    *rsa.PrivateKey
+	kid    string
+	keyGen int
+	codes  map[string]grant
+}
+
+// New khởi động issuer giả và đóng nó khi test kết thúc.
+func New(tb testing.TB) *Server {
+	tb.Helper()
+	s := &Server{codes: map[string]grant{}}
+	if err := s.RotateKey(); err != nil {
+		tb.Fatalf("oidctest: generate key: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/jwks", s.jwks)
+	mux.HandleFunc("/token", s.token)
+	s.Server = httptest.NewServer(mux)
+	tb.Cleanup(s.Close)
+	return s
+}
+
+// Issuer trả về issuer URL để cấu hình oidc.Provider.
+func (s *Server) Issuer() string {
+	return s.URL
+}
+
+// RotateKey tạo signing key mới với kid mới; token ký bằng key cũ không còn
+// xác thực được.
+func (s *Server) RotateKey() error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keyGen++
+	s.key, s.kid = key, fmt.Sprintf("key-%d", s.keyGen)
+	return nil
+}
+
+// Authorize đóng vai trình duyệt và trang đăng nhập: nhận URL từ
+// Provider.AuthCodeURL, cho id đăng nhập và trả về code, state sẽ được
+// gửi tới redirect_uri.
+func (s *Server) Authorize(authURL string, id Identity) (code, state string, err error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	q := u.Query()
+	if q.Get("client_id") != ClientID || q.Get("response_type") != "code" {
+		return "", "", fmt.Errorf("oidctest: bad authorization request: %s", u.RawQuery)
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		return "", "", fmt.Errorf("oidctest: missing PKCE challenge")
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	code = base64.RawURLEncoding.EncodeToString(buf)
+
+	s.mu.Lock()
+	s.codes[code] = grant{
+		identity:    id,
+		nonce:       q.Get("nonce"),
+		challenge:   q.Get("code_challenge"),
+		redirectURI: q.Get("redirect_uri"),
+	}
+	s.mu.Unlock()
+	return code, q.Get("state"), nil
+}
+
+// Sign ký claims bằng key hiện tại, dùng để tạo ID token tuỳ ý (sai
+// audience, hết hạn...).
+func (s *Server) Sign(claims jwt.MapClaims) string {
+	s.mu.Lock()
+	key, kid := s.key, s.kid
+	s.mu.Unlock()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	raw, err := token.SignedString(key)
+	if err != nil {
+		panic(err)
+	}
+	return raw
+}
+
+// Claims trả về claims chuẩn của ID token cho id, hết hạn sau 5 phút.
+func (s *Server) Claims(id Identity, nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            s.URL,
+		"aud":            ClientID,
+		"sub":            id.Subject,
+		"email":          id.Email,
+		"email_verified": id.EmailVerified,
+		"name":           id.Name,
+		"nonce":          nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 s.URL,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"jwks_uri":               s.URL + "/jwks",
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	pub, kid := s.key.PublicKey, s.kid
+	s.mu.Unlock()
+	b64 := base64.RawURLEncoding
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   b64.EncodeToString(pub.N.Bytes()),
+			"e":   b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	code := r.PostForm.Get("code")
+	s.mu.Lock()
+	g, ok := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case r.PostForm.Get("grant_type") != "authorization_code", r.PostForm.Get("client_id") != ClientID:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_client"})
+	case !ok, r.PostForm.Get("redirect_uri") != g.redirectURI:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+	case base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+	default:
+		writeJSON(w, http.StatusOK, map[string]string{
+			"token_type": "Bearer",
+			"id_token":   s.Sign(s.Claims(g.identity, g.nonce)),
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package payment
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// HostedGateway là cổng thanh toán thật dạng hosted checkout: người dùng
+// được chuyển tới trang thanh toán của cổng (CheckoutURL kèm reference và
+// số tiền), cổng gọi lại POST /webhooks/payments với chữ ký HMAC theo cùng
+// định dạng SignatureHeader.
+type HostedGateway struct {
+	Secret      []byte
+	CheckoutURL string
+	// ReturnURL là trang cổng chuyển người dùng về sau khi thanh toán.
+	ReturnURL string
+	now       func() time.Time
+}
+
+func NewHostedGateway(secret, checkoutURL, returnURL string) *HostedGateway {
+	return &HostedGateway{Secret: []byte(secret), CheckoutURL: checkoutURL, ReturnURL: returnURL, now: time.Now}
+}
+
+func (g *HostedGateway) Name() string {
+	return "hosted"
+}
+
+func (g *HostedGateway) CreateCheckout(ctx context.Context, req CheckoutRequest) (Checkout, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return Checkout{}, err
+	}
+	ref := "chk_" + hex.EncodeToString(buf)
+
+	u, err := url.Parse(g.CheckoutURL)
+	if err != nil {
+		return Checkout{}, err
+	}
+	q := u.Query()
+	q.Set("reference", ref)
+	q.Set("amount", strconv.Itoa(req.Amount))
+	q.Set("expires_at", strconv.FormatInt(req.ExpiresAt.Unix(), 10))
+	if g.ReturnURL != "" {
+		q.Set("return_url", g.ReturnURL)
+	}
+	u.RawQuery = q.Encode()
+	return Checkout{Reference: ref, URL: u.String()}, nil
+}
+
+func (g *HostedGateway) ParseWebhook(header http.Header, body []byte) (Event, error) {
+	return parseSignedEvent(g.Secret, header, body, g.now())
+}
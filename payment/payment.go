@@ -0,0 +1,55 @@
+// Package payment trừu tượng hoá cổng thanh toán dùng cho nạp coin.
+//
+// Luồng chung: server tạo một recharge ở trạng thái pending, gọi
+// Provider.CreateCheckout để lấy mã tham chiếu và đường dẫn thanh toán,
+// sau đó chỉ cộng coin khi nhận được webhook hợp lệ báo thành công.
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Trạng thái mà cổng thanh toán báo về qua webhook.
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrInvalidPayload   = errors.New("invalid webhook payload")
+)
+
+// CheckoutRequest là thông tin cần để tạo một phiên thanh toán.
+type CheckoutRequest struct {
+	RechargeID string
+	UserID     string
+	Amount     int
+	ExpiresAt  time.Time
+}
+
+// Checkout là kết quả tạo phiên thanh toán.
+type Checkout struct {
+	Reference string
+	URL       string
+}
+
+// Event là một webhook đã được xác thực.
+type Event struct {
+	ID         string    `json:"id"`
+	Reference  string    `json:"reference"`
+	Status     string    `json:"status"`
+	Amount     int       `json:"amount"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// Provider là một cổng thanh toán.
+type Provider interface {
+	Name() string
+	CreateCheckout(ctx context.Context, req CheckoutRequest) (Checkout, error)
+	// ParseWebhook xác thực chữ ký và giải mã webhook.
+	ParseWebhook(header http.Header, body []byte) (Event, error)
+}
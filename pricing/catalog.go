@@ -0,0 +1,259 @@
+package pricing
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SourcePricing là Source của PriceHistory khi giá đổi do áp dụng bảng giá.
+const SourcePricing = "pricing"
+
+// InputFromGame lấy dữ liệu tính giá từ một game trong catalog.
+func InputFromGame(g models.Game) Input {
+	return Input{Released: g.Released, Rating: g.Rating, Genres: g.Genres}
+}
+
+// Selection chọn các game áp dụng bảng giá. Mặc định chỉ game import từ
+// RAWG; game tạo tay giữ giá do admin đặt trừ khi IncludeManual.
+type Selection struct {
+	GameIDs       []primitive.ObjectID
+	IncludeManual bool
+}
+
+func (s Selection) filter() bson.M {
+	filter := bson.M{}
+	if len(s.GameIDs) > 0 {
+		filter["_id"] = bson.M{"$in": s.GameIDs}
+	}
+	if !s.IncludeManual {
+		filter["rawg_id"] = bson.M{"$gt": 0}
+	}
+	return filter
+}
+
+// Change là giá trước/sau của một game.
+type Change struct {
+	GameID primitive.ObjectID `json:"game_id"`
+	RawgID int                `json:"rawg_id,omitempty"`
+	Name   string             `json:"name"`
+	Before int                `json:"before"`
+	After  int                `json:"after"`
+	Delta  int                `json:"delta"`
+	Steps  []Step             `json:"steps"`
+}
+
+// Report tóm tắt kết quả preview hoặc apply. Changes chỉ chứa tối đa limit
+// game thay đổi nhiều nhất; các con số tổng tính trên toàn bộ selection.
+type Report struct {
+	Applied     bool                `json:"applied"`
+	BatchID     *primitive.ObjectID `json:"batch_id,omitempty"`
+	Total       int                 `json:"total"`
+	Changed     int                 `json:"changed"`
+	Increased   int                 `json:"increased"`
+	Decreased   int                 `json:"decreased"`
+	Conflicts   int                 `json:"conflicts,omitempty"`
+	TotalBefore int64               `json:"total_before"`
+	TotalAfter  int64               `json:"total_after"`
+	Changes     []Change            `json:"changes"`
+}
+
+// compute tính giá mới cho selection mà không ghi gì vào DB.
+func compute(ctx context.Context, sel Selection) (Report, []Change, error) {
+	report := Report{Changes: []Change{}}
+	cursor, err := config.DB.Collection("games").Find(ctx, sel.filter(),
+		options.Find().SetProjection(bson.M{
+			"_id": 1, "rawg_id": 1, "name": 1, "price": 1,
+			"released": 1, "rating": 1, "genres": 1,
+		}),
+	)
+	if err != nil {
+		return report, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var changes []Change
+	for cursor.Next(ctx) {
+		var game models.Game
+		if err := cursor.Decode(&game); err != nil {
+			return report, nil, err
+		}
+		price, steps := Price(InputFromGame(game))
+		report.Total++
+		report.TotalBefore += int64(game.Price)
+		report.TotalAfter += int64(price)
+		if price == game.Price {
+			continue
+		}
+		if price > game.Price {
+			report.Increased++
+		} else {
+			report.Decreased++
+		}
+		changes = append(changes, Change{
+			GameID: game.ID, RawgID: game.RawgID, Name: game.Name,
+			Before: game.Price, After: price, Delta: price - game.Price, Steps: steps,
+		})
+	}
+	if err := cursor.Err(); err != nil {
+		return report, nil, err
+	}
+	report.Changed = len(changes)
+	return report, changes, nil
+}
+
+// top trả về tối đa limit thay đổi lớn nhất theo trị tuyệt đối.
+func top(changes []Change, limit int) []Change {
+	sorted := append([]Change{}, changes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return abs(sorted[i].Delta) > abs(sorted[j].Delta)
+	})
+	if len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+	return sorted
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Preview tính giá mới cho selection (dry-run).
+func Preview(ctx context.Context, sel Selection, limit int) (Report, error) {
+	report, changes, err := compute(ctx, sel)
+	if err != nil {
+		return report, err
+	}
+	report.Changes = top(changes, limit)
+	return report, nil
+}
+
+// applyBatchSize là số game đổi giá trong một transaction.
+const applyBatchSize = 500
+
+// Apply đổi giá các game trong selection theo bảng giá và ghi price_history.
+// Mỗi lô applyBatchSize game được đổi giá và ghi lịch sử trong cùng một
+// transaction nên không có giá nào đổi mà thiếu lịch sử. Game bị đổi giá ở
+// nơi khác trong lúc apply được bỏ qua và đếm vào Conflicts.
+func Apply(ctx context.Context, sel Selection, changedBy primitive.ObjectID, limit int) (Report, error) {
+	report, changes, err := compute(ctx, sel)
+	if err != nil {
+		return report, err
+	}
+	batchID := primitive.NewObjectID()
+	report.Applied = true
+	report.BatchID = &batchID
+
+	var applied []Change
+	for start := 0; start < len(changes); start += applyBatchSize {
+		batch := changes[start:min(start+applyBatchSize, len(changes))]
+		done, err := applyBatch(ctx, batch, batchID, changedBy)
+		if err != nil {
+			// Các lô trước đã commit; báo lại phần đã áp dụng.
+			report.Changed = len(applied)
+			report.Changes = top(applied, limit)
+			return report, err
+		}
+		applied = append(applied, done...)
+		for _, ch := range conflicts(batch, done) {
+			report.Conflicts++
+			report.TotalAfter += int64(ch.Before - ch.After)
+			if ch.Delta > 0 {
+				report.Increased--
+			} else {
+				report.Decreased--
+			}
+		}
+	}
+	report.Changed = len(applied)
+	report.Changes = top(applied, limit)
+	return report, nil
+}
+
+// applyBatch đổi giá và ghi price_history cho batch trong một transaction,
+// trả về các thay đổi đã áp dụng.
+func applyBatch(ctx context.Context, batch []Change, batchID, changedBy primitive.ObjectID) ([]Change, error) {
+	games := config.DB.Collection("games")
+	history := config.DB.Collection("price_history")
+
+	var applied []Change
+	err := config.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		// Transaction có thể chạy lại khi gặp lỗi tạm thời.
+		applied = applied[:0]
+		var docs []interface{}
+		now := time.Now()
+		for _, ch := range batch {
+			res, err := games.UpdateOne(sessCtx,
+				bson.M{"_id": ch.GameID, "price": ch.Before},
+				bson.M{"$set": bson.M{"price": ch.After, "updated_at": now}},
+			)
+			if err != nil {
+				return err
+			}
+			if res.MatchedCount == 0 {
+				continue
+			}
+			applied = append(applied, ch)
+			docs = append(docs, models.PriceHistory{
+				GameID:    ch.GameID,
+				OldPrice:  ch.Before,
+				NewPrice:  ch.After,
+				Source:    SourcePricing,
+				BatchID:   batchID,
+				ChangedBy: changedBy,
+				CreatedAt: now,
+			})
+		}
+		if len(docs) == 0 {
+			return nil
+		}
+		_, err := history.InsertMany(sessCtx, docs)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// conflicts trả về các thay đổi trong batch không có trong applied.
+func conflicts(batch, applied []Change) []Change {
+	done := make(map[primitive.ObjectID]bool, len(applied))
+	for _, ch := range applied {
+		done[ch.GameID] = true
+	}
+	var skipped []Change
+	for _, ch := range batch {
+		if !done[ch.GameID] {
+			skipped = append(skipped, ch)
+		}
+	}
+	return skipped
+}
+
+// History trả về lịch sử giá của một game, mới nhất trước.
+func History(ctx context.Context, gameID primitive.ObjectID, limit int64) ([]models.PriceHistory, error) {
+	cursor, err := config.DB.Collection("price_history").Find(ctx,
+		bson.M{"game_id": gameID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+	list := []models.PriceHistory{}
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,68 @@
+package pricing_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/config/mongotest"
+	"go-mvc-demo/models"
+	"go-mvc-demo/pricing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestApplyWritesPriceAndHistoryTogether(t *testing.T) {
+	mongotest.Setup(t)
+	ctx := context.Background()
+
+	released := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	var docs []interface{}
+	for i := 0; i < 1203; i++ {
+		g := models.Game{ID: primitive.NewObjectID(), RawgID: i + 1, Name: "g", Price: 100, Released: &released, Rating: 4.6, Genres: []string{"RPG"}}
+		if i%4 == 0 {
+			// Đã đúng giá: không đổi, không ghi lịch sử.
+			g.Price, _ = pricing.Price(pricing.InputFromGame(g))
+		}
+		docs = append(docs, g)
+	}
+	// Game tạo tay không nằm trong selection mặc định.
+	docs = append(docs, models.Game{ID: primitive.NewObjectID(), Name: "manual", Price: 1})
+	if _, err := config.DB.Collection("games").InsertMany(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+
+	admin := primitive.NewObjectID()
+	report, err := pricing.Apply(ctx, pricing.Selection{}, admin, 10)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if report.Total != 1203 || report.Changed != 902 || report.Conflicts != 0 || len(report.Changes) != 10 {
+		t.Fatalf("report = total %d changed %d conflicts %d changes %d", report.Total, report.Changed, report.Conflicts, len(report.Changes))
+	}
+
+	history, err := config.DB.Collection("price_history").CountDocuments(ctx, bson.M{"batch_id": *report.BatchID, "changed_by": admin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history != int64(report.Changed) {
+		t.Fatalf("%d price_history rows, want %d", history, report.Changed)
+	}
+	stale, err := config.DB.Collection("games").CountDocuments(ctx, bson.M{"rawg_id": bson.M{"$gt": 0}, "price": 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stale != 0 {
+		t.Fatalf("%d games kept the old price", stale)
+	}
+
+	again, err := pricing.Apply(ctx, pricing.Selection{}, admin, 10)
+	if err != nil {
+		t.Fatalf("second Apply: %v", err)
+	}
+	if again.Changed != 0 {
+		t.Fatalf("second Apply changed %d games, want 0", again.Changed)
+	}
+}
@@ -0,0 +1,214 @@
+// Package pricing tính giá bán của game từ dữ liệu catalog.
+//
+// Giá được tính bởi một Engine gồm các Rule áp dụng lần lượt: rule đầu đặt
+// giá gốc, các rule sau nhân hệ số, giới hạn min/max và làm tròn về mức giá
+// chuẩn. Cùng một game luôn cho cùng một giá ở mọi môi trường.
+package pricing
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Input là dữ liệu của game dùng để tính giá.
+type Input struct {
+	Released *time.Time
+	Rating   float64
+	Genres   []string
+}
+
+// Rule là một bước tính giá: nhận giá hiện tại và trả về giá mới.
+type Rule interface {
+	Name() string
+	Apply(price float64, in Input) float64
+}
+
+// Step ghi lại giá sau mỗi rule để giải thích kết quả.
+type Step struct {
+	Rule  string  `json:"rule" bson:"rule"`
+	Price float64 `json:"price" bson:"price"`
+}
+
+// Engine áp dụng Rules theo thứ tự.
+type Engine struct {
+	Rules []Rule
+}
+
+// Price trả về giá của game và giá sau từng rule.
+func (e *Engine) Price(in Input) (int, []Step) {
+	price := 0.0
+	steps := make([]Step, 0, len(e.Rules))
+	for _, r := range e.Rules {
+		price = r.Apply(price, in)
+		steps = append(steps, Step{Rule: r.Name(), Price: math.Round(price*100) / 100})
+	}
+	return int(math.Round(price)), steps
+}
+
+// YearTier là giá gốc cho game phát hành từ FromYear trở đi.
+type YearTier struct {
+	FromYear int
+	Price    int
+}
+
+// BaseByReleaseYear đặt giá gốc theo năm phát hành: dùng tier có FromYear
+// lớn nhất không vượt quá năm phát hành. Game chưa rõ ngày phát hành dùng
+// Unknown.
+type BaseByReleaseYear struct {
+	Tiers   []YearTier
+	Unknown int
+}
+
+func (BaseByReleaseYear) Name() string { return "base_by_release_year" }
+
+func (r BaseByReleaseYear) Apply(_ float64, in Input) float64 {
+	if in.Released == nil {
+		return float64(r.Unknown)
+	}
+	year := in.Released.Year()
+	best, price := math.MinInt, r.Unknown
+	for _, t := range r.Tiers {
+		if t.FromYear <= year && t.FromYear > best {
+			best, price = t.FromYear, t.Price
+		}
+	}
+	return float64(price)
+}
+
+// RatingTier là hệ số cho game có rating từ MinRating trở lên.
+type RatingTier struct {
+	MinRating  float64
+	Multiplier float64
+}
+
+// RatingMultiplier nhân giá theo rating RAWG (0-5) bằng tier có MinRating
+// cao nhất mà game đạt. Game chưa có rating (0) giữ nguyên giá.
+type RatingMultiplier struct {
+	Tiers []RatingTier
+}
+
+func (RatingMultiplier) Name() string { return "rating_multiplier" }
+
+func (r RatingMultiplier) Apply(price float64, in Input) float64 {
+	if in.Rating <= 0 {
+		return price
+	}
+	best, mult := -1.0, 1.0
+	for _, t := range r.Tiers {
+		if in.Rating >= t.MinRating && t.MinRating > best {
+			best, mult = t.MinRating, t.Multiplier
+		}
+	}
+	return price * mult
+}
+
+// GenreMultiplier nhân giá theo thể loại. Game có nhiều thể loại trong
+// Multipliers dùng hệ số thấp nhất nếu có hệ số < 1, ngược lại dùng hệ số
+// cao nhất. Tên thể loại không phân biệt hoa thường.
+type GenreMultiplier struct {
+	Multipliers map[string]float64
+}
+
+func (GenreMultiplier) Name() string { return "genre_multiplier" }
+
+func (r GenreMultiplier) Apply(price float64, in Input) float64 {
+	lowest, highest := 1.0, 1.0
+	for _, g := range in.Genres {
+		for name, m := range r.Multipliers {
+			if !strings.EqualFold(name, g) {
+				continue
+			}
+			lowest = math.Min(lowest, m)
+			highest = math.Max(highest, m)
+		}
+	}
+	if lowest < 1 {
+		return price * lowest
+	}
+	return price * highest
+}
+
+// Clamp giới hạn giá trong [Min, Max].
+type Clamp struct {
+	Min int
+	Max int
+}
+
+func (Clamp) Name() string { return "clamp" }
+
+func (r Clamp) Apply(price float64, _ Input) float64 {
+	return math.Max(float64(r.Min), math.Min(float64(r.Max), price))
+}
+
+// RoundToPricePoint làm tròn về mức giá gần nhất trong Points; cách đều
+// hai mức thì lấy mức thấp hơn.
+type RoundToPricePoint struct {
+	Points []int
+}
+
+func (RoundToPricePoint) Name() string { return "round_to_price_point" }
+
+func (r RoundToPricePoint) Apply(price float64, _ Input) float64 {
+	if len(r.Points) == 0 {
+		return math.Round(price)
+	}
+	points := append([]int(nil), r.Points...)
+	sort.Ints(points)
+	best := points[0]
+	for _, p := range points[1:] {
+		if math.Abs(float64(p)-price) < math.Abs(float64(best)-price) {
+			best = p
+		}
+	}
+	return float64(best)
+}
+
+// Default là bảng giá mặc định, giữ giá trong khoảng 100-999 coin như giá
+// ngẫu nhiên trước đây.
+func Default() *Engine {
+	return &Engine{Rules: []Rule{
+		BaseByReleaseYear{
+			Tiers: []YearTier{
+				{FromYear: 0, Price: 200},
+				{FromYear: 2010, Price: 300},
+				{FromYear: 2015, Price: 450},
+				{FromYear: 2020, Price: 600},
+			},
+			Unknown: 300,
+		},
+		RatingMultiplier{Tiers: []RatingTier{
+			{MinRating: 0, Multiplier: 0.85},
+			{MinRating: 3.0, Multiplier: 1.0},
+			{MinRating: 4.0, Multiplier: 1.15},
+			{MinRating: 4.5, Multiplier: 1.3},
+		}},
+		GenreMultiplier{Multipliers: map[string]float64{
+			"Indie":                 0.7,
+			"Casual":                0.6,
+			"Puzzle":                0.8,
+			"Massively Multiplayer": 0.8,
+			"RPG":                   1.1,
+			"Strategy":              1.05,
+		}},
+		Clamp{Min: 100, Max: 999},
+		RoundToPricePoint{Points: []int{
+			100, 149, 199, 249, 299, 349, 399, 449, 499, 549,
+			599, 649, 699, 749, 799, 849, 899, 949, 999,
+		}},
+	}}
+}
+
+// engine là bảng giá đang dùng; đổi qua Configure.
+var engine = Default()
+
+// Configure thay bảng giá đang dùng.
+func Configure(e *Engine) {
+	engine = e
+}
+
+// Price tính giá bằng bảng giá đang dùng.
+func Price(in Input) (int, []Step) {
+	return engine.Price(in)
+}
@@ -0,0 +1,160 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int) *time.Time {
+	t := time.Date(year, 6, 15, 0, 0, 0, 0, time.UTC)
+	return &t
+}
+
+func TestBaseByReleaseYear(t *testing.T) {
+	rule := BaseByReleaseYear{
+		// Cố ý không sắp xếp: kết quả không được phụ thuộc thứ tự tier.
+		Tiers: []YearTier{
+			{FromYear: 2020, Price: 600},
+			{FromYear: 0, Price: 200},
+			{FromYear: 2015, Price: 450},
+			{FromYear: 2010, Price: 300},
+		},
+		Unknown: 333,
+	}
+	tests := []struct {
+		name     string
+		released *time.Time
+		want     float64
+	}{
+		{"unknown release", nil, 333},
+		{"very old", date(1995), 200},
+		{"year before tier", date(2009), 200},
+		{"first year of tier", date(2010), 300},
+		{"inside tier", date(2017), 450},
+		{"newest tier", date(2024), 600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rule.Apply(0, Input{Released: tt.released}); got != tt.want {
+				t.Errorf("Apply = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := (BaseByReleaseYear{Unknown: 123}).Apply(0, Input{Released: date(2020)}); got != 123 {
+		t.Errorf("no tiers: Apply = %v, want Unknown 123", got)
+	}
+}
+
+func TestRoundToPricePoint(t *testing.T) {
+	rule := RoundToPricePoint{Points: []int{199, 100, 149}}
+	tests := []struct {
+		price float64
+		want  float64
+	}{
+		{50, 100},
+		{124, 100},
+		{124.5, 100}, // cách đều 100 và 149: lấy mức thấp hơn
+		{125, 149},
+		{174, 149}, // cách đều 149 và 199: lấy 149
+		{174.01, 199},
+		{5000, 199},
+	}
+	for _, tt := range tests {
+		if got := rule.Apply(tt.price, Input{}); got != tt.want {
+			t.Errorf("Apply(%v) = %v, want %v", tt.price, got, tt.want)
+		}
+	}
+	if got := (RoundToPricePoint{}).Apply(123.5, Input{}); got != 124 {
+		t.Errorf("no points: Apply(123.5) = %v, want 124", got)
+	}
+	if rule.Points[0] != 199 {
+		t.Error("Apply sorted the caller's Points slice")
+	}
+}
+
+func TestGenreMultiplier(t *testing.T) {
+	rule := GenreMultiplier{Multipliers: map[string]float64{
+		"Indie":    0.7,
+		"Casual":   0.6,
+		"RPG":      1.1,
+		"Strategy": 1.05,
+	}}
+	tests := []struct {
+		name   string
+		genres []string
+		want   float64
+	}{
+		{"no genres", nil, 100},
+		{"unlisted genre", []string{"Action"}, 100},
+		{"single premium", []string{"RPG"}, 110},
+		{"highest premium wins", []string{"Strategy", "RPG"}, 110},
+		{"discount beats premium", []string{"RPG", "Indie"}, 70},
+		{"lowest discount wins", []string{"Indie", "Casual", "RPG"}, 60},
+		{"case insensitive", []string{"rpg", "INDIE"}, 70},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Lặp nhiều lần vì thứ tự duyệt map thay đổi mỗi lần chạy.
+			for i := 0; i < 50; i++ {
+				if got := rule.Apply(100, Input{Genres: tt.genres}); !almostEqual(got, tt.want) {
+					t.Fatalf("Apply = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func almostEqual(a, b float64) bool {
+	d := a - b
+	return d < 1e-9 && d > -1e-9
+}
+
+func TestDefaultEngineIsDeterministic(t *testing.T) {
+	tests := []struct {
+		name string
+		in   Input
+		want int
+	}{
+		// 600 * 1.3 * 0.7 = 546 -> 549
+		{"recent acclaimed indie rpg", Input{Released: date(2021), Rating: 4.6, Genres: []string{"RPG", "Indie"}}, 549},
+		// 300 (unknown) -> 299
+		{"unknown everything", Input{}, 299},
+		// 200 * 0.85 * 0.6 = 102 -> 100
+		{"old badly rated casual", Input{Released: date(2001), Rating: 2.1, Genres: []string{"Casual"}}, 100},
+		// 600 * 1.3 * 1.1 = 858 -> 849
+		{"recent acclaimed rpg", Input{Released: date(2023), Rating: 4.7, Genres: []string{"RPG"}}, 849},
+		// 450 * 1.15 = 517.5 -> 499 (cách 18.5) thay vì 549 (cách 31.5)
+		{"mid rated 2016", Input{Released: date(2016), Rating: 4.2, Genres: []string{"Action"}}, 499},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, steps := Default().Price(tt.in)
+			if first != tt.want {
+				t.Fatalf("Price = %d, want %d (steps %+v)", first, tt.want, steps)
+			}
+			if len(steps) != len(Default().Rules) {
+				t.Fatalf("got %d steps, want one per rule", len(steps))
+			}
+			for i := 0; i < 20; i++ {
+				if again, _ := Default().Price(tt.in); again != first {
+					t.Fatalf("run %d priced %d, first run %d", i, again, first)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultEngineStaysWithinBounds(t *testing.T) {
+	genres := [][]string{nil, {"Casual"}, {"RPG"}, {"Indie", "RPG"}, {"Strategy"}}
+	for year := 1980; year <= 2030; year += 5 {
+		for rating := 0.0; rating <= 5; rating += 0.5 {
+			for _, g := range genres {
+				price, _ := Default().Price(Input{Released: date(year), Rating: rating, Genres: g})
+				if price < 100 || price > 999 {
+					t.Fatalf("Price(%d, %.1f, %v) = %d, outside 100-999", year, rating, g, price)
+				}
+			}
+		}
+	}
+}
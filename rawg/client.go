@@ -0,0 +1,283 @@
+// Package rawg là client cho RAWG Video Games Database API
+// (https://api.rawg.io/docs).
+//
+// Client tự giới hạn tốc độ gọi API, đặt timeout cho mỗi request và thử lại
+// với backoff khi RAWG trả 429/5xx hoặc lỗi mạng.
+package rawg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultBaseURL = "https://api.rawg.io/api"
+	// MaxPageSize là page_size lớn nhất RAWG chấp nhận.
+	MaxPageSize = 40
+)
+
+var ErrNotFound = errors.New("rawg: not found")
+
+// APIError là response lỗi không thử lại được (hoặc đã hết số lần thử).
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("rawg: HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// Options cấu hình Client. Field để trống dùng giá trị mặc định.
+type Options struct {
+	BaseURL string
+	// RequestTimeout áp cho từng lần gọi HTTP (mặc định 15s).
+	RequestTimeout time.Duration
+	// MaxRetries là số lần thử lại sau lần gọi đầu (mặc định 4).
+	MaxRetries int
+	// MinBackoff/MaxBackoff giới hạn thời gian chờ giữa các lần thử (mặc định 1s/30s).
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// RequestsPerSecond giới hạn tốc độ gọi API (mặc định 4). Số âm: không giới hạn.
+	RequestsPerSecond float64
+	HTTPClient        *http.Client
+}
+
+// Client gọi RAWG API. An toàn khi dùng từ nhiều goroutine.
+type Client struct {
+	apiKey  string
+	opts    Options
+	http    *http.Client
+	limiter *limiter
+}
+
+// New tạo client với API key.
+func New(apiKey string, opts Options) *Client {
+	if opts.BaseURL == "" {
+		opts.BaseURL = DefaultBaseURL
+	}
+	if opts.RequestTimeout == 0 {
+		opts.RequestTimeout = 15 * time.Second
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 4
+	}
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff = time.Second
+	}
+	if opts.MaxBackoff == 0 {
+		opts.MaxBackoff = 30 * time.Second
+	}
+	if opts.RequestsPerSecond == 0 {
+		opts.RequestsPerSecond = 4
+	}
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	return &Client{
+		apiKey:  apiKey,
+		opts:    opts,
+		http:    httpClient,
+		limiter: newLimiter(opts.RequestsPerSecond),
+	}
+}
+
+// ListOptions là tham số phân trang của các API danh sách.
+type ListOptions struct {
+	Page     int
+	PageSize int
+	// Ordering ví dụ "-updated"; để trống dùng thứ tự mặc định của RAWG.
+	Ordering string
+}
+
+func (o ListOptions) query() url.Values {
+	q := url.Values{}
+	if o.Page > 0 {
+		q.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.PageSize > 0 {
+		q.Set("page_size", strconv.Itoa(o.PageSize))
+	}
+	if o.Ordering != "" {
+		q.Set("ordering", o.Ordering)
+	}
+	return q
+}
+
+// ListGames trả về một trang của /games. Trang vượt quá số trang hiện có
+// trả về ErrNotFound.
+func (c *Client) ListGames(ctx context.Context, opts ListOptions) (Page[Game], error) {
+	var page Page[Game]
+	err := c.get(ctx, "/games", opts.query(), &page)
+	return page, err
+}
+
+// GetGame trả về chi tiết một game.
+func (c *Client) GetGame(ctx context.Context, id int) (GameDetail, error) {
+	var game GameDetail
+	err := c.get(ctx, "/games/"+strconv.Itoa(id), nil, &game)
+	return game, err
+}
+
+// ListGameScreenshots trả về một trang ảnh chụp màn hình của game.
+func (c *Client) ListGameScreenshots(ctx context.Context, id int, opts ListOptions) (Page[Screenshot], error) {
+	var page Page[Screenshot]
+	err := c.get(ctx, "/games/"+strconv.Itoa(id)+"/screenshots", opts.query(), &page)
+	return page, err
+}
+
+// ListGameMovies trả về các trailer của game.
+func (c *Client) ListGameMovies(ctx context.Context, id int) (Page[Movie], error) {
+	var page Page[Movie]
+	err := c.get(ctx, "/games/"+strconv.Itoa(id)+"/movies", nil, &page)
+	return page, err
+}
+
+// ListGenres trả về một trang thể loại.
+func (c *Client) ListGenres(ctx context.Context, opts ListOptions) (Page[Genre], error) {
+	var page Page[Genre]
+	err := c.get(ctx, "/genres", opts.query(), &page)
+	return page, err
+}
+
+// ListPlatforms trả về một trang nền tảng.
+func (c *Client) ListPlatforms(ctx context.Context, opts ListOptions) (Page[Platform], error) {
+	var page Page[Platform]
+	err := c.get(ctx, "/platforms", opts.query(), &page)
+	return page, err
+}
+
+// get gọi GET path và decode JSON vào dst, thử lại khi lỗi tạm thời.
+func (c *Client) get(ctx context.Context, path string, q url.Values, dst interface{}) error {
+	if q == nil {
+		q = url.Values{}
+	}
+	q.Set("key", c.apiKey)
+	endpoint := c.opts.BaseURL + path + "?" + q.Encode()
+
+	var lastErr error
+	for attempt := 0; attempt <= c.opts.MaxRetries; attempt++ {
+		if attempt > 0 {
+			wait := c.backoff(attempt, lastErr)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+		if err := c.limiter.wait(ctx); err != nil {
+			return err
+		}
+
+		retry, err := c.do(ctx, endpoint, dst)
+		if err == nil {
+			return nil
+		}
+		if !retry || ctx.Err() != nil {
+			return err
+		}
+		lastErr = err
+	}
+	return lastErr
+}
+
+// retryAfterError mang thời gian chờ từ header Retry-After của response 429/503.
+type retryAfterError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+// do thực hiện một lần gọi; retry cho biết lỗi có nên thử lại không.
+func (c *Client) do(ctx context.Context, endpoint string, dst interface{}) (retry bool, err error) {
+	reqCtx, cancel := context.WithTimeout(ctx, c.opts.RequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		// Lỗi mạng hoặc hết timeout của riêng request này.
+		return true, fmt.Errorf("rawg: %w", redactKey(err))
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		if err := json.NewDecoder(resp.Body).Decode(dst); err != nil {
+			return false, fmt.Errorf("rawg: decode response: %w", err)
+		}
+		return false, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return false, ErrNotFound
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	apiErr := &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return true, &retryAfterError{err: apiErr, after: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+	return false, apiErr
+}
+
+// backoff tính thời gian chờ trước lần thử thứ attempt: ưu tiên Retry-After,
+// nếu không thì exponential backoff có jitter.
+func (c *Client) backoff(attempt int, lastErr error) time.Duration {
+	var ra *retryAfterError
+	if errors.As(lastErr, &ra) && ra.after > 0 {
+		if ra.after > c.opts.MaxBackoff {
+			return c.opts.MaxBackoff
+		}
+		return ra.after
+	}
+	d := c.opts.MinBackoff << (attempt - 1)
+	if d <= 0 || d > c.opts.MaxBackoff {
+		d = c.opts.MaxBackoff
+	}
+	// Jitter ±20% để các replica không thử lại cùng lúc.
+	jitter := time.Duration(rand.Int63n(int64(d)/5+1)) - d/10
+	return d + jitter
+}
+
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// redactKey bỏ API key khỏi URL trong lỗi của net/http để không lộ key ra log.
+func redactKey(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if u, perr := url.Parse(urlErr.URL); perr == nil {
+			q := u.Query()
+			if q.Has("key") {
+				q.Set("key", "REDACTED")
+				u.RawQuery = q.Encode()
+			}
+			return &url.Error{Op: urlErr.Op, URL: u.String(), Err: urlErr.Err}
+		}
+	}
+	return err
+}
@@ -0,0 +1,201 @@
+package rawg_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-mvc-demo/rawg"
+	"go-mvc-demo/rawg/rawgtest"
+)
+
+// newClient trỏ vào server giả với các option của test; backoff mặc định
+// ngắn để test chạy nhanh.
+func newClient(s *rawgtest.Server, opts rawg.Options) *rawg.Client {
+	opts.BaseURL = s.URL
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff = time.Millisecond
+	}
+	if opts.MaxBackoff == 0 {
+		opts.MaxBackoff = 10 * time.Millisecond
+	}
+	if opts.RequestsPerSecond == 0 {
+		opts.RequestsPerSecond = -1
+	}
+	return rawg.New(rawgtest.APIKey, opts)
+}
+
+func TestListGames(t *testing.T) {
+	s := rawgtest.New(t)
+	page, err := s.Client().ListGames(context.Background(), rawg.ListOptions{Page: 1, PageSize: 3, Ordering: "-updated"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Results) != 3 || page.Results[0].ID != 3498 || !page.HasNext() {
+		t.Fatalf("page = %+v", page)
+	}
+	if got := page.Results[1].GenreNames(); len(got) != 2 || got[1] != "RPG" {
+		t.Fatalf("genres = %v", got)
+	}
+	reqs := s.Requests()
+	if len(reqs) != 1 || reqs[0] != "/games?ordering=-updated&page=1&page_size=3" {
+		t.Fatalf("requests = %v", reqs)
+	}
+
+	if _, err := s.Client().ListGames(context.Background(), rawg.ListOptions{Page: 99}); !errors.Is(err, rawg.ErrNotFound) {
+		t.Fatalf("page past the end: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRetriesTransientErrors(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			s := rawgtest.New(t)
+			s.FailNext(2, status)
+			if _, err := s.Client().GetGame(context.Background(), 3498); err != nil {
+				t.Fatalf("GetGame after 2 failures: %v", err)
+			}
+			if n := len(s.Requests()); n != 3 {
+				t.Fatalf("%d requests, want 3", n)
+			}
+		})
+	}
+}
+
+func TestGivesUpAfterMaxRetries(t *testing.T) {
+	s := rawgtest.New(t)
+	s.FailNext(10, http.StatusServiceUnavailable)
+	_, err := newClient(s, rawg.Options{MaxRetries: 2}).GetGame(context.Background(), 3498)
+
+	var apiErr *rawg.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("err = %v, want APIError 503", err)
+	}
+	if n := len(s.Requests()); n != 3 {
+		t.Fatalf("%d requests, want 1 + 2 retries", n)
+	}
+}
+
+func TestDoesNotRetryClientErrors(t *testing.T) {
+	s := rawgtest.New(t)
+	s.FailNext(1, http.StatusBadRequest)
+	_, err := s.Client().GetGame(context.Background(), 3498)
+
+	var apiErr *rawg.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("err = %v, want APIError 400", err)
+	}
+	if n := len(s.Requests()); n != 1 {
+		t.Fatalf("%d requests, want 1", n)
+	}
+
+	_, err = rawg.New("wrong-key", rawg.Options{BaseURL: s.URL, RequestsPerSecond: -1}).GetGame(context.Background(), 3498)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("wrong key: err = %v, want APIError 401", err)
+	}
+}
+
+func TestHonorsRetryAfter(t *testing.T) {
+	s := rawgtest.New(t)
+	s.FailNextWithRetryAfter(1, http.StatusTooManyRequests, "1")
+	c := newClient(s, rawg.Options{MaxBackoff: 5 * time.Second})
+
+	start := time.Now()
+	if _, err := c.GetGame(context.Background(), 3498); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("retried after %v, want at least the 1s Retry-After", elapsed)
+	}
+}
+
+func TestRetryAfterIsCappedByMaxBackoff(t *testing.T) {
+	s := rawgtest.New(t)
+	s.FailNextWithRetryAfter(1, http.StatusServiceUnavailable, "120")
+	c := newClient(s, rawg.Options{MaxBackoff: 20 * time.Millisecond})
+
+	start := time.Now()
+	if _, err := c.GetGame(context.Background(), 3498); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("retried after %v, want Retry-After capped at MaxBackoff", elapsed)
+	}
+}
+
+func TestRetryWaitStopsOnContextCancel(t *testing.T) {
+	s := rawgtest.New(t)
+	s.FailNextWithRetryAfter(1, http.StatusTooManyRequests, "30")
+	c := newClient(s, rawg.Options{MaxBackoff: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetGame(ctx, 3498); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestRateLimitSpacesRequests(t *testing.T) {
+	s := rawgtest.New(t)
+	c := newClient(s, rawg.Options{RequestsPerSecond: 20})
+
+	const calls = 6
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.ListGenres(context.Background(), rawg.ListOptions{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 20 req/s: 6 request cách nhau ít nhất 5 * 50ms.
+	if elapsed := time.Since(start); elapsed < 240*time.Millisecond {
+		t.Fatalf("%d requests took %v, want at least 250ms", calls, elapsed)
+	}
+	if n := len(s.Requests()); n != calls {
+		t.Fatalf("%d requests, want %d", n, calls)
+	}
+}
+
+func TestRateLimitWaitStopsOnContextCancel(t *testing.T) {
+	s := rawgtest.New(t)
+	c := newClient(s, rawg.Options{RequestsPerSecond: 1})
+	if _, err := c.ListPlatforms(context.Background(), rawg.ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.ListPlatforms(ctx, rawg.ListOptions{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if n := len(s.Requests()); n != 1 {
+		t.Fatalf("%d requests, want the second one to wait", n)
+	}
+}
+
+func TestNetworkErrorRedactsAPIKey(t *testing.T) {
+	s := rawgtest.New(t)
+	c := newClient(s, rawg.Options{MaxRetries: 1})
+	s.Close()
+
+	_, err := c.GetGame(context.Background(), 3498)
+	if err == nil {
+		t.Fatal("GetGame against a closed server succeeded")
+	}
+	if strings.Contains(err.Error(), rawgtest.APIKey) {
+		t.Fatalf("error leaks the API key: %v", err)
+	}
+	if !strings.Contains(err.Error(), "key=REDACTED") {
+		t.Fatalf("error = %v, want the URL with key=REDACTED", err)
+	}
+}
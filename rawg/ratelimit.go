@@ -0,0 +1,50 @@
+package rawg
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// limiter giãn các request cách nhau tối thiểu interval. Request vượt nhịp
+// phải chờ tới lượt của mình (hàng đợi theo thời điểm đặt chỗ).
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newLimiter(perSecond float64) *limiter {
+	if perSecond <= 0 {
+		return &limiter{}
+	}
+	return &limiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// wait chờ tới lượt gọi tiếp theo hoặc tới khi ctx bị huỷ.
+func (l *limiter) wait(ctx context.Context) error {
+	if l.interval == 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,156 @@
+// Package rawgtest cung cấp RAWG API giả chạy trên httptest để test importer
+// mà không cần mạng. Dữ liệu trả về lấy từ các fixture ghi sẵn trong testdata:
+//
+//	games_page_<n>.json   GET /games?page=<n> (trang không có file trả 404)
+//	game_<id>.json        GET /games/<id>
+//	game_<id>_<sub>.json  GET /games/<id>/screenshots, /games/<id>/movies
+//	genres.json           GET /genres
+//	platforms.json        GET /platforms
+package rawgtest
+
+import (
+	"embed"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-mvc-demo/rawg"
+)
+
+// APIKey là key mà server giả chấp nhận.
+const APIKey = "test-key"
+
+//go:embed testdata/*.json
+var fixtures embed.FS
+
+// Server là RAWG API giả.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	failures []failure
+	requests []string
+}
+
+type failure struct {
+	status     int
+	retryAfter string
+}
+
+// New khởi động server giả và đóng nó khi test kết thúc.
+func New(tb testing.TB) *Server {
+	tb.Helper()
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	tb.Cleanup(s.Close)
+	return s
+}
+
+// Client trả về client trỏ vào server giả, không giới hạn tốc độ và backoff
+// ngắn để test chạy nhanh.
+func (s *Server) Client() *rawg.Client {
+	return rawg.New(APIKey, rawg.Options{
+		BaseURL:           s.URL,
+		RequestTimeout:    2 * time.Second,
+		MinBackoff:        time.Millisecond,
+		MaxBackoff:        10 * time.Millisecond,
+		RequestsPerSecond: -1,
+	})
+}
+
+// FailNext làm n request tiếp theo trả về status (ví dụ 429 hoặc 503).
+func (s *Server) FailNext(n, status int) {
+	s.FailNextWithRetryAfter(n, status, "")
+}
+
+// FailNextWithRetryAfter giống FailNext nhưng kèm header Retry-After.
+func (s *Server) FailNextWithRetryAfter(n, status int, retryAfter string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, failure{status: status, retryAfter: retryAfter})
+	}
+}
+
+// Requests trả về path kèm query (đã bỏ key) của các request đã nhận.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	key := q.Get("key")
+	q.Del("key")
+
+	s.mu.Lock()
+	s.requests = append(s.requests, r.URL.Path+"?"+q.Encode())
+	var fail *failure
+	if len(s.failures) > 0 {
+		fail = &s.failures[0]
+		s.failures = s.failures[1:]
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if key != APIKey {
+		writeError(w, http.StatusUnauthorized, "The key parameter is not provided")
+		return
+	}
+	if fail != nil {
+		if fail.retryAfter != "" {
+			w.Header().Set("Retry-After", fail.retryAfter)
+		}
+		writeError(w, fail.status, http.StatusText(fail.status))
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var name string
+	switch path := strings.TrimSuffix(r.URL.Path, "/"); {
+	case path == "/games":
+		page := 1
+		if v := q.Get("page"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				writeError(w, http.StatusNotFound, "Invalid page.")
+				return
+			}
+			page = n
+		}
+		name = fmt.Sprintf("games_page_%d.json", page)
+	case strings.HasPrefix(path, "/games/"):
+		parts := strings.Split(strings.TrimPrefix(path, "/games/"), "/")
+		switch {
+		case len(parts) == 1:
+			name = "game_" + parts[0] + ".json"
+		case len(parts) == 2 && (parts[1] == "screenshots" || parts[1] == "movies"):
+			name = "game_" + parts[0] + "_" + parts[1] + ".json"
+		}
+	case path == "/genres":
+		name = "genres.json"
+	case path == "/platforms":
+		name = "platforms.json"
+	}
+
+	data, err := fixtures.ReadFile("testdata/" + name)
+	if name == "" || err != nil {
+		writeError(w, http.StatusNotFound, "Not found.")
+		return
+	}
+	w.Write(data)
+}
+
+func writeError(w http.ResponseWriter, status int, detail string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "{\"detail\":%q}", detail)
+}
@@ -0,0 +1,123 @@
+package rawg
+
+// Genre là thể loại game.
+type Genre struct {
+	ID              int    `json:"id"`
+	Name            string `json:"name"`
+	Slug            string `json:"slug"`
+	GamesCount      int    `json:"games_count,omitempty"`
+	ImageBackground string `json:"image_background,omitempty"`
+}
+
+// Company là nhà phát triển hoặc nhà phát hành.
+type Company struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// Platform là một nền tảng (PC, PlayStation 5...).
+type Platform struct {
+	ID              int    `json:"id"`
+	Name            string `json:"name"`
+	Slug            string `json:"slug"`
+	GamesCount      int    `json:"games_count,omitempty"`
+	ImageBackground string `json:"image_background,omitempty"`
+}
+
+// GamePlatform là phần tử của field platforms trong game.
+type GamePlatform struct {
+	Platform     Platform `json:"platform"`
+	ReleasedAt   string   `json:"released_at,omitempty"`
+	Requirements *struct {
+		Minimum     string `json:"minimum,omitempty"`
+		Recommended string `json:"recommended,omitempty"`
+	} `json:"requirements,omitempty"`
+}
+
+// Game là một game trong kết quả danh sách /games. Các slice có thể nil khi
+// RAWG trả về null.
+type Game struct {
+	ID              int            `json:"id"`
+	Slug            string         `json:"slug"`
+	Name            string         `json:"name"`
+	Released        string         `json:"released"`
+	TBA             bool           `json:"tba"`
+	BackgroundImage string         `json:"background_image"`
+	Rating          float64        `json:"rating"`
+	RatingTop       int            `json:"rating_top"`
+	RatingsCount    int            `json:"ratings_count"`
+	Metacritic      int            `json:"metacritic"`
+	Playtime        int            `json:"playtime"`
+	Updated         string         `json:"updated"`
+	Genres          []Genre        `json:"genres"`
+	Platforms       []GamePlatform `json:"platforms"`
+}
+
+// GenreNames trả về tên các thể loại của game.
+func (g Game) GenreNames() []string {
+	names := make([]string, 0, len(g.Genres))
+	for _, genre := range g.Genres {
+		names = append(names, genre.Name)
+	}
+	return names
+}
+
+// PlatformNames trả về tên các nền tảng của game.
+func (g Game) PlatformNames() []string {
+	names := make([]string, 0, len(g.Platforms))
+	for _, p := range g.Platforms {
+		names = append(names, p.Platform.Name)
+	}
+	return names
+}
+
+// GameDetail là kết quả của /games/{id}.
+type GameDetail struct {
+	Game
+	NameOriginal         string    `json:"name_original"`
+	Description          string    `json:"description"`
+	DescriptionRaw       string    `json:"description_raw"`
+	Website              string    `json:"website"`
+	BackgroundImageExtra string    `json:"background_image_additional"`
+	ScreenshotsCount     int       `json:"screenshots_count"`
+	MoviesCount          int       `json:"movies_count"`
+	Developers           []Company `json:"developers"`
+	Publishers           []Company `json:"publishers"`
+	ESRBRating           *struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+		Slug string `json:"slug"`
+	} `json:"esrb_rating"`
+}
+
+// Page là một trang kết quả phân trang của RAWG.
+type Page[T any] struct {
+	Count    int     `json:"count"`
+	Next     *string `json:"next"`
+	Previous *string `json:"previous"`
+	Results  []T     `json:"results"`
+}
+
+// HasNext cho biết còn trang sau hay không.
+func (p Page[T]) HasNext() bool {
+	return p.Next != nil && *p.Next != ""
+}
+
+// Screenshot là một ảnh chụp màn hình của game (/games/{id}/screenshots).
+type Screenshot struct {
+	ID        int    `json:"id"`
+	Image     string `json:"image"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	IsDeleted bool   `json:"is_deleted"`
+}
+
+// Movie là một trailer của game (/games/{id}/movies). Data chứa URL video
+// theo chất lượng: "480" và "max".
+type Movie struct {
+	ID      int               `json:"id"`
+	Name    string            `json:"name"`
+	Preview string            `json:"preview"`
+	Data    map[string]string `json:"data"`
+}
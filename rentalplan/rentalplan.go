@@ -0,0 +1,172 @@
+// Package rentalplan xác định các gói thuê áp dụng cho một game và giá thuê.
+//
+// Gói được cấu hình trong collection rental_plans ở ba phạm vi: toàn cục,
+// theo thể loại và theo game. Với mỗi mã gói, cấu hình ở phạm vi hẹp hơn sẽ
+// ghi đè phạm vi rộng hơn; một override có active=false sẽ ẩn gói đó.
+// Gói toàn cục trong DB ghi đè gói cùng mã trong Defaults; mã không có trong
+// DB vẫn dùng Defaults.
+package rentalplan
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"go-mvc-demo/config"
+	"go-mvc-demo/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// DefaultPlan là gói dùng khi request thuê không chỉ định plan.
+const DefaultPlan = "3d"
+
+var ErrPlanNotFound = errors.New("rental plan not available")
+
+// Defaults là các gói toàn cục mặc định. Gói 3d giữ đúng giá cũ (10% giá game).
+var Defaults = []models.RentalPlan{
+	{Code: "1d", Name: "1 ngày", DurationDays: 1, PricePercent: 5, MinPrice: 1, Scope: models.PlanScopeGlobal, Active: true},
+	{Code: "3d", Name: "3 ngày", DurationDays: 3, PricePercent: 10, Scope: models.PlanScopeGlobal, Active: true},
+	{Code: "7d", Name: "7 ngày", DurationDays: 7, PricePercent: 18, MinPrice: 1, Scope: models.PlanScopeGlobal, Active: true},
+	{Code: "30d", Name: "30 ngày", DurationDays: 30, PricePercent: 50, MinPrice: 1, Scope: models.PlanScopeGlobal, Active: true},
+}
+
+// Quote là một gói thuê đã áp dụng cho một game cụ thể.
+type Quote struct {
+	Code         string `json:"code"`
+	Name         string `json:"name"`
+	DurationDays int    `json:"duration_days"`
+	Price        int    `json:"price"`
+	Source       string `json:"source"` // global, genre:<tên>, game
+}
+
+// Price tính giá thuê của plan cho game.
+func Price(plan models.RentalPlan, game models.Game) int {
+	price := game.Price * plan.PricePercent / 100
+	if price < plan.MinPrice {
+		price = plan.MinPrice
+	}
+	return price
+}
+
+func scopeRank(plan models.RentalPlan) int {
+	switch plan.Scope {
+	case models.PlanScopeGame:
+		return 2
+	case models.PlanScopeGenre:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func source(plan models.RentalPlan) string {
+	switch plan.Scope {
+	case models.PlanScopeGame:
+		return "game"
+	case models.PlanScopeGenre:
+		return "genre:" + plan.Genre
+	default:
+		return "global"
+	}
+}
+
+// Resolve trả về các gói thuê khả dụng cho game, sắp xếp theo thời lượng.
+func Resolve(ctx context.Context, game models.Game) ([]Quote, error) {
+	genres := game.Genres
+	if genres == nil {
+		genres = []string{}
+	}
+	cursor, err := config.DB.Collection("rental_plans").Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"scope": models.PlanScopeGlobal},
+		bson.M{"scope": models.PlanScopeGenre, "genre": bson.M{"$in": genres}},
+		bson.M{"scope": models.PlanScopeGame, "game_id": game.ID},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	var stored []models.RentalPlan
+	if err := cursor.All(ctx, &stored); err != nil {
+		return nil, err
+	}
+	return merge(stored, game), nil
+}
+
+// merge chọn cấu hình áp dụng cho game từ Defaults và các gói đọc từ DB.
+func merge(stored []models.RentalPlan, game models.Game) []Quote {
+	chosen := map[string]models.RentalPlan{}
+	for _, p := range Defaults {
+		chosen[p.Code] = p
+	}
+	for _, p := range stored {
+		if p.Scope == models.PlanScopeGlobal {
+			chosen[p.Code] = p
+		}
+	}
+
+	// Chọn cấu hình ở phạm vi hẹp nhất cho mỗi mã gói. Khi nhiều thể loại
+	// cùng override một gói, lấy giá thấp nhất để có lợi cho người thuê.
+	for _, p := range stored {
+		if p.Scope == models.PlanScopeGlobal {
+			continue
+		}
+		current, ok := chosen[p.Code]
+		switch {
+		case !ok, scopeRank(p) > scopeRank(current):
+			chosen[p.Code] = p
+		case scopeRank(p) == scopeRank(current) && p.Active && Price(p, game) < Price(current, game):
+			chosen[p.Code] = p
+		}
+	}
+
+	quotes := []Quote{}
+	for _, p := range chosen {
+		if !p.Active || p.DurationDays <= 0 {
+			continue
+		}
+		quotes = append(quotes, Quote{
+			Code:         p.Code,
+			Name:         p.Name,
+			DurationDays: p.DurationDays,
+			Price:        Price(p, game),
+			Source:       source(p),
+		})
+	}
+	sort.Slice(quotes, func(i, j int) bool {
+		if quotes[i].DurationDays != quotes[j].DurationDays {
+			return quotes[i].DurationDays < quotes[j].DurationDays
+		}
+		return quotes[i].Code < quotes[j].Code
+	})
+	return quotes
+}
+
+// Find trả về gói code đã áp dụng cho game.
+func Find(ctx context.Context, game models.Game, code string) (Quote, error) {
+	quotes, err := Resolve(ctx, game)
+	if err != nil {
+		return Quote{}, err
+	}
+	for _, q := range quotes {
+		if q.Code == code {
+			return q, nil
+		}
+	}
+	return Quote{}, ErrPlanNotFound
+}
+
+// ProratedRefund trả về phần tiền tương ứng với thời gian thuê còn lại
+// (từ now tới end) trên tổng thời gian thuê [start, end].
+func ProratedRefund(paid int, start, end, now time.Time) int {
+	total := end.Sub(start)
+	remaining := end.Sub(now)
+	if total <= 0 || remaining <= 0 {
+		return 0
+	}
+	if remaining > total {
+		remaining = total
+	}
+	return int(math.Floor(float64(paid) * float64(remaining) / float64(total)))
+}
@@ -0,0 +1,131 @@
+package rentalplan
+
+import (
+	"testing"
+	"time"
+
+	"go-mvc-demo/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// codes đánh chỉ mục quotes theo mã gói.
+func codes(quotes []Quote) map[string]Quote {
+	m := map[string]Quote{}
+	for _, q := range quotes {
+		m[q.Code] = q
+	}
+	return m
+}
+
+func TestMergeWithoutStoredPlansUsesDefaults(t *testing.T) {
+	quotes := merge(nil, models.Game{Price: 1000})
+	if len(quotes) != len(Defaults) {
+		t.Fatalf("got %d quotes, want %d defaults", len(quotes), len(Defaults))
+	}
+	for i, want := range []string{"1d", "3d", "7d", "30d"} {
+		if quotes[i].Code != want || quotes[i].Source != "global" {
+			t.Fatalf("quotes[%d] = %+v, want default %s", i, quotes[i], want)
+		}
+	}
+	if q := codes(quotes)["3d"]; q.Price != 100 {
+		t.Fatalf("3d price = %d, want 10%% of 1000", q.Price)
+	}
+}
+
+func TestMergeStoredGlobalOverridesDefaultByCode(t *testing.T) {
+	stored := []models.RentalPlan{
+		// Chỉ đổi giá gói 3d; các gói mặc định khác vẫn phải còn.
+		{Code: "3d", Name: "3 ngày", DurationDays: 3, PricePercent: 20, Scope: models.PlanScopeGlobal, Active: true},
+		// Tắt gói 30d.
+		{Code: "30d", Name: "30 ngày", DurationDays: 30, PricePercent: 50, Scope: models.PlanScopeGlobal, Active: false},
+		// Gói mới không có trong Defaults.
+		{Code: "14d", Name: "14 ngày", DurationDays: 14, PricePercent: 30, Scope: models.PlanScopeGlobal, Active: true},
+	}
+	quotes := merge(stored, models.Game{Price: 1000})
+	got := codes(quotes)
+
+	if len(quotes) != 4 {
+		t.Fatalf("quotes = %+v, want 1d, 3d, 7d, 14d", quotes)
+	}
+	if got["3d"].Price != 200 {
+		t.Fatalf("3d price = %d, want stored 20%% over the default 10%%", got["3d"].Price)
+	}
+	if _, ok := got["30d"]; ok {
+		t.Fatal("inactive stored global did not hide the default 30d plan")
+	}
+	if got["1d"].Price != 50 || got["7d"].Price != 180 || got["14d"].Price != 300 {
+		t.Fatalf("quotes = %+v", quotes)
+	}
+	// Gói giữ nguyên thứ tự theo thời lượng.
+	if quotes[2].Code != "7d" || quotes[3].Code != "14d" {
+		t.Fatalf("order = %+v", quotes)
+	}
+}
+
+func TestMergeStoredGlobalWinsEvenWhenPricier(t *testing.T) {
+	stored := []models.RentalPlan{
+		{Code: "1d", Name: "1 ngày", DurationDays: 1, PricePercent: 8, Scope: models.PlanScopeGlobal, Active: true},
+	}
+	if q := codes(merge(stored, models.Game{Price: 1000}))["1d"]; q.Price != 80 {
+		t.Fatalf("1d price = %d, want the stored 80 rather than the cheaper default", q.Price)
+	}
+}
+
+func TestMergeNarrowerScopesOverrideGlobal(t *testing.T) {
+	gameID := primitive.NewObjectID()
+	game := models.Game{ID: gameID, Price: 1000, Genres: []string{"Indie", "RPG"}}
+	stored := []models.RentalPlan{
+		{Code: "3d", DurationDays: 3, PricePercent: 20, Scope: models.PlanScopeGlobal, Active: true},
+		{Code: "3d", DurationDays: 3, PricePercent: 8, Scope: models.PlanScopeGenre, Genre: "Indie", Active: true},
+		{Code: "3d", DurationDays: 3, PricePercent: 6, Scope: models.PlanScopeGenre, Genre: "RPG", Active: true},
+		{Code: "7d", DurationDays: 7, PricePercent: 12, Scope: models.PlanScopeGenre, Genre: "RPG", Active: true},
+		{Code: "7d", DurationDays: 7, PricePercent: 15, Scope: models.PlanScopeGame, GameID: &gameID, Active: true},
+		{Code: "1d", DurationDays: 1, PricePercent: 5, Scope: models.PlanScopeGame, GameID: &gameID, Active: false},
+	}
+	got := codes(merge(stored, game))
+
+	if q := got["3d"]; q.Price != 60 || q.Source != "genre:RPG" {
+		t.Fatalf("3d = %+v, want cheapest genre override", q)
+	}
+	if q := got["7d"]; q.Price != 150 || q.Source != "game" {
+		t.Fatalf("7d = %+v, want game override", q)
+	}
+	if _, ok := got["1d"]; ok {
+		t.Fatal("inactive game override did not hide 1d")
+	}
+	if q := got["30d"]; q.Source != "global" || q.Price != 500 {
+		t.Fatalf("30d = %+v, want default", q)
+	}
+}
+
+func TestPriceMinimum(t *testing.T) {
+	plan := models.RentalPlan{PricePercent: 5, MinPrice: 3}
+	if got := Price(plan, models.Game{Price: 20}); got != 3 {
+		t.Fatalf("Price = %d, want MinPrice 3", got)
+	}
+	if got := Price(plan, models.Game{Price: 1000}); got != 50 {
+		t.Fatalf("Price = %d, want 50", got)
+	}
+}
+
+func TestProratedRefund(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(4 * 24 * time.Hour)
+	tests := []struct {
+		now  time.Time
+		want int
+	}{
+		{start.Add(-time.Hour), 100},
+		{start, 100},
+		{start.Add(24 * time.Hour), 75},
+		{start.Add(3*24*time.Hour + time.Hour), 23},
+		{end, 0},
+		{end.Add(time.Hour), 0},
+	}
+	for _, tt := range tests {
+		if got := ProratedRefund(100, start, end, tt.now); got != tt.want {
+			t.Errorf("ProratedRefund at %v = %d, want %d", tt.now.Sub(start), got, tt.want)
+		}
+	}
+}
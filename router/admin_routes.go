@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"go-mvc-demo/auth"
+	controllers "go-mvc-demo/controller"
+	"go-mvc-demo/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AdminRoutes(r *gin.Engine) {
+	admin := r.Group("/admin", middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	plansWrite := middleware.RequirePermission(auth.PermRentalPlansWrite)
+	usersAdmin := middleware.RequirePermission(auth.PermUsersAdmin)
+	gamesWrite := middleware.RequirePermission(auth.PermGamesWrite)
+	pricingWrite := middleware.RequirePermission(auth.PermPricingWrite)
+	{
+		admin.GET("/rental-plans", plansWrite, controllers.ListRentalPlans)
+		admin.PUT("/rental-plans", plansWrite, controllers.UpsertRentalPlan)
+		admin.DELETE("/rental-plans/:id", plansWrite, controllers.DeleteRentalPlan)
+
+		admin.POST("/users/:id/unlock", usersAdmin, controllers.UnlockUser)
+		admin.GET("/users/:id/login-history", usersAdmin, controllers.GetUserLoginHistory)
+
+		admin.POST("/imports", gamesWrite, controllers.StartImport)
+		admin.GET("/imports", gamesWrite, controllers.ListImports)
+		admin.GET("/imports/:id", gamesWrite, controllers.GetImport)
+		admin.GET("/imports/:id/changes", gamesWrite, controllers.GetImportChanges)
+		admin.POST("/imports/:id/cancel", gamesWrite, controllers.CancelImport)
+		admin.POST("/imports/:id/resume", gamesWrite, controllers.ResumeImport)
+		admin.POST("/games/:id/enrich", gamesWrite, controllers.EnrichGame)
+
+		admin.POST("/pricing/preview", pricingWrite, controllers.PreviewPricing)
+		admin.POST("/pricing/apply", pricingWrite, controllers.ApplyPricing)
+		admin.GET("/games/:id/price-history", pricingWrite, controllers.GetPriceHistory)
+	}
+}
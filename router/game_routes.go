@@ -1,7 +1,9 @@
 package routes
 
 import (
+	"go-mvc-demo/auth"
 	controllers "go-mvc-demo/controller"
+	"go-mvc-demo/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,15 +16,18 @@ func GameRoutes(r *gin.Engine) {
 	// 	protected.GET("/my-rentals", controllers.GetRentedGames)
 	// }
 
+	gamesWrite := []gin.HandlerFunc{middleware.AuthMiddleware(), middleware.RequirePermission(auth.PermGamesWrite)}
+
 	game := r.Group("/games")
 	{
-		r.GET("/fetch-games", controllers.FetchGamesByPage)
-		r.GET("/fetch-games100", controllers.FetchAndSaveGames100)
+		r.GET("/fetch-games", middleware.OptionalAuthMiddleware(), controllers.FetchGamesByPage)
+		r.GET("/fetch-games100", append(gamesWrite, controllers.FetchAndSaveGames100)...)
 
-		game.POST("/", controllers.CreateGame)
+		game.POST("/", append(gamesWrite, controllers.CreateGame)...)
 		game.GET("/", controllers.GetGames)
-		game.GET("/:id", controllers.GetGameByID)
-		game.DELETE("/:id", controllers.DeleteGame)
-		game.GET("/fetch", controllers.FetchAndSaveGames)
+		game.GET("/:id", middleware.OptionalAuthMiddleware(), controllers.GetGameByID)
+		game.GET("/:id/rental-plans", controllers.GetGameRentalPlans)
+		game.DELETE("/:id", append(gamesWrite, controllers.DeleteGame)...)
+		game.GET("/fetch", append(gamesWrite, controllers.FetchAndSaveGames)...)
 	}
 }
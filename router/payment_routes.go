@@ -0,0 +1,17 @@
+package routes
+
+import (
+	controllers "go-mvc-demo/controller"
+	"go-mvc-demo/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PaymentRoutes đăng ký webhook thanh toán. Route giả lập thanh toán chỉ có
+// khi fake gateway được bật (APP_ENV development/test).
+func PaymentRoutes(r *gin.Engine, fakeGateway bool) {
+	r.POST("/webhooks/payments", controllers.PaymentWebhook)
+	if fakeGateway {
+		r.POST("/payments/fake/:reference/:outcome", middleware.AuthMiddleware(), controllers.SimulateFakePayment)
+	}
+}
@@ -1,17 +1,28 @@
 package routes
 
 import (
+	authz "go-mvc-demo/auth"
+	"go-mvc-demo/config"
 	controllers "go-mvc-demo/controller"
 	"go-mvc-demo/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
-func TransactionRoutes(r *gin.Engine) {
+func TransactionRoutes(r *gin.Engine, idempotency config.IdempotencyConfig) {
 	auth := r.Group("/", middleware.AuthMiddleware())
-	auth.POST("/buy/:id", controllers.BuyGame)
-	auth.POST("/rent/:id", controllers.RentGame)
+	idempotent := middleware.Idempotency(idempotency)
+	// API key chỉ được di chuyển tiền khi được cấp scope wallet:spend.
+	spend := middleware.RequirePermission(authz.PermWalletSpend)
+
+	auth.POST("/buy/:id", spend, idempotent, controllers.BuyGame)
+	auth.POST("/rent/:id", spend, idempotent, controllers.RentGame)
 	auth.GET("/rental/check/:id", controllers.CheckActiveRental)
-	auth.POST("/recharge", controllers.RechargeCoin)
+	auth.POST("/purchases/:id/refund", spend, idempotent, controllers.RefundPurchase)
+	auth.POST("/rentals/:id/return", spend, idempotent, controllers.ReturnRental)
+	auth.POST("/rentals/:id/extend", spend, idempotent, controllers.ExtendRental)
+	auth.POST("/recharge", spend, idempotent, controllers.RechargeCoin)
 	auth.GET("/recharge-history", controllers.GetRechargeHistory)
+	auth.GET("/wallet/ledger", controllers.GetLedger)
+	auth.POST("/wallet/reconcile/:id", middleware.RequirePermission(authz.PermWalletAdjust), controllers.ReconcileWallet)
 }
@@ -1,20 +1,24 @@
 package routes
 
 import (
+	"go-mvc-demo/auth"
 	controllers "go-mvc-demo/controller"
+	"go-mvc-demo/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 func UserRoutes(r *gin.Engine) {
-	user := r.Group("/users")
+	user := r.Group("/users", middleware.AuthMiddleware())
+	usersAdmin := middleware.RequirePermission(auth.PermUsersAdmin)
 	{
-		user.GET("/", controllers.GetUsers)
+		user.GET("/", usersAdmin, controllers.GetUsers)
+		// GetUserByID và UpdateUser tự kiểm tra: user thường chỉ được truy cập chính mình.
 		user.GET("/:id", controllers.GetUserByID)
-		user.POST("/", controllers.CreateUser)
+		user.POST("/", usersAdmin, controllers.CreateUser)
 		user.PUT("/:id", controllers.UpdateUser)
-		user.DELETE("/:id", controllers.DeleteUser)
-		user.PUT("/:id/promote", controllers.PromoteUserToAdmin)
+		user.DELETE("/:id", usersAdmin, controllers.DeleteUser)
+		user.PUT("/:id/promote", usersAdmin, controllers.PromoteUserToAdmin)
 
 	}
 }
@@ -0,0 +1,162 @@
+// Package sanitize làm sạch HTML lấy từ nguồn ngoài (mô tả game của RAWG)
+// trước khi lưu và trả cho client.
+package sanitize
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedTags là các thẻ được giữ lại; thẻ khác bị bỏ nhưng nội dung bên
+// trong vẫn giữ.
+var allowedTags = map[atom.Atom]bool{
+	atom.P: true, atom.Br: true, atom.Ul: true, atom.Ol: true, atom.Li: true,
+	atom.Strong: true, atom.B: true, atom.Em: true, atom.I: true, atom.U: true,
+	atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Blockquote: true, atom.A: true,
+}
+
+// droppedTags bị bỏ cùng toàn bộ nội dung.
+var droppedTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Iframe: true, atom.Object: true,
+	atom.Embed: true, atom.Noscript: true, atom.Template: true, atom.Svg: true,
+	atom.Math: true, atom.Form: true, atom.Textarea: true, atom.Select: true,
+	atom.Head: true, atom.Title: true,
+}
+
+// blockTags là các thẻ khối, thành dòng riêng khi chuyển sang plain text.
+var blockTags = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Ul: true, atom.Ol: true, atom.Li: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true,
+	atom.H6: true, atom.Blockquote: true, atom.Table: true, atom.Tr: true,
+}
+
+func parse(s string) ([]*html.Node, error) {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	return html.ParseFragment(strings.NewReader(s), body)
+}
+
+// HTML chỉ giữ các thẻ định dạng an toàn trong allowedTags, bỏ mọi thuộc
+// tính trừ href http(s) của thẻ a. Link được thêm rel="nofollow noopener
+// noreferrer" và target="_blank".
+func HTML(s string) string {
+	nodes, err := parse(s)
+	if err != nil {
+		return html.EscapeString(Text(s))
+	}
+	var b strings.Builder
+	for _, n := range nodes {
+		writeHTML(&b, n)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func writeHTML(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		// Comment, doctype...
+		return
+	}
+	if droppedTags[n.DataAtom] {
+		return
+	}
+
+	keep := allowedTags[n.DataAtom]
+	if keep {
+		b.WriteByte('<')
+		b.WriteString(n.DataAtom.String())
+		if n.DataAtom == atom.A {
+			if href := safeURL(attr(n, "href")); href != "" {
+				b.WriteString(` href="` + html.EscapeString(href) + `" rel="nofollow noopener noreferrer" target="_blank"`)
+			}
+		}
+		b.WriteByte('>')
+		if n.DataAtom == atom.Br {
+			return
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeHTML(b, c)
+	}
+	if keep {
+		b.WriteString("</" + n.DataAtom.String() + ">")
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// safeURL chỉ chấp nhận URL tuyệt đối http/https.
+func safeURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return u.String()
+}
+
+// Text chuyển HTML thành plain text: bỏ thẻ, giải mã entity, mỗi khối (p,
+// li, h*...) và br thành một dòng, gộp khoảng trắng thừa.
+func Text(s string) string {
+	nodes, err := parse(s)
+	if err != nil {
+		return strings.TrimSpace(s)
+	}
+	var b strings.Builder
+	for _, n := range nodes {
+		writeText(&b, n)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func writeText(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+	if droppedTags[n.DataAtom] {
+		return
+	}
+	if n.DataAtom == atom.Br {
+		b.WriteByte('\n')
+		return
+	}
+	if blockTags[n.DataAtom] {
+		b.WriteByte('\n')
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeText(b, c)
+	}
+	if blockTags[n.DataAtom] {
+		b.WriteByte('\n')
+	}
+}
+
+// URL trả về raw nếu là URL tuyệt đối http/https, ngược lại trả về chuỗi rỗng.
+func URL(raw string) string {
+	return safeURL(raw)
+}
@@ -0,0 +1,88 @@
+package sanitize
+
+import "testing"
+
+const linkAttrs = ` rel="nofollow noopener noreferrer" target="_blank"`
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain formatting kept", "<p>Hello <strong>world</strong></p>", "<p>Hello <strong>world</strong></p>"},
+		{"script dropped with content", `<p>Hi</p><script>alert("x")</script>`, "<p>Hi</p>"},
+		{"uppercase script", `<SCRIPT>alert(1)</SCRIPT><p>ok</p>`, "<p>ok</p>"},
+		{"style dropped with content", "<style>p{color:red}</style><p>ok</p>", "<p>ok</p>"},
+		{"iframe dropped", `<iframe src="https://evil.test"></iframe>text`, "text"},
+		{"javascript href removed", `<a href="javascript:alert(1)">click</a>`, "<a>click</a>"},
+		{"javascript href with spaces and case", `<a href="  JaVaScRiPt:alert(1)">x</a>`, "<a>x</a>"},
+		{"data href removed", `<a href="data:text/html;base64,PHNjcmlwdD4=">x</a>`, "<a>x</a>"},
+		{"relative href removed", `<a href="/admin">x</a>`, "<a>x</a>"},
+		{"https href kept", `<a href="https://rawg.io/games/1">rawg</a>`, `<a href="https://rawg.io/games/1"` + linkAttrs + `>rawg</a>`},
+		{"href is escaped", `<a href="https://x.test/?a=1&b=&quot;2">x</a>`, `<a href="https://x.test/?a=1&amp;b=&#34;2"` + linkAttrs + `>x</a>`},
+		{"event attributes stripped", `<p onclick="alert(1)" style="x" class="y">hi</p>`, "<p>hi</p>"},
+		{"event attribute on link stripped", `<a href="https://ok.test" onmouseover="alert(1)">x</a>`, `<a href="https://ok.test"` + linkAttrs + `>x</a>`},
+		{"img dropped entirely", `<img src=x onerror="alert(1)">after`, "after"},
+		{"unknown tag unwrapped", "<div><span>text</span></div>", "text"},
+		{"nested dropped tags", "<p>a<object><embed><script>evil()</script>inner</embed></object>b</p>", "<p>ab</p>"},
+		{"dropped tag inside unknown tag", "<div><svg><script>evil()</script><text>svg</text></svg>kept</div>", "kept"},
+		{"comments removed", "<p>a<!-- <script>evil()</script> -->b</p>", "<p>ab</p>"},
+		{"entities decoded then re-escaped", "<p>Tom &amp; Jerry &lt;3 &quot;hi&quot; &#39;x&#39;</p>", "<p>Tom &amp; Jerry &lt;3 &#34;hi&#34; &#39;x&#39;</p>"},
+		{"escaped markup stays text", "&lt;script&gt;alert(1)&lt;/script&gt;", "&lt;script&gt;alert(1)&lt;/script&gt;"},
+		{"br kept without closing tag", "a<br>b<br/>c", "a<br>b<br>c"},
+		{"unclosed tags closed", "<ul><li>one<li>two", "<ul><li>one</li><li>two</li></ul>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTML(tt.in); got != tt.want {
+				t.Errorf("HTML(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"paragraphs become lines", "<p>One</p><p>Two</p>", "One\nTwo"},
+		{"divs split text", "a<div>b</div>c", "a\nb\nc"},
+		{"br becomes newline", "line 1<br>line 2", "line 1\nline 2"},
+		{"whitespace collapsed", "<p>  lots   of \t space </p>", "lots of space"},
+		{"source newlines kept, blank lines dropped", "<p>one\n\n\ntwo</p>", "one\ntwo"},
+		{"script content dropped", "<p>Hi</p><script>var secret = 1</script>", "Hi"},
+		{"nested dropped tags", "<div>a<noscript><style>x{}</style>no</noscript>b</div>", "ab"},
+		{"entities decoded", "<p>Tom &amp; Jerry &lt;3 &eacute;&#233;</p>", "Tom & Jerry <3 éé"},
+		{"list items", "<ul><li>one</li><li>two</li></ul>", "one\ntwo"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Text(tt.in); got != tt.want {
+				t.Errorf("Text(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestURL(t *testing.T) {
+	tests := map[string]string{
+		"https://media.rawg.io/a.jpg": "https://media.rawg.io/a.jpg",
+		"http://example.com":          "http://example.com",
+		" https://example.com/x ":     "https://example.com/x",
+		"javascript:alert(1)":         "",
+		"//example.com/a.jpg":         "",
+		"/relative.jpg":               "",
+		"ftp://example.com/file":      "",
+		"https://":                    "",
+		"":                            "",
+	}
+	for in, want := range tests {
+		if got := URL(in); got != want {
+			t.Errorf("URL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
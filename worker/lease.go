@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go-mvc-demo/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Lease là một khoá có thời hạn lưu trong collection locks, đảm bảo mỗi
+// job chỉ chạy trên một replica tại một thời điểm. Nếu replica giữ khoá chết,
+// khoá tự hết hạn sau TTL và replica khác có thể lấy.
+type Lease struct {
+	ID         string    `bson:"_id"`
+	Owner      string    `bson:"owner"`
+	ExpiresAt  time.Time `bson:"expires_at"`
+	AcquiredAt time.Time `bson:"acquired_at"`
+}
+
+func leases() *mongo.Collection {
+	return config.DB.Collection("locks")
+}
+
+// AcquireLease lấy (hoặc gia hạn) khoá name cho owner trong ttl.
+// Trả về false nếu khoá đang được replica khác giữ.
+func AcquireLease(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	filter := bson.M{
+		"_id": name,
+		"$or": bson.A{
+			bson.M{"owner": owner},
+			bson.M{"expires_at": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{
+		"owner":       owner,
+		"expires_at":  now.Add(ttl),
+		"acquired_at": now,
+	}}
+
+	_, err := leases().UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// Khoá tồn tại và còn hạn: upsert đụng _id của replica khác.
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ReleaseLease trả khoá nếu owner đang giữ nó.
+func ReleaseLease(ctx context.Context, name, owner string) error {
+	_, err := leases().DeleteOne(ctx, bson.M{"_id": name, "owner": owner})
+	return err
+}
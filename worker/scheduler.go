@@ -0,0 +1,101 @@
+// Package worker chạy các job định kỳ bên trong server.
+//
+// Mỗi job được bảo vệ bởi một lease trong Mongo nên khi chạy nhiều replica,
+// tại mỗi thời điểm chỉ một replica thực thi job đó.
+package worker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Job là một tác vụ chạy định kỳ.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	// LeaseTTL mặc định bằng 2 lần Interval (tối thiểu 30s).
+	LeaseTTL time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler chạy các Job đã đăng ký cho tới khi context bị huỷ.
+type Scheduler struct {
+	owner string
+	jobs  []Job
+	wg    sync.WaitGroup
+}
+
+func NewScheduler() *Scheduler {
+	host, _ := os.Hostname()
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	return &Scheduler{owner: fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(buf))}
+}
+
+func (s *Scheduler) Add(job Job) {
+	if job.LeaseTTL == 0 {
+		job.LeaseTTL = 2 * job.Interval
+		if job.LeaseTTL < 30*time.Second {
+			job.LeaseTTL = 30 * time.Second
+		}
+	}
+	s.jobs = append(s.jobs, job)
+}
+
+// Start chạy mỗi job trong một goroutine riêng.
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func(job Job) {
+			defer s.wg.Done()
+			s.loop(ctx, job)
+		}(job)
+	}
+}
+
+// Wait chờ tất cả job dừng hẳn sau khi context của Start bị huỷ.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+	defer func() {
+		// Dùng context riêng vì ctx đã bị huỷ khi shutdown.
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		ReleaseLease(releaseCtx, job.Name, s.owner)
+	}()
+
+	for {
+		s.runOnce(ctx, job)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context, job Job) {
+	ok, err := AcquireLease(ctx, job.Name, s.owner, job.LeaseTTL)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("⚠️ [%s] không lấy được lease: %v", job.Name, err)
+		}
+		return
+	}
+	if !ok {
+		return
+	}
+	if err := job.Run(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("⚠️ [%s] lỗi: %v", job.Name, err)
+	}
+}